@@ -0,0 +1,169 @@
+// Command server runs the users micro-service.
+//
+// For local development it only needs a SQLite file, e.g.
+//
+//	go run ./cmd/server -db users.db -keys-dir ./keys -mail-file mail.log
+//
+// Activation emails are appended to the mail file (or printed if it is not set), and the signing keys of access
+// tokens are generated in the keys directory on the first run. User events are relayed from the outbox to the events
+// file if it is set.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/mattn/go-sqlite3"
+
+	apiV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/api/v1"
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+	authV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/auth/v1"
+	authzV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/authz/v1"
+	outboxV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/outbox/v1"
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "the address to listen on")
+	driver := flag.String("driver", "sqlite3", "the database driver, sqlite3 or mysql")
+	dsn := flag.String("db", "users.db", "the data source name; MySQL DSNs need parseTime=true")
+	keysDir := flag.String("keys-dir", "keys", "the directory of the keys which sign access tokens")
+	rotateEvery := flag.Duration("rotate-keys-every", 24*time.Hour, "how often the signing key is rotated, 0 to disable")
+	mailFile := flag.String("mail-file", "", "the file which emails are appended to; they are printed if it is not set")
+	tokenSecret := flag.String("token-secret", "", "the secret which signs activation and password reset tokens, USERS_TOKEN_SECRET by default")
+	activationURL := flag.String("activation-url", "", "the page which activation links point to")
+	eventsFile := flag.String("events-file", "", "the JSON-lines file which user events are relayed to; they are only delivered in process if it is not set")
+	admins := flag.String("admins", "", "comma separated IDs of users of the default tenant who are granted the admin role at startup")
+	captureStack := flag.Bool("capture-stack", false, "capture stack traces in errors")
+	flag.Parse()
+	// The secret is not the default value of the flag, or `-help` would print it
+	if *tokenSecret == "" {
+		*tokenSecret = os.Getenv("USERS_TOKEN_SECRET")
+	}
+
+	usvcErrors.SetCaptureStack(*captureStack)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := sql.Open(*driver, *dsn)
+	if err != nil {
+		log.Fatalf("[server] error opening the database, err: %s", err.Error())
+	}
+	defer db.Close()
+	if *driver == "sqlite3" {
+		// SQLite does not support concurrent writers
+		db.SetMaxOpenConns(1)
+	}
+	for _, migrate := range []func(context.Context, *sql.DB) error{userV1.MigrateSQLSchema, authV1.MigrateSQLSchema, authzV1.MigrateSQLSchema} {
+		if err := migrate(ctx, db); err != nil {
+			log.Fatalf("[server] %s", err.Error())
+		}
+	}
+
+	// The SQL repository records the changes of users in the audit log of the same database, in the transactions
+	// which store the changes
+	auditLog := auditV1.NewSQLStore(db)
+
+	userOpts := []userV1.Option{userV1.WithActivationURL(*activationURL)}
+	if *tokenSecret != "" {
+		userOpts = append(userOpts, userV1.WithTokenSecret([]byte(*tokenSecret)))
+	}
+	if *mailFile != "" {
+		mailer, err := userV1.NewFileMailer(*mailFile)
+		if err != nil {
+			log.Fatalf("[server] %s", err.Error())
+		}
+		userOpts = append(userOpts, userV1.WithMailer(mailer))
+	}
+	userRepo := userV1.NewSQLRepository(db)
+	users := userV1.NewManager(userRepo, userOpts...)
+
+	var publisher outboxV1.Publisher = outboxV1.NewInProcessPublisher()
+	if *eventsFile != "" {
+		if publisher, err = outboxV1.NewFilePublisher(*eventsFile); err != nil {
+			log.Fatalf("[server] %s", err.Error())
+		}
+	}
+	go outboxV1.NewRelay(userRepo, publisher).Run(ctx)
+
+	keys, err := authV1.NewFileKeyStore(*keysDir, authV1.DefaultMaxKeys)
+	if err != nil {
+		log.Fatalf("[server] %s", err.Error())
+	}
+	auth := authV1.NewManager(users, authV1.NewSQLRepository(db), keys)
+
+	authz := authzV1.NewManager(authzV1.NewSQLRepository(db))
+	for _, ID := range strings.Split(*admins, ",") {
+		if ID = strings.TrimSpace(ID); ID == "" {
+			continue
+		}
+		if err := authz.BindRole(ctx, ID, authzV1.RoleAdmin); err != nil && !errors.Is(err, usvcErrors.ErrConflict) {
+			log.Fatalf("[server] error granting the admin role to user %s, err: %s", ID, err.Error())
+		}
+	}
+
+	if *rotateEvery > 0 {
+		go rotateKeys(ctx, keys, *rotateEvery)
+	}
+
+	api := apiV1.NewAPIServer(users, log.Default(), apiV1.NopMetrics{}, apiV1.WithAuth(auth), apiV1.WithAuthz(authz), apiV1.WithAuditLog(auditLog))
+	if err := api.VerifyOpenAPI(); err != nil {
+		log.Fatalf("[server] %s", err.Error())
+	}
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           api.Router(),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("[server] error shutting down, err: %s", err.Error())
+		}
+	}()
+
+	log.Printf("[server] listening on %s", *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("[server] error serving, err: %s", err.Error())
+	}
+}
+
+// rotateKeys rotates the signing keys periodically until the context is done. Between rotations it reloads the keys
+// so that keys rotated by other instances sharing the directory are picked up.
+func rotateKeys(ctx context.Context, keys authV1.KeyStore, every time.Duration) {
+	rotate := time.NewTicker(every)
+	defer rotate.Stop()
+	reload := time.NewTicker(time.Minute)
+	defer reload.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-rotate.C:
+			if err := keys.Rotate(); err != nil {
+				log.Printf("[server] error rotating the signing keys, err: %s", err.Error())
+			}
+		case <-reload.C:
+			if err := keys.Reload(); err != nil {
+				log.Printf("[server] error reloading the signing keys, err: %s", err.Error())
+			}
+		}
+	}
+}
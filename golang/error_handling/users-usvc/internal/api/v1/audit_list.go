@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// listAuditRecords is the API handler for querying the audit log, e.g. `?target_id=<user ID>` answers who changed
+// a user. It supports the following query parameters:
+//
+//	cursor                     - the `meta.next_cursor` of the previous page
+//	limit                      - the page size, 50 by default and 500 at most
+//	actor, action, target_id   - filter records by exact match
+//	since, until               - filter records by creation time, in RFC 3339
+func (s *APIServer) listAuditRecords(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := &auditV1.Query{
+		Actor:    q.Get("actor"),
+		Action:   q.Get("action"),
+		TargetID: q.Get("target_id"),
+		Cursor:   q.Get("cursor"),
+	}
+
+	var violations []usvcErrors.FieldViolation
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(v); err != nil {
+			violations = append(violations, usvcErrors.FieldViolation{Field: "limit", Description: "The limit must be an integer."})
+		}
+	}
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"since", &query.Since}, {"until", &query.Until}} {
+		if v := q.Get(p.name); v != "" {
+			var err error
+			if *p.t, err = time.Parse(time.RFC3339, v); err != nil {
+				violations = append(violations, usvcErrors.FieldViolation{Field: p.name, Description: "The time must be in RFC 3339, e.g. 2006-01-02T15:04:05Z."})
+			}
+		}
+	}
+	if len(violations) > 0 {
+		usvcResponse.Error(w, r, usvcErrors.NewValidation(violations))
+		return
+	}
+
+	list, err := s.auditLog.Query(r.Context(), query)
+	if err != nil {
+		usvcResponse.Error(w, r, usvcErrors.WrapInternal(err, "Error querying the audit log"))
+		return
+	}
+	usvcResponse.Page(w, list.Records, &usvcResponse.Meta{NextCursor: list.NextCursor})
+}
@@ -0,0 +1,60 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+)
+
+func TestListAuditRecords(t *testing.T) {
+	auditLog, err := auditV1.NewFileStore(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newTestManagerWithRepository(t, userV1.NewMemoryRepository(userV1.WithMemoryAuditLog(auditLog)))
+	r := newTestRouter(m, WithAuditLog(auditLog))
+
+	rec := serve(r, http.MethodPost, "/users/v1/", `{"first_name":"Ann","last_name":"Lee","password":"`+testPassword+`","email":"ann@example.com"}`,
+		map[string]string{requestIDHeader: "req-1"})
+	created := &createUserResponse{}
+	decode(t, rec, created)
+	createUser(t, r, "bob@example.com")
+	if _, err := m.Update(context.Background(), created.ID, 1, &userV1.UserUpdate{FirstName: "Anna"}, []string{userV1.UpdateMaskFirstName}); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []*auditV1.Record
+	rec = serve(r, http.MethodGet, "/audit/v1/records?limit=1&target_id="+created.ID, "", nil)
+	env := decode(t, rec, &records)
+	if rec.Code != http.StatusOK || len(records) != 1 || records[0].Action != userV1.AuditActionCreate || env.Meta == nil || env.Meta.NextCursor == "" {
+		t.Fatalf("GET /audit/v1/records = %d %s, want the first record of the user and a cursor", rec.Code, rec.Body.String())
+	}
+	// The request ID of the request which created the user is recorded
+	if records[0].RequestID != "req-1" {
+		t.Errorf("the request ID of the create record = %s, want req-1", records[0].RequestID)
+	}
+
+	records = nil
+	rec = serve(r, http.MethodGet, "/audit/v1/records?limit=1&target_id="+created.ID+"&cursor="+env.Meta.NextCursor, "", nil)
+	decode(t, rec, &records)
+	if len(records) != 1 || records[0].Action != userV1.AuditActionUpdate {
+		t.Errorf("GET /audit/v1/records of the next page = %s, want the update record", rec.Body.String())
+	}
+
+	for _, target := range []string{"/audit/v1/records?since=yesterday", "/audit/v1/records?until=2024-01-02", "/audit/v1/records?limit=ten"} {
+		if rec := serve(r, http.MethodGet, target, "", nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d %s, want 400", target, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestListAuditRecordsDisabled(t *testing.T) {
+	r := newTestRouter(newTestManager(t))
+	if rec := serve(r, http.MethodGet, "/audit/v1/records", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /audit/v1/records without an audit log = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,193 @@
+package v1
+
+import (
+	"net/http"
+
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+	authV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/auth/v1"
+	authzV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/authz/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcOpenAPI "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/openapi"
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// APIVersion - the version of the users API in the OpenAPI document
+const APIVersion = "1.0.0"
+
+// OpenAPI returns the OpenAPI document of the endpoints served by `Router`. `VerifyOpenAPI` checks that both
+// describe the same endpoints, so an endpoint added to one of them only must be added to the other.
+func (s *APIServer) OpenAPI() *usvcOpenAPI.Document {
+	d := usvcOpenAPI.NewDocument("users-usvc", APIVersion)
+	d.Info.Description = "Manage users and their sessions. Errors are returned in the `error` member of the response envelope, in the problem details format of RFC 7807."
+	tags := []string{"users"}
+	idParam := &usvcOpenAPI.Parameter{Name: "id", In: "path", Required: true, Schema: usvcOpenAPI.String("The ID of the user")}
+	// Protected endpoints are scoped to the tenant of the access token, so only public ones take the header
+	tenantParam := &usvcOpenAPI.Parameter{Name: usvcTenant.Header, In: "header", Schema: usvcOpenAPI.String("The tenant of the user, the default tenant if it is not given")}
+	// Every endpoint may fail with these errors
+	common := []usvcErrors.ErrType{usvcErrors.ErrTypeInternalServerErr, usvcErrors.ErrTypeUnavailable, usvcErrors.ErrTypeTimeout}
+
+	d.Add(http.MethodGet, "/openapi.json", &usvcOpenAPI.Operation{
+		OperationID: "getOpenAPI",
+		Summary:     "Get this document",
+		Responses:   map[string]*usvcOpenAPI.Response{"200": {Description: "The OpenAPI document"}},
+	})
+
+	d.Add(http.MethodPost, "/users/v1/", &usvcOpenAPI.Operation{
+		OperationID: "createUser",
+		Summary:     "Create a user, who has to be activated with the token sent by email",
+		Tags:        tags,
+		Parameters: []*usvcOpenAPI.Parameter{tenantParam, {
+			Name: "Idempotency-Key", In: "header", Schema: usvcOpenAPI.String("Retries with the same key get the user created by the first request"),
+		}},
+		RequestBody: usvcOpenAPI.JSONBody(createUserRequest{}),
+		Responses:   map[string]*usvcOpenAPI.Response{"201": withLocation(usvcOpenAPI.DataResponse("The user is created", createUserResponse{}))},
+	}, append(common, usvcErrors.ErrTypeBadRequest, usvcErrors.ErrTypeConflict)...)
+	d.Add(http.MethodPost, "/users/v1/activate", &usvcOpenAPI.Operation{
+		OperationID: "activateUser",
+		Summary:     "Activate a user with the token sent by email",
+		Tags:        tags,
+		RequestBody: usvcOpenAPI.JSONBody(activateUserRequest{}),
+		Responses:   map[string]*usvcOpenAPI.Response{"200": withETag(usvcOpenAPI.DataResponse("The activated user", userResponse{}))},
+	}, append(common, usvcErrors.ErrTypeBadRequest, usvcErrors.ErrTypeNotFound, usvcErrors.ErrTypeConflict)...)
+
+	d.Add(http.MethodGet, "/users/v1/", s.protectedOperation(&usvcOpenAPI.Operation{
+		OperationID: "listUsers",
+		Summary:     "List users",
+		Tags:        tags,
+		Parameters: []*usvcOpenAPI.Parameter{
+			{Name: "cursor", In: "query", Schema: usvcOpenAPI.String("The `meta.next_cursor` of the previous page")},
+			{Name: "limit", In: "query", Schema: &usvcOpenAPI.Schema{Type: "integer", Description: "The page size, 20 by default and 100 at most"}},
+			{Name: "first_name", In: "query", Schema: usvcOpenAPI.String("Filter users by first name")},
+			{Name: "last_name", In: "query", Schema: usvcOpenAPI.String("Filter users by last name")},
+			{Name: "email", In: "query", Schema: usvcOpenAPI.String("Filter users by email")},
+			{Name: "status", In: "query", Schema: usvcOpenAPI.String("Filter users by status")},
+			{Name: "include_deleted", In: "query", Schema: &usvcOpenAPI.Schema{Type: "boolean", Description: "Include soft deleted users"}},
+		},
+		Responses: map[string]*usvcOpenAPI.Response{"200": usvcOpenAPI.PageResponse("A page of users", userResponse{})},
+	}), s.protectedErrTypes(common, usvcErrors.ErrTypeBadRequest)...)
+	d.Add(http.MethodPost, "/users/v1/import", s.protectedOperation(&usvcOpenAPI.Operation{
+		OperationID: "importUsers",
+		Summary:     "Create users in bulk; every row is validated like creating a user and the result of every row is reported",
+		Tags:        tags,
+		Parameters: []*usvcOpenAPI.Parameter{
+			{Name: "dry_run", In: "query", Schema: &usvcOpenAPI.Schema{Type: "boolean", Description: "Only validate the rows"}},
+			{Name: "batch_size", In: "query", Schema: &usvcOpenAPI.Schema{Type: "integer", Description: "How many rows are created in one transaction, 100 by default and 1000 at most"}},
+		},
+		RequestBody: &usvcOpenAPI.RequestBody{Required: true, Content: map[string]*usvcOpenAPI.MediaType{
+			"text/csv":             {Schema: usvcOpenAPI.String("A header row naming the columns first_name, last_name, email and password, followed by a row per user")},
+			"application/x-ndjson": {Schema: usvcOpenAPI.String("A JSON object per line with the members first_name, last_name, email and password")},
+		}},
+		Responses: map[string]*usvcOpenAPI.Response{"200": usvcOpenAPI.DataResponse("The result of every row", importReportResponse{})},
+	}), s.protectedErrTypes(common, usvcErrors.ErrTypeBadRequest)...)
+	d.Add(http.MethodGet, "/users/v1/export", s.protectedOperation(&usvcOpenAPI.Operation{
+		OperationID: "exportUsers",
+		Summary:     "Stream users as CSV or JSON lines",
+		Tags:        tags,
+		Parameters: []*usvcOpenAPI.Parameter{
+			{Name: "format", In: "query", Schema: &usvcOpenAPI.Schema{Type: "string", Enum: []string{"csv", "jsonl"}, Description: "The format of the file, csv by default"}},
+			{Name: "first_name", In: "query", Schema: usvcOpenAPI.String("Filter users by first name")},
+			{Name: "last_name", In: "query", Schema: usvcOpenAPI.String("Filter users by last name")},
+			{Name: "email", In: "query", Schema: usvcOpenAPI.String("Filter users by email")},
+			{Name: "status", In: "query", Schema: usvcOpenAPI.String("Filter users by status")},
+			{Name: "include_deleted", In: "query", Schema: &usvcOpenAPI.Schema{Type: "boolean", Description: "Include soft deleted users"}},
+		},
+		Responses: map[string]*usvcOpenAPI.Response{"200": {Description: "The users, ordered by ID", Content: map[string]*usvcOpenAPI.MediaType{
+			"text/csv":             {Schema: usvcOpenAPI.String("A header row followed by a row per user")},
+			"application/x-ndjson": {Schema: usvcOpenAPI.String("A JSON object per user")},
+		}}},
+	}), s.protectedErrTypes(common, usvcErrors.ErrTypeBadRequest)...)
+	d.Add(http.MethodGet, "/users/v1/{id}", s.protectedOperation(&usvcOpenAPI.Operation{
+		OperationID: "getUser",
+		Summary:     "Get a user",
+		Tags:        tags,
+		Parameters:  []*usvcOpenAPI.Parameter{idParam},
+		Responses:   map[string]*usvcOpenAPI.Response{"200": withETag(usvcOpenAPI.DataResponse("The user", userResponse{}))},
+	}), s.protectedErrTypes(common, usvcErrors.ErrTypeNotFound)...)
+	d.Add(http.MethodPatch, "/users/v1/{id}", s.protectedOperation(&usvcOpenAPI.Operation{
+		OperationID: "updateUser",
+		Summary:     "Update the fields of a user present in the request body",
+		Tags:        tags,
+		Parameters: []*usvcOpenAPI.Parameter{idParam, {
+			Name: "If-Match", In: "header", Required: true, Schema: usvcOpenAPI.String("The ETag of the user the update is based on; the update fails with 409 if the user has changed since"),
+		}},
+		RequestBody: usvcOpenAPI.JSONBody(updateUserRequest{}),
+		Responses:   map[string]*usvcOpenAPI.Response{"200": withETag(usvcOpenAPI.DataResponse("The updated user", userResponse{}))},
+	}), s.protectedErrTypes(common, usvcErrors.ErrTypeBadRequest, usvcErrors.ErrTypeNotFound, usvcErrors.ErrTypeConflict)...)
+	d.Add(http.MethodDelete, "/users/v1/{id}", s.protectedOperation(&usvcOpenAPI.Operation{
+		OperationID: "deleteUser",
+		Summary:     "Delete a user",
+		Tags:        tags,
+		Parameters: []*usvcOpenAPI.Parameter{idParam, {
+			Name: "hard", In: "query", Schema: &usvcOpenAPI.Schema{Type: "boolean", Description: "Delete the user permanently instead of soft deleting it"},
+		}},
+		Responses: map[string]*usvcOpenAPI.Response{"204": {Description: "The user is deleted"}},
+	}), s.protectedErrTypes(common, usvcErrors.ErrTypeBadRequest, usvcErrors.ErrTypeNotFound)...)
+	d.Add(http.MethodPut, "/users/v1/{id}/status", s.protectedOperation(&usvcOpenAPI.Operation{
+		OperationID: "setUserStatus",
+		Summary:     "Disable or re-enable a user",
+		Tags:        tags,
+		Parameters:  []*usvcOpenAPI.Parameter{idParam},
+		RequestBody: usvcOpenAPI.JSONBody(setUserStatusRequest{}),
+		Responses:   map[string]*usvcOpenAPI.Response{"200": usvcOpenAPI.DataResponse("The user in the new status", userResponse{})},
+	}), s.protectedErrTypes(common, usvcErrors.ErrTypeBadRequest, usvcErrors.ErrTypeNotFound, usvcErrors.ErrTypeConflict)...)
+
+	if s.auditLog != nil {
+		d.Add(http.MethodGet, "/audit/v1/records", s.protectedOperation(&usvcOpenAPI.Operation{
+			OperationID: "listAuditRecords",
+			Summary:     "Query the audit log of user changes",
+			Tags:        []string{"audit"},
+			Parameters: []*usvcOpenAPI.Parameter{
+				{Name: "cursor", In: "query", Schema: usvcOpenAPI.String("The `meta.next_cursor` of the previous page")},
+				{Name: "limit", In: "query", Schema: &usvcOpenAPI.Schema{Type: "integer", Description: "The page size, 50 by default and 500 at most"}},
+				{Name: "actor", In: "query", Schema: usvcOpenAPI.String("Filter records by the ID of the user who made the change")},
+				{Name: "action", In: "query", Schema: usvcOpenAPI.String("Filter records by action, e.g. `user.update`")},
+				{Name: "target_id", In: "query", Schema: usvcOpenAPI.String("Filter records by the ID of the changed user")},
+				{Name: "since", In: "query", Schema: &usvcOpenAPI.Schema{Type: "string", Format: "date-time", Description: "Only records created at or after the time"}},
+				{Name: "until", In: "query", Schema: &usvcOpenAPI.Schema{Type: "string", Format: "date-time", Description: "Only records created before the time"}},
+			},
+			Responses: map[string]*usvcOpenAPI.Response{"200": usvcOpenAPI.PageResponse("A page of audit records, from the oldest to the newest", auditV1.Record{})},
+		}), s.protectedErrTypes(common, usvcErrors.ErrTypeBadRequest)...)
+	}
+	if s.auth != nil {
+		authV1.DescribeRoutes(d)
+		if s.authz != nil {
+			authzV1.DescribeRoutes(d)
+		}
+	}
+	return d
+}
+
+// VerifyOpenAPI checks that the OpenAPI document and the router of the API server describe the same endpoints.
+// Servers should call it at startup so that the document never drifts from the routes.
+func (s *APIServer) VerifyOpenAPI() error {
+	return usvcOpenAPI.Verify(s.Router(), s.OpenAPI())
+}
+
+// protectedOperation documents that the operation needs an access token if auth is enabled
+func (s *APIServer) protectedOperation(op *usvcOpenAPI.Operation) *usvcOpenAPI.Operation {
+	if s.auth != nil {
+		op.Security = []map[string][]string{{usvcOpenAPI.SecurityBearer: {}}}
+	}
+	return op
+}
+
+// protectedErrTypes returns the error types of a protected operation, which fails with 401 and 403 if auth is enabled
+func (s *APIServer) protectedErrTypes(common []usvcErrors.ErrType, errTypes ...usvcErrors.ErrType) []usvcErrors.ErrType {
+	all := append(append([]usvcErrors.ErrType{}, common...), errTypes...)
+	if s.auth != nil {
+		all = append(all, usvcErrors.ErrTypeUnauthorized, usvcErrors.ErrTypeForbidden)
+	}
+	return all
+}
+
+// withETag documents the `ETag` header of a user, which is passed back in `If-Match` to update the user
+func withETag(resp *usvcOpenAPI.Response) *usvcOpenAPI.Response {
+	resp.Headers = map[string]*usvcOpenAPI.Header{"ETag": {Description: "The version of the user", Schema: usvcOpenAPI.String("")}}
+	return resp
+}
+
+// withLocation documents the `Location` header of a created resource
+func withLocation(resp *usvcOpenAPI.Response) *usvcOpenAPI.Response {
+	resp.Headers = map[string]*usvcOpenAPI.Header{"Location": {Description: "The URL of the created resource", Schema: usvcOpenAPI.String("")}}
+	return resp
+}
@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+	authV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/auth/v1"
+	authzV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/authz/v1"
+	usvcOpenAPI "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/openapi"
+)
+
+// testRouterOptions returns the options which enable every optional feature of the API server
+func testRouterOptions(t *testing.T) []RouterOption {
+	t.Helper()
+	keys, err := authV1.NewFileKeyStore(t.TempDir(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditLog, err := auditV1.NewFileStore(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []RouterOption{
+		WithAuth(authV1.NewManager(newTestManager(t), authV1.NewMemoryRepository(), keys)),
+		WithAuthz(authzV1.NewManager(authzV1.NewMemoryRepository())),
+		WithAuditLog(auditLog),
+	}
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	tests := map[string][]RouterOption{
+		"bare":          nil,
+		"every feature": testRouterOptions(t),
+	}
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := NewAPIServer(newTestManager(t), nil, nil, opts...)
+			if err := usvcOpenAPI.Verify(s.Router(), s.OpenAPI()); err != nil {
+				t.Errorf("Verify() err: %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenAPIDocumentsAuth(t *testing.T) {
+	bare := NewAPIServer(newTestManager(t), nil, nil).OpenAPI()
+	if op := (*bare.Paths["/users/v1/{id}"])["get"]; len(op.Security) != 0 {
+		t.Errorf("GET /users/v1/{id} needs %v without auth, want no security", op.Security)
+	}
+
+	d := NewAPIServer(newTestManager(t), nil, nil, testRouterOptions(t)...).OpenAPI()
+	for path, methods := range map[string][]string{"/users/v1/{id}": {"get", "patch", "delete"}, "/users/v1/": {"get"}, "/users/v1/{id}/status": {"put"}, "/authz/v1/roles": {"get", "post"}, "/audit/v1/records": {"get"}} {
+		for _, method := range methods {
+			op := (*d.Paths[path])[method]
+			if len(op.Security) != 1 || op.Responses["401"] == nil || op.Responses["403"] == nil {
+				t.Errorf("%s %s does not document the bearer token and its errors", method, path)
+			}
+		}
+	}
+	// Signing up stays public
+	if op := (*d.Paths["/users/v1/"])["post"]; len(op.Security) != 0 {
+		t.Errorf("POST /users/v1/ needs %v, want no security", op.Security)
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	r := newTestRouter(newTestManager(t))
+	rec := serve(r, http.MethodGet, "/openapi.json", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d %s, want 200", rec.Code, rec.Body.String())
+	}
+	d := &usvcOpenAPI.Document{}
+	if err := json.Unmarshal(rec.Body.Bytes(), d); err != nil {
+		t.Fatal(err)
+	}
+	if d.OpenAPI == "" || d.Info.Version != APIVersion || d.Paths["/users/v1/{id}"] == nil {
+		t.Errorf("GET /openapi.json = %s, want the document of the users API", rec.Body.String())
+	}
+}
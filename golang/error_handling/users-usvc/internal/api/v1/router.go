@@ -0,0 +1,106 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+	authV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/auth/v1"
+	authzV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/authz/v1"
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcOpenAPI "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/openapi"
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// NewRouter creates the router of the users API:
+//
+//	POST   /users/v1/            - create a user
+//	POST   /users/v1/activate    - activate a user with the token sent by email
+//	GET    /users/v1/            - list users, see `listUsers` for the query parameters
+//	POST   /users/v1/import      - create users in bulk from CSV or JSON lines, see `importUsers`
+//	GET    /users/v1/export      - stream users as CSV or JSON lines, see `exportUsers`
+//	GET    /users/v1/{id}        - get a user
+//	PATCH  /users/v1/{id}        - update some fields of a user
+//	DELETE /users/v1/{id}        - delete a user, permanently with `?hard=true`
+//	PUT    /users/v1/{id}/status - disable or re-enable a user
+//	GET    /audit/v1/records     - query the audit log, if it is enabled with `WithAuditLog`
+//	GET    /openapi.json         - the OpenAPI document of the endpoints
+//
+// Without `WithAuth` every endpoint is public, which is only meant for local development and tests, so a warning
+// is logged.
+//
+// Users are scoped to the tenant named by the `X-Tenant-ID` header, or to the tenant of the access token of
+// authenticated requests, see `scopeTenant`.
+//
+// It serves the API with the default logger and no metrics. Use `NewAPIServer` to inject them.
+func NewRouter(manager userV1.Manager, opts ...RouterOption) *mux.Router {
+	return NewAPIServer(manager, nil, nil, opts...).Router()
+}
+
+// Router creates the router which serves the endpoints listed in `NewRouter`
+func (s *APIServer) Router() *mux.Router {
+	if s.auth == nil {
+		s.logger.Printf("[api_v1] WARNING: auth is disabled, so anyone can read, update and delete any user. " +
+			"Pass `WithAuth` unless this is local development or a test.")
+	}
+
+	r := mux.NewRouter()
+	r.Use(s.instrument, scopeTenant)
+	// Middlewares do not run on unmatched requests, so the handlers of these are instrumented on their own
+	r.NotFoundHandler = s.instrument(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		usvcResponse.Error(w, r, usvcErrors.New(usvcErrors.ErrTypeNotFound, "The path %s does not exist.", r.URL.Path))
+	}))
+	r.MethodNotAllowedHandler = s.instrument(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		usvcResponse.Error(w, r, usvcErrors.New(usvcErrors.ErrTypeMethodNotAllowed, "The method %s is not allowed on %s.", r.Method, r.URL.Path))
+	}))
+
+	// Public endpoints
+	r.Handle("/openapi.json", usvcOpenAPI.Handler(s.OpenAPI())).Methods(http.MethodGet)
+	r.HandleFunc("/users/v1/", s.createUser).Methods(http.MethodPost)
+	r.HandleFunc("/users/v1/activate", s.activateUser).Methods(http.MethodPost)
+
+	r.Handle("/users/v1/", s.protect(authzV1.PermissionUsersList, "", s.listUsers)).Methods(http.MethodGet)
+	// Registered before the routes of single users, which would take `import` and `export` as IDs
+	r.Handle("/users/v1/import", s.protect(authzV1.PermissionUsersImport, "", s.importUsers)).Methods(http.MethodPost)
+	r.Handle("/users/v1/export", s.protect(authzV1.PermissionUsersExport, "", s.exportUsers)).Methods(http.MethodGet)
+	r.Handle("/users/v1/{id}", s.protect(authzV1.PermissionUsersRead, "id", s.getUser)).Methods(http.MethodGet)
+	r.Handle("/users/v1/{id}", s.protect(authzV1.PermissionUsersUpdate, "id", s.updateUser)).Methods(http.MethodPatch)
+	r.Handle("/users/v1/{id}", s.protect(authzV1.PermissionUsersDelete, "", s.deleteUser)).Methods(http.MethodDelete)
+	r.Handle("/users/v1/{id}/status", s.protect(authzV1.PermissionUsersManageStatus, "", s.setUserStatus)).Methods(http.MethodPut)
+
+	if s.auditLog != nil {
+		r.Handle("/audit/v1/records", s.protect(authzV1.PermissionAuditRead, "", s.listAuditRecords)).Methods(http.MethodGet)
+	}
+	if s.auth != nil {
+		authV1.RegisterRoutes(r, s.auth)
+		if s.authz != nil {
+			authzV1.RegisterRoutes(r, s.authz, s.auth)
+		}
+	}
+	return r
+}
+
+// protect wraps the handler of a protected endpoint with the middleware which authenticates the user and the one which
+// enforces the permission. Users with the ID in the route variable `selfVar` are let through without the permission.
+// The authenticated user is recorded as the actor in the audit log. Handlers are returned as is if auth is disabled.
+func (s *APIServer) protect(permission authzV1.Permission, selfVar string, h http.HandlerFunc) http.Handler {
+	if s.auth == nil {
+		return h
+	}
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := authV1.ClaimsFromContext(r.Context()); ok {
+			r = r.WithContext(auditV1.WithActor(r.Context(), claims.Subject))
+		}
+		h(w, r)
+	})
+	if s.authz != nil {
+		if selfVar != "" {
+			handler = authzV1.RequireOrSelf(s.authz, permission, selfVar)(handler)
+		} else {
+			handler = authzV1.Require(s.authz, permission)(handler)
+		}
+	}
+	return authV1.Middleware(s.auth)(handler)
+}
@@ -0,0 +1,309 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+
+	authV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/auth/v1"
+	authzV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/authz/v1"
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// testPassword - a password which passes the default password policy
+const testPassword = "Passw0rd!xyz"
+
+// newTestManager creates a user manager backed by a memory repository, see `newTestManagerWithRepository`
+func newTestManager(t *testing.T, opts ...userV1.Option) userV1.Manager {
+	t.Helper()
+	return newTestManagerWithRepository(t, userV1.NewMemoryRepository(), opts...)
+}
+
+// newTestManagerWithRepository creates a user manager backed by the given repository. Passwords are hashed with the
+// minimum bcrypt cost to keep tests fast, and emails are discarded unless a mailer is given in the options.
+func newTestManagerWithRepository(t *testing.T, repo userV1.Repository, opts ...userV1.Option) userV1.Manager {
+	t.Helper()
+	cfg := userV1.DefaultPasswordConfig()
+	cfg.BcryptCost = bcrypt.MinCost
+	hasher, err := userV1.NewPasswordHasher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append([]userV1.Option{userV1.WithPasswordHasher(hasher), userV1.WithMailer(userV1.NewWriterMailer(io.Discard))}, opts...)
+	return userV1.NewManager(repo, opts...)
+}
+
+// newTestRouter creates the router of an API server which discards its logs
+func newTestRouter(m userV1.Manager, opts ...RouterOption) *mux.Router {
+	return NewAPIServer(m, log.New(io.Discard, "", 0), nil, opts...).Router()
+}
+
+// serve sends a request with an optional JSON body and headers to the handler
+func serve(h http.Handler, method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// decode decodes the envelope of a response, with its data decoded into `data` if it is not nil
+func decode(t *testing.T, rec *httptest.ResponseRecorder, data interface{}) *usvcResponse.Envelope {
+	t.Helper()
+	if contentType := rec.Header().Get("Content-Type"); contentType != usvcResponse.ContentType {
+		t.Fatalf("Content-Type = %s, want %s", contentType, usvcResponse.ContentType)
+	}
+	env := &usvcResponse.Envelope{Data: data}
+	if err := json.Unmarshal(rec.Body.Bytes(), env); err != nil {
+		t.Fatalf("the body %q is not an envelope, err: %v", rec.Body.String(), err)
+	}
+	return env
+}
+
+// problem returns the problem details in the envelope of an error response
+func problem(t *testing.T, rec *httptest.ResponseRecorder) *usvcErrors.ProblemDetails {
+	t.Helper()
+	env := decode(t, rec, nil)
+	if env.Error == nil {
+		t.Fatalf("the body %s has no error", rec.Body.String())
+	}
+	return env.Error
+}
+
+// createUser creates a user through the API and returns its ID
+func createUser(t *testing.T, h http.Handler, email string) string {
+	t.Helper()
+	rec := serve(h, http.MethodPost, "/users/v1/", `{"first_name":"Ann","last_name":"Lee","password":"`+testPassword+`","email":"`+email+`"}`, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /users/v1/ = %d %s, want 201", rec.Code, rec.Body.String())
+	}
+	resp := &createUserResponse{}
+	decode(t, rec, resp)
+	if loc := rec.Header().Get("Location"); loc != "/users/v1/"+resp.ID {
+		t.Errorf("POST /users/v1/ Location = %s, want /users/v1/%s", loc, resp.ID)
+	}
+	return resp.ID
+}
+
+// listUsers lists users through the API and returns the page and the cursor of the next one
+func listUsers(t *testing.T, h http.Handler, target string) ([]*userResponse, string) {
+	t.Helper()
+	rec := serve(h, http.MethodGet, target, "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d %s, want 200", target, rec.Code, rec.Body.String())
+	}
+	var users []*userResponse
+	env := decode(t, rec, &users)
+	if env.Meta == nil {
+		return users, ""
+	}
+	return users, env.Meta.NextCursor
+}
+
+func TestUserEndpoints(t *testing.T) {
+	r := newTestRouter(newTestManager(t))
+	ID := createUser(t, r, "ann@example.com")
+
+	rec := serve(r, http.MethodGet, "/users/v1/"+ID, "", nil)
+	user := &userResponse{}
+	decode(t, rec, user)
+	if rec.Code != http.StatusOK || user.Email != "ann@example.com" || user.Status != string(userV1.UserStatusPending) {
+		t.Errorf("GET /users/v1/%s = %d %s, want the pending user", ID, rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "password") {
+		t.Errorf("GET /users/v1/%s exposes the password hash: %s", ID, rec.Body.String())
+	}
+
+	rec = serve(r, http.MethodPatch, "/users/v1/"+ID, `{"first_name":"Anna"}`, map[string]string{"If-Match": rec.Header().Get("ETag")})
+	decode(t, rec, user)
+	if rec.Code != http.StatusOK || user.FirstName != "Anna" || user.LastName != "Lee" {
+		t.Errorf("PATCH /users/v1/%s = %d %s, want the first name updated", ID, rec.Code, rec.Body.String())
+	}
+
+	rec = serve(r, http.MethodPut, "/users/v1/"+ID+"/status", `{"status":"disabled"}`, nil)
+	decode(t, rec, user)
+	if rec.Code != http.StatusOK || user.Status != string(userV1.UserStatusDisabled) {
+		t.Errorf("PUT /users/v1/%s/status = %d %s, want the user disabled", ID, rec.Code, rec.Body.String())
+	}
+
+	if rec = serve(r, http.MethodDelete, "/users/v1/"+ID, "", nil); rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Errorf("DELETE /users/v1/%s = %d %s, want 204 without a body", ID, rec.Code, rec.Body.String())
+	}
+	if rec = serve(r, http.MethodGet, "/users/v1/"+ID, "", nil); rec.Code != http.StatusNotFound || problem(t, rec).Code != userV1.CodeUserNotFound {
+		t.Errorf("GET /users/v1/%s after DELETE = %d %s, want 404 %s", ID, rec.Code, rec.Body.String(), userV1.CodeUserNotFound)
+	}
+	// Soft deleted users are still listed with `include_deleted`
+	if users, _ := listUsers(t, r, "/users/v1/?include_deleted=true"); len(users) != 1 {
+		t.Errorf("GET /users/v1/?include_deleted=true = %+v, want the deleted user", users)
+	}
+	if rec = serve(r, http.MethodDelete, "/users/v1/"+ID+"?hard=true", "", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE /users/v1/%s?hard=true = %d %s, want 204", ID, rec.Code, rec.Body.String())
+	}
+	if users, _ := listUsers(t, r, "/users/v1/?include_deleted=true"); len(users) != 0 {
+		t.Errorf("GET /users/v1/?include_deleted=true after a hard delete = %+v, want no users", users)
+	}
+}
+
+func TestCreateUserIdempotencyKey(t *testing.T) {
+	r := newTestRouter(newTestManager(t))
+	body := `{"first_name":"Ann","last_name":"Lee","password":"` + testPassword + `","email":"ann@example.com"}`
+	headers := map[string]string{"Idempotency-Key": "key-1"}
+
+	first, retry := serve(r, http.MethodPost, "/users/v1/", body, headers), serve(r, http.MethodPost, "/users/v1/", body, headers)
+	if first.Code != http.StatusCreated || retry.Code != http.StatusCreated || first.Body.String() != retry.Body.String() {
+		t.Errorf("POST /users/v1/ retried with the same key = %d %s, want %d %s", retry.Code, retry.Body.String(), first.Code, first.Body.String())
+	}
+
+	rec := serve(r, http.MethodPost, "/users/v1/", strings.Replace(body, "ann@", "bob@", 1), headers)
+	if rec.Code != http.StatusConflict || problem(t, rec).Code != userV1.CodeIdempotencyKeyReused {
+		t.Errorf("POST /users/v1/ with a reused key = %d %s, want 409 %s", rec.Code, rec.Body.String(), userV1.CodeIdempotencyKeyReused)
+	}
+}
+
+func TestListUsersPagination(t *testing.T) {
+	r := newTestRouter(newTestManager(t))
+	for _, email := range []string{"ann@example.com", "bob@example.com", "cid@example.com"} {
+		createUser(t, r, email)
+	}
+
+	var emails []string
+	target := "/users/v1/?limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 2 {
+			t.Fatalf("GET /users/v1/ returned more than 2 pages")
+		}
+		users, cursor := listUsers(t, r, target)
+		for _, u := range users {
+			emails = append(emails, u.Email)
+		}
+		if cursor == "" {
+			break
+		}
+		target = "/users/v1/?limit=2&cursor=" + cursor
+	}
+	if len(emails) != 3 {
+		t.Errorf("GET /users/v1/ returned %v over the pages, want the 3 users", emails)
+	}
+
+	if users, _ := listUsers(t, r, "/users/v1/?email=bob@example.com"); len(users) != 1 || users[0].Email != "bob@example.com" {
+		t.Errorf("GET /users/v1/?email=bob@example.com = %+v, want bob", users)
+	}
+	// Empty pages are written as `[]`
+	if rec := serve(r, http.MethodGet, "/users/v1/?status=disabled", "", nil); !strings.Contains(rec.Body.String(), `"data":[]`) {
+		t.Errorf("GET /users/v1/?status=disabled = %s, want an empty list", rec.Body.String())
+	}
+}
+
+func TestUserEndpointsInvalidRequests(t *testing.T) {
+	r := newTestRouter(newTestManager(t))
+	ID := createUser(t, r, "ann@example.com")
+
+	tests := []struct {
+		name, method, target, body string
+		wantStatus                 int
+	}{
+		{"create with a malformed body", http.MethodPost, "/users/v1/", `{"email":`, http.StatusBadRequest},
+		{"create with a bad email", http.MethodPost, "/users/v1/", `{"first_name":"Bob","last_name":"Lee","password":"` + testPassword + `","email":"bob"}`, http.StatusBadRequest},
+		{"create with a used email", http.MethodPost, "/users/v1/", `{"first_name":"Ann","last_name":"Lee","password":"` + testPassword + `","email":"ann@example.com"}`, http.StatusConflict},
+		{"bad limit", http.MethodGet, "/users/v1/?limit=ten", "", http.StatusBadRequest},
+		{"bad include_deleted", http.MethodGet, "/users/v1/?include_deleted=maybe", "", http.StatusBadRequest},
+		{"bad hard", http.MethodDelete, "/users/v1/" + ID + "?hard=maybe", "", http.StatusBadRequest},
+		{"unknown status", http.MethodPut, "/users/v1/" + ID + "/status", `{"status":"gone"}`, http.StatusBadRequest},
+		{"missing user", http.MethodGet, "/users/v1/missing", "", http.StatusNotFound},
+		{"delete a missing user", http.MethodDelete, "/users/v1/missing", "", http.StatusNotFound},
+		{"unknown path", http.MethodGet, "/groups/v1/", "", http.StatusNotFound},
+		{"unknown method", http.MethodPut, "/users/v1/" + ID, `{}`, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(r, tt.method, tt.target, tt.body, nil)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s %s = %d %s, want %d", tt.method, tt.target, rec.Code, rec.Body.String(), tt.wantStatus)
+			}
+			if p := problem(t, rec); p.Status != tt.wantStatus {
+				t.Errorf("%s %s = %s, want problem details with status %d", tt.method, tt.target, rec.Body.String(), tt.wantStatus)
+			}
+		})
+	}
+
+	// The user is left as it was
+	if rec := serve(r, http.MethodGet, "/users/v1/"+ID, "", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /users/v1/%s = %d %s, want 200", ID, rec.Code, rec.Body.String())
+	}
+}
+
+// login activates the user with the given ID and returns the header which authenticates the user
+func login(t *testing.T, users userV1.Manager, auth authV1.Manager, ID string) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+	user, err := users.SetStatus(ctx, ID, userV1.UserStatusActive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := auth.Login(ctx, user.Email, testPassword)
+	if err != nil {
+		t.Fatalf("Login(%s) err: %v", user.Email, err)
+	}
+	return map[string]string{"Authorization": "Bearer " + tokens.AccessToken}
+}
+
+func TestProtectedEndpoints(t *testing.T) {
+	users := newTestManager(t)
+	keys, err := authV1.NewFileKeyStore(t.TempDir(), authV1.DefaultMaxKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := authV1.NewManager(users, authV1.NewMemoryRepository(), keys)
+	authz := authzV1.NewManager(authzV1.NewMemoryRepository())
+	r := newTestRouter(users, WithAuth(auth), WithAuthz(authz))
+
+	adminID, annID := createUser(t, r, "admin@example.com"), createUser(t, r, "ann@example.com")
+	if err := authz.BindRole(context.Background(), adminID, authzV1.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	admin, ann := login(t, users, auth, adminID), login(t, users, auth, annID)
+
+	tests := []struct {
+		name, method, target, body string
+		headers                    map[string]string
+		wantStatus                 int
+	}{
+		{"unauthenticated", http.MethodGet, "/users/v1/" + annID, "", nil, http.StatusUnauthorized},
+		{"self", http.MethodGet, "/users/v1/" + annID, "", ann, http.StatusOK},
+		{"another user", http.MethodGet, "/users/v1/" + adminID, "", ann, http.StatusForbidden},
+		{"own status", http.MethodPut, "/users/v1/" + annID + "/status", `{"status":"disabled"}`, ann, http.StatusForbidden},
+		{"roles without the permission", http.MethodGet, "/authz/v1/roles", "", ann, http.StatusForbidden},
+		{"roles", http.MethodGet, "/authz/v1/roles", "", admin, http.StatusOK},
+		{"status", http.MethodPut, "/users/v1/" + annID + "/status", `{"status":"disabled"}`, admin, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(r, tt.method, tt.target, tt.body, tt.headers); rec.Code != tt.wantStatus {
+				t.Errorf("%s %s = %d %s, want %d", tt.method, tt.target, rec.Code, rec.Body.String(), tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRouterWarnsWithoutAuth(t *testing.T) {
+	var logs strings.Builder
+	NewAPIServer(newTestManager(t), log.New(&logs, "", 0), nil).Router()
+	if !strings.Contains(logs.String(), "WARNING: auth is disabled") {
+		t.Errorf("Router() without auth logged %q, want a warning", logs.String())
+	}
+}
@@ -0,0 +1,187 @@
+package v1
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+	authV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/auth/v1"
+	authzV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/authz/v1"
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// Logger defines the interface for the logger of the API server. `*log.Logger` implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// MetricsSink defines the interface for recording the metrics of the API server. Implement it to plug in
+// Prometheus, StatsD, etc.
+type MetricsSink interface {
+	// ObserveRequest records a handled request. The route is the path template, e.g. `/users/v1/{id}`,
+	// so that the number of series does not grow with user IDs.
+	ObserveRequest(method, route string, status int, duration time.Duration)
+}
+
+// NopMetrics is the implementation of MetricsSink interface which discards metrics
+type NopMetrics struct{}
+
+// ObserveRequest - the implementation of the `ObserveRequest` method
+func (NopMetrics) ObserveRequest(method, route string, status int, duration time.Duration) {}
+
+// APIServer serves the users API. Its dependencies are injected at construction, so the same manager (and its
+// database connection pool) serves every request, and tests can pass a manager backed by the in-memory repository:
+//
+//	s := v1.NewAPIServer(userV1.NewManager(userV1.NewMemoryRepository()), log.Default(), v1.NopMetrics{})
+//	srv := httptest.NewServer(s.Router())
+type APIServer struct {
+	manager  userV1.Manager
+	logger   Logger
+	metrics  MetricsSink
+	auth     authV1.Manager
+	authz    authzV1.Manager
+	auditLog auditV1.Store
+}
+
+// RouterOption configures optional features of the API server
+type RouterOption func(s *APIServer)
+
+// WithAuth mounts the auth endpoints and requires an access token on the user endpoints, except for signing up
+// and activating users
+func WithAuth(auth authV1.Manager) RouterOption {
+	return func(s *APIServer) {
+		s.auth = auth
+	}
+}
+
+// WithAuthz enforces the permissions of the user endpoints and mounts the endpoints which manage roles, see
+// `authzV1.RegisterRoutes`. Users can always read and update themselves, while listing and deleting users need the
+// `users:list` and `users:delete` permissions. It only works with `WithAuth`.
+func WithAuthz(authz authzV1.Manager) RouterOption {
+	return func(s *APIServer) {
+		s.authz = authz
+	}
+}
+
+// WithAuditLog mounts the endpoint which queries the audit log. It needs the `audit:read` permission.
+func WithAuditLog(store auditV1.Store) RouterOption {
+	return func(s *APIServer) {
+		s.auditLog = store
+	}
+}
+
+// NewAPIServer creates an instance of APIServer. A nil logger or metrics sink falls back to `log.Default()`
+// and NopMetrics.
+func NewAPIServer(manager userV1.Manager, logger Logger, metrics MetricsSink, opts ...RouterOption) *APIServer {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if metrics == nil {
+		metrics = NopMetrics{}
+	}
+	s := &APIServer{
+		manager: manager,
+		logger:  logger,
+		metrics: metrics,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// instrument is the middleware which records the metrics of requests and logs them. It also puts the request ID into
+// the request context for the audit log: the `X-Request-ID` header set by the gateway, or a generated one.
+// The ID is echoed in the response so that clients can quote it.
+func (s *APIServer) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		r = r.WithContext(auditV1.WithRequestID(r.Context(), requestID))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// Requests which match no route are recorded under one label, or every scanned path would add a series
+		route := "unmatched"
+		if cur := mux.CurrentRoute(r); cur != nil {
+			if tpl, err := cur.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		duration := time.Since(start)
+		s.metrics.ObserveRequest(r.Method, route, rec.status, duration)
+		s.logger.Printf("[api_v1] %s %s %d %s request_id=%s", r.Method, r.URL.Path, rec.status, duration, requestID)
+	})
+}
+
+// scopeTenant is the middleware which puts the tenant named by the `X-Tenant-ID` header into the request context,
+// so that the users of the request are read and written in that tenant. Requests without the header belong to the
+// default tenant, and malformed tenant IDs are rejected with 400. Authenticated requests are scoped to the tenant of
+// their access tokens instead, see `authV1.Middleware`.
+func scopeTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ID := r.Header.Get(usvcTenant.Header)
+		if ID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := usvcTenant.Validate(ID); err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(usvcTenant.WithID(r.Context(), ID)))
+	})
+}
+
+// requestIDHeader - the header which carries the request ID
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID checks whether a request ID given by the client can be recorded as is
+func validRequestID(ID string) bool {
+	if ID == "" || len(ID) > 128 {
+		return false
+	}
+	for _, c := range ID {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	// crypto/rand does not fail on supported platforms
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder records the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code and writes it
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap returns the wrapped writer, so that `http.ResponseController` can flush streamed responses
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
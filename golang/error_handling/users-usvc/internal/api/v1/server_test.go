@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingMetrics - a MetricsSink which records the requests observed
+type recordingMetrics struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+// ObserveRequest - the implementation of the `ObserveRequest` method
+func (m *recordingMetrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, fmt.Sprintf("%s %s %d", method, route, status))
+}
+
+// recordingLogger - a Logger which records the lines logged
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+// Printf - the implementation of the `Printf` method
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestAPIServerDependencies(t *testing.T) {
+	m := newTestManager(t)
+	metrics, logger := &recordingMetrics{}, &recordingLogger{}
+	r := NewAPIServer(m, logger, metrics).Router()
+
+	// The handlers use the injected manager rather than one of their own
+	ID := createUser(t, r, "ann@example.com")
+	if _, err := m.Get(context.Background(), ID); err != nil {
+		t.Errorf("Get() of the user created through the API err: %v", err)
+	}
+	serve(r, http.MethodGet, "/users/v1/"+ID, "", nil)
+	serve(r, http.MethodGet, "/users/v1/missing", "", nil)
+	serve(r, http.MethodGet, "/wp-login.php", "", nil)
+
+	// Routes are recorded as templates, so neither user IDs nor unknown paths create series of their own
+	want := []string{
+		"POST /users/v1/ 201",
+		"GET /users/v1/{id} 200",
+		"GET /users/v1/{id} 404",
+		"GET unmatched 404",
+	}
+	if strings.Join(metrics.requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ObserveRequest() calls = %q, want %q", metrics.requests, want)
+	}
+	// The first line is the warning that auth is disabled
+	if len(logger.lines) != len(want)+1 || !strings.Contains(logger.lines[0], "WARNING") || !strings.Contains(logger.lines[2], "GET /users/v1/"+ID+" 200") {
+		t.Errorf("Printf() calls = %q, want the warning and a line per request", logger.lines)
+	}
+}
+
+func TestAPIServerDefaults(t *testing.T) {
+	// A nil logger and metrics sink fall back to the defaults rather than panicking
+	r := NewRouter(newTestManager(t))
+	if rec := serve(r, http.MethodGet, "/users/v1/", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /users/v1/ = %d %s, want 200", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,92 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	authV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/auth/v1"
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+func TestScopeTenant(t *testing.T) {
+	r := newTestRouter(newTestManager(t))
+	body := `{"first_name":"Ann","last_name":"Lee","password":"` + testPassword + `","email":"ann@example.com"}`
+
+	// The same email is created in both tenants
+	IDs := map[string]string{}
+	for _, tenant := range []string{"acme", "beta"} {
+		rec := serve(r, http.MethodPost, "/users/v1/", body, map[string]string{usvcTenant.Header: tenant})
+		created := &createUserResponse{}
+		decode(t, rec, created)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("POST /users/v1/ in %s = %d %s, want 201", tenant, rec.Code, rec.Body.String())
+		}
+		IDs[tenant] = created.ID
+	}
+
+	tests := []struct {
+		name, tenant string
+		wantStatus   int
+	}{
+		{"own tenant", "acme", http.StatusOK},
+		{"other tenant", "beta", http.StatusNotFound},
+		{"default tenant", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{}
+			if tt.tenant != "" {
+				headers[usvcTenant.Header] = tt.tenant
+			}
+			if rec := serve(r, http.MethodGet, "/users/v1/"+IDs["acme"], "", headers); rec.Code != tt.wantStatus {
+				t.Errorf("GET /users/v1/%s in %q = %d %s, want %d", IDs["acme"], tt.tenant, rec.Code, rec.Body.String(), tt.wantStatus)
+			}
+		})
+	}
+
+	rec := serve(r, http.MethodGet, "/users/v1/", "", map[string]string{usvcTenant.Header: "Not A Tenant"})
+	if rec.Code != http.StatusBadRequest || problem(t, rec).Code != usvcTenant.CodeInvalidTenant {
+		t.Errorf("GET /users/v1/ with an invalid tenant = %d %s, want 400 %s", rec.Code, rec.Body.String(), usvcTenant.CodeInvalidTenant)
+	}
+}
+
+func TestScopeTenantOfAccessTokens(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+	IDs := map[string]string{}
+	for _, tenant := range []string{"acme", "beta"} {
+		tenantCtx := usvcTenant.WithID(ctx, tenant)
+		ID, err := m.Create(tenantCtx, "Ann", "Lee", testPassword, "ann@example.com", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.SetStatus(tenantCtx, ID, userV1.UserStatusActive); err != nil {
+			t.Fatal(err)
+		}
+		IDs[tenant] = ID
+	}
+	keys, err := authV1.NewFileKeyStore(t.TempDir(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRouter(m, WithAuth(authV1.NewManager(m, authV1.NewMemoryRepository(), keys)))
+
+	rec := serve(r, http.MethodPost, "/auth/v1/login", `{"email":"ann@example.com","password":"`+testPassword+`"}`, map[string]string{usvcTenant.Header: "acme"})
+	tokens := &authV1.Tokens{}
+	// The tokens are written as they are, without an envelope
+	if err := json.Unmarshal(rec.Body.Bytes(), tokens); rec.Code != http.StatusOK || err != nil || tokens.AccessToken == "" {
+		t.Fatalf("POST /auth/v1/login in acme = %d %s, want 200", rec.Code, rec.Body.String())
+	}
+
+	// The request is scoped to the tenant of the token whatever tenant the header names
+	headers := map[string]string{"Authorization": "Bearer " + tokens.AccessToken, usvcTenant.Header: "beta"}
+	if rec := serve(r, http.MethodGet, "/users/v1/"+IDs["acme"], "", headers); rec.Code != http.StatusOK {
+		t.Errorf("GET of the user of acme with a token of acme = %d %s, want 200", rec.Code, rec.Body.String())
+	}
+	if rec := serve(r, http.MethodGet, "/users/v1/"+IDs["beta"], "", headers); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of the user of beta with a token of acme = %d %s, want 404", rec.Code, rec.Body.String())
+	}
+}
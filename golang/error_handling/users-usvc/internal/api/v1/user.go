@@ -0,0 +1,57 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// userResponse - the representation of a user in responses. The password hash is never exposed.
+type userResponse struct {
+	ID        string    `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Email     string    `json:"email"`
+	Status    string    `json:"status"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// newUserResponse converts a user to its representation in responses
+func newUserResponse(u *userV1.User) *userResponse {
+	return &userResponse{
+		ID:        u.ID,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Email:     u.Email,
+		Status:    string(u.Status),
+		Version:   u.Version,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
+
+// setETag sets the `ETag` header to the version of the user. Clients pass it back in `If-Match` to update the user.
+func setETag(w http.ResponseWriter, u *userV1.User) {
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(u.Version)))
+}
+
+// ifMatchVersion returns the version of the user in the `If-Match` header. The header is required and must be
+// a single ETag returned by the API, so that updates are always based on a known version of the user.
+func ifMatchVersion(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return 0, usvcErrors.NewValidation([]usvcErrors.FieldViolation{{Field: "If-Match", Description: "The If-Match header is required; set it to the ETag of the user."}})
+	}
+	// Weak ETags, lists of ETags and `*` are rejected as they do not name a single version
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(header, `"`), `"`))
+	if err != nil || version < 1 || header != strconv.Quote(strconv.Itoa(version)) {
+		return 0, usvcErrors.NewValidation([]usvcErrors.FieldViolation{{Field: "If-Match", Description: "The If-Match header must be a single ETag of the user, e.g. \"1\"."}})
+	}
+	return version, nil
+}
@@ -0,0 +1,28 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// deleteUser is the API handler for deleting a user. Users are soft deleted unless `?hard=true` is given.
+func (s *APIServer) deleteUser(w http.ResponseWriter, r *http.Request) {
+	hard := false
+	if v := r.URL.Query().Get("hard"); v != "" {
+		var err error
+		if hard, err = strconv.ParseBool(v); err != nil {
+			usvcResponse.Error(w, r, usvcErrors.NewValidation([]usvcErrors.FieldViolation{{Field: "hard", Description: "The hard must be true or false."}}))
+			return
+		}
+	}
+	if err := s.manager.Delete(r.Context(), mux.Vars(r)["id"], hard); err != nil {
+		usvcResponse.Error(w, r, err)
+		return
+	}
+	usvcResponse.NoContent(w)
+}
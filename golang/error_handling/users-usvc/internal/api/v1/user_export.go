@@ -0,0 +1,86 @@
+package v1
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// exportFormat - a format of exported files
+type exportFormat struct {
+	contentType string
+	extension   string
+	newWriter   func(io.Writer) userV1.ExportWriter
+}
+
+// exportFormats - the formats of exported files by the value of the `format` query parameter
+var exportFormats = map[string]*exportFormat{
+	"csv":   {contentType: "text/csv; charset=utf-8", extension: "csv", newWriter: userV1.NewCSVExportWriter},
+	"jsonl": {contentType: "application/x-ndjson", extension: "jsonl", newWriter: userV1.NewJSONLinesExportWriter},
+}
+
+// exportUsers is the API handler for exporting users. The users are streamed as they are read, so exports of any
+// size use little memory. It supports the following query parameters:
+//
+//	format - `csv` (the default) or `jsonl`
+//	first_name, last_name, email, status - filter users by exact match
+//	include_deleted - `true` to include soft deleted users
+func (s *APIServer) exportUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter, violations := listFilterFromQuery(q)
+	name := q.Get("format")
+	if name == "" {
+		name = "csv"
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		violations = append(violations, usvcErrors.FieldViolation{Field: "format", Description: "The format must be csv or jsonl."})
+	}
+	if len(violations) > 0 {
+		usvcResponse.Error(w, r, usvcErrors.NewValidation(violations))
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// Large exports take longer than the write timeout of the server
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.logger.Printf("[api_v1] error clearing the write deadline of the export, err: %s", err.Error())
+	}
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="users.`+format.extension+`"`)
+	out := &exportResponseWriter{w: w, rc: rc}
+	if err := s.manager.Export(r.Context(), filter, format.newWriter(out)); err != nil {
+		if !out.written {
+			w.Header().Del("Content-Disposition")
+			usvcResponse.Error(w, r, err)
+			return
+		}
+		// The status has been sent, so the export can only be cut short
+		s.logger.Printf("[api_v1] error exporting users, err: %+v", err)
+	}
+}
+
+// exportResponseWriter streams exported users to the response and records whether anything has been written
+type exportResponseWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+	// written tells whether the response has been started, after which errors cannot be responded
+	written bool
+}
+
+// Write - the implementation of `io.Writer`
+func (e *exportResponseWriter) Write(p []byte) (int, error) {
+	e.written = true
+	return e.w.Write(p)
+}
+
+// Flush sends the written users to the client
+func (e *exportResponseWriter) Flush() {
+	// A failed flush means the client has gone; the next write fails and stops the export
+	_ = e.rc.Flush()
+}
@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+func TestExportUsers(t *testing.T) {
+	r := newTestRouter(newTestManager(t))
+	createUser(t, r, "ann@example.com")
+	createUser(t, r, "bob@example.com")
+
+	tests := []struct {
+		name, query                      string
+		wantContentType, wantDisposition string
+		wantLines                        int
+	}{
+		{"csv by default", "", "text/csv; charset=utf-8", `attachment; filename="users.csv"`, 3},
+		{"json lines", "?format=jsonl", "application/x-ndjson", `attachment; filename="users.jsonl"`, 2},
+		{"filtered", "?format=jsonl&email=bob@example.com", "application/x-ndjson", `attachment; filename="users.jsonl"`, 1},
+		{"no users", "?first_name=Nobody", "text/csv; charset=utf-8", `attachment; filename="users.csv"`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(r, http.MethodGet, "/users/v1/export"+tt.query, "", nil)
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != tt.wantContentType || rec.Header().Get("Content-Disposition") != tt.wantDisposition {
+				t.Fatalf("GET /users/v1/export%s = %d %v, want 200 %s", tt.query, rec.Code, rec.Header(), tt.wantContentType)
+			}
+			if lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n"); len(lines) != tt.wantLines {
+				t.Errorf("GET /users/v1/export%s = %q, want %d lines", tt.query, rec.Body.String(), tt.wantLines)
+			}
+			// The users are streamed rather than buffered until the end
+			if !rec.Flushed {
+				t.Errorf("GET /users/v1/export%s did not flush the response", tt.query)
+			}
+		})
+	}
+
+	for _, query := range []string{"?format=xml", "?include_deleted=maybe"} {
+		rec := serve(r, http.MethodGet, "/users/v1/export"+query, "", nil)
+		if rec.Code != http.StatusBadRequest || problem(t, rec).Code != usvcErrors.CodeInvalidFields || rec.Header().Get("Content-Disposition") != "" {
+			t.Errorf("GET /users/v1/export%s = %d %s, want 400 %s", query, rec.Code, rec.Body.String(), usvcErrors.CodeInvalidFields)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// getUser is the API handler for getting a user
+func (s *APIServer) getUser(w http.ResponseWriter, r *http.Request) {
+	user, err := s.manager.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		usvcResponse.Error(w, r, err)
+		return
+	}
+	setETag(w, user)
+	usvcResponse.OK(w, newUserResponse(user))
+}
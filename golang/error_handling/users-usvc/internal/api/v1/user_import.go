@@ -0,0 +1,154 @@
+package v1
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcRequest "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/request"
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// MaxImportBodyBytes - the maximum size of imported files
+const MaxImportBodyBytes = 32 << 20
+
+// importReaders - the readers of imported files by media type
+var importReaders = map[string]func(io.Reader) userV1.ImportReader{
+	"text/csv":             userV1.NewCSVImportReader,
+	"application/x-ndjson": userV1.NewJSONLinesImportReader,
+	"application/jsonl":    userV1.NewJSONLinesImportReader,
+}
+
+// Statuses of imported rows
+const (
+	importRowCreated = "created"
+	importRowValid   = "valid"
+	importRowFailed  = "failed"
+)
+
+// importReportResponse - the response body of importing users
+type importReportResponse struct {
+	DryRun    bool `json:"dry_run"`
+	Total     int  `json:"total"`
+	Succeeded int  `json:"succeeded"`
+	Failed    int  `json:"failed"`
+	// FailedByType counts the failed rows by error type
+	FailedByType map[usvcErrors.ErrType]int `json:"failed_by_type"`
+	Rows         []*importRowResponse       `json:"rows"`
+}
+
+// importRowResponse - the result of an imported row
+type importRowResponse struct {
+	Line int `json:"line"`
+	// Status is `created`, `valid` (in dry runs) or `failed`
+	Status string `json:"status"`
+	Email  string `json:"email,omitempty"`
+	ID     string `json:"id,omitempty"`
+	// Error is the reason why the row failed, in the same format as the errors of requests
+	Error *usvcErrors.ProblemDetails `json:"error,omitempty"`
+}
+
+// newImportReportResponse converts an import report to its representation in responses
+func newImportReportResponse(report *userV1.ImportReport) *importReportResponse {
+	resp := &importReportResponse{
+		DryRun:       report.DryRun,
+		Total:        len(report.Results),
+		Succeeded:    report.Succeeded,
+		Failed:       report.Failed,
+		FailedByType: report.FailedByType,
+		Rows:         make([]*importRowResponse, 0, len(report.Results)),
+	}
+	for _, result := range report.Results {
+		row := &importRowResponse{Line: result.Line, Status: importRowCreated, Email: result.Email, ID: result.ID}
+		if result.Err != nil {
+			row.Status, row.Error = importRowFailed, usvcErrors.NewProblemDetails(result.Err)
+		} else if report.DryRun {
+			row.Status = importRowValid
+		}
+		resp.Rows = append(resp.Rows, row)
+	}
+	return resp
+}
+
+// importUsers is the API handler for creating users in bulk. The body is a CSV file with a header row
+// (`Content-Type: text/csv`) or JSON lines (`Content-Type: application/x-ndjson`), see `userV1.NewCSVImportReader`
+// and `userV1.NewJSONLinesImportReader`. It supports the following query parameters:
+//
+//	dry_run    - `true` to only validate the rows
+//	batch_size - how many rows are created in one transaction, 100 by default and 1000 at most
+//
+// The response reports the result of every row; rows fail with the same errors as creating a user. If the file
+// cannot be read to the end, e.g. as it is too large, the error is returned with the report of the rows before.
+func (s *APIServer) importUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var violations []usvcErrors.FieldViolation
+	dryRun := false
+	if v := q.Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			violations = append(violations, usvcErrors.FieldViolation{Field: "dry_run", Description: "The dry_run must be true or false."})
+		}
+	}
+	batchSize := 0
+	if v := q.Get("batch_size"); v != "" {
+		var err error
+		if batchSize, err = strconv.Atoi(v); err != nil {
+			violations = append(violations, usvcErrors.FieldViolation{Field: "batch_size", Description: "The batch_size must be an integer."})
+		}
+	}
+	if len(violations) > 0 {
+		usvcResponse.Error(w, r, usvcErrors.NewValidation(violations))
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	newReader, ok := importReaders[mediaType]
+	if !ok {
+		usvcResponse.Error(w, r, usvcErrors.NewCoded(usvcErrors.ErrTypeUnsupportedMediaType, usvcRequest.CodeUnsupportedMediaType, map[string]string{"content_type": mediaType},
+			"The Content-Type must be text/csv or application/x-ndjson."))
+		return
+	}
+
+	// Every row is hashed and checked against the database while the body is read, so large imports take longer
+	// than the read and write timeouts of the server
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.logger.Printf("[api_v1] error clearing the read deadline of the import, err: %s", err.Error())
+	}
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.logger.Printf("[api_v1] error clearing the write deadline of the import, err: %s", err.Error())
+	}
+	body := &importBody{r: http.MaxBytesReader(w, r.Body, MaxImportBodyBytes)}
+	report, err := s.manager.Import(r.Context(), newReader(body), &userV1.ImportOptions{DryRun: dryRun, BatchSize: batchSize})
+	if err != nil && report != nil && len(report.Results) > 0 {
+		// The rows before the error have been imported; report them so that the rest can be imported separately
+		usvcResponse.ErrorWithData(w, r, err, newImportReportResponse(report))
+		return
+	}
+	if err != nil {
+		usvcResponse.Error(w, r, err)
+		return
+	}
+	usvcResponse.OK(w, newImportReportResponse(report))
+}
+
+// importBody reads imported files and reports a body over `MaxImportBodyBytes` as a bad request
+type importBody struct {
+	r io.Reader
+}
+
+// Read - the implementation of `io.Reader`
+func (b *importBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return n, usvcErrors.NewCoded(usvcErrors.ErrTypePayloadTooLarge, usvcRequest.CodeBodyTooLarge, map[string]string{"limit": strconv.FormatInt(tooLarge.Limit, 10)},
+			"The imported file exceeds %d bytes; split it into smaller files.", tooLarge.Limit)
+	}
+	return n, err
+}
@@ -0,0 +1,147 @@
+package v1
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcRequest "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/request"
+)
+
+// testImportCSV - a CSV file with a valid row, an invalid row and a row which repeats the email of the first one
+const testImportCSV = "email,first_name,last_name,password\n" +
+	"ann@example.com,Ann,Lee," + testPassword + "\n" +
+	"bob,Bob,Lee,weak\n" +
+	"ann@example.com,Anna,Lee," + testPassword + "\n"
+
+// rowStatuses returns the statuses of the rows of an import report
+func rowStatuses(report *importReportResponse) string {
+	statuses := make([]string, 0, len(report.Rows))
+	for _, row := range report.Rows {
+		statuses = append(statuses, row.Status)
+	}
+	return strings.Join(statuses, ",")
+}
+
+func TestImportUsers(t *testing.T) {
+	r := newTestRouter(newTestManager(t))
+	headers := map[string]string{"Content-Type": "text/csv"}
+
+	rec := serve(r, http.MethodPost, "/users/v1/import?dry_run=true", testImportCSV, headers)
+	report := &importReportResponse{}
+	decode(t, rec, report)
+	if rec.Code != http.StatusOK || !report.DryRun || report.Total != 3 || rowStatuses(report) != "valid,failed,failed" {
+		t.Errorf("POST /users/v1/import?dry_run=true = %d %s, want 1 valid row", rec.Code, rec.Body.String())
+	}
+	if users, _ := listUsers(t, r, "/users/v1/"); len(users) != 0 {
+		t.Errorf("GET /users/v1/ after a dry run = %d users, want none", len(users))
+	}
+
+	rec = serve(r, http.MethodPost, "/users/v1/import?batch_size=2", testImportCSV, headers)
+	report = &importReportResponse{}
+	decode(t, rec, report)
+	if rec.Code != http.StatusOK || report.Succeeded != 1 || report.Failed != 2 || rowStatuses(report) != "created,failed,failed" {
+		t.Fatalf("POST /users/v1/import = %d %s, want 1 created row", rec.Code, rec.Body.String())
+	}
+	if report.FailedByType[usvcErrors.ErrTypeBadRequest] != 1 || report.FailedByType[usvcErrors.ErrTypeConflict] != 1 {
+		t.Errorf("failed_by_type = %v, want a bad request and a conflict", report.FailedByType)
+	}
+	// Rows fail with the same errors as creating a user
+	if row := report.Rows[2]; row.Line != 4 || row.Error == nil || row.Error.Code != userV1.CodeEmailTaken {
+		t.Errorf("the row at line 4 = %+v, want %s", row, userV1.CodeEmailTaken)
+	}
+	if rec := serve(r, http.MethodGet, "/users/v1/"+report.Rows[0].ID, "", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET of the imported user = %d %s, want 200", rec.Code, rec.Body.String())
+	}
+
+	jsonLines := `{"first_name":"Cy","last_name":"Lee","email":"cy@example.com","password":"` + testPassword + `"}` + "\n" + "not json\n"
+	rec = serve(r, http.MethodPost, "/users/v1/import", jsonLines, map[string]string{"Content-Type": "application/x-ndjson"})
+	report = &importReportResponse{}
+	decode(t, rec, report)
+	if rec.Code != http.StatusOK || rowStatuses(report) != "created,failed" || report.Rows[1].Error.Code != userV1.CodeMalformedImportRow {
+		t.Errorf("POST /users/v1/import as JSON lines = %d %s, want 1 created row", rec.Code, rec.Body.String())
+	}
+}
+
+func TestImportUsersInvalidRequests(t *testing.T) {
+	r := newTestRouter(newTestManager(t))
+
+	tests := []struct {
+		name, query, contentType, body string
+		wantStatus                     int
+		wantCode                       string
+	}{
+		{"bad dry_run", "?dry_run=maybe", "text/csv", testImportCSV, http.StatusBadRequest, usvcErrors.CodeInvalidFields},
+		{"bad batch_size", "?batch_size=ten", "text/csv", testImportCSV, http.StatusBadRequest, usvcErrors.CodeInvalidFields},
+		{"batch_size over the maximum", "?batch_size=5000", "text/csv", testImportCSV, http.StatusBadRequest, string(usvcErrors.ErrTypeBadRequest)},
+		{"unsupported media type", "", "application/json", `{}`, http.StatusUnsupportedMediaType, usvcRequest.CodeUnsupportedMediaType},
+		{"malformed header row", "", "text/csv", "email,first_name\n", http.StatusBadRequest, userV1.CodeMalformedImport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(r, http.MethodPost, "/users/v1/import"+tt.query, tt.body, map[string]string{"Content-Type": tt.contentType})
+			if rec.Code != tt.wantStatus || problem(t, rec).Code != tt.wantCode {
+				t.Errorf("POST /users/v1/import%s = %d %s, want %d %s", tt.query, rec.Code, rec.Body.String(), tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestImportUsersPartially(t *testing.T) {
+	r := newTestRouter(newTestManager(t))
+	var b strings.Builder
+	for _, name := range []string{"ann", "bob", "cy"} {
+		b.WriteString(`{"first_name":"Ann","last_name":"Lee","email":"` + name + `@example.com","password":"` + testPassword + `"}` + "\n")
+	}
+	b.WriteString(strings.Repeat("x", userV1.MaxImportLineBytes+1) + "\n")
+
+	rec := serve(r, http.MethodPost, "/users/v1/import?batch_size=2", b.String(), map[string]string{"Content-Type": "application/x-ndjson"})
+	report := &importReportResponse{}
+	env := decode(t, rec, report)
+	if rec.Code != http.StatusBadRequest || env.Error == nil || env.Error.Code != userV1.CodeMalformedImport {
+		t.Fatalf("POST /users/v1/import = %d %s, want 400 %s", rec.Code, rec.Body.String(), userV1.CodeMalformedImport)
+	}
+	// The rows of the batches before the error are reported so that the rest can be imported separately
+	if rowStatuses(report) != "created,created" {
+		t.Errorf("POST /users/v1/import reported %s, want the 2 rows of the first batch", rec.Body.String())
+	}
+	if users, _ := listUsers(t, r, "/users/v1/"); len(users) != 2 {
+		t.Errorf("GET /users/v1/ after a partial import = %d users, want 2", len(users))
+	}
+}
+
+// slowImportManager - a Manager whose imports take longer than the timeouts of the test server
+type slowImportManager struct {
+	userV1.Manager
+	delay time.Duration
+}
+
+// Import - the implementation of the `Import` method
+func (m *slowImportManager) Import(ctx context.Context, rows userV1.ImportReader, opts *userV1.ImportOptions) (*userV1.ImportReport, error) {
+	time.Sleep(m.delay)
+	return m.Manager.Import(ctx, rows, opts)
+}
+
+func TestImportUsersOutlastsServerTimeouts(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	srv := httptest.NewUnstartedServer(newTestRouter(&slowImportManager{Manager: newTestManager(t), delay: 3 * timeout}))
+	srv.Config.ReadTimeout, srv.Config.WriteTimeout = timeout, timeout
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/users/v1/import", "text/csv", strings.NewReader(testImportCSV))
+	if err != nil {
+		t.Fatalf("POST /users/v1/import err: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"total":3`) {
+		t.Errorf("POST /users/v1/import = %d %s, %v, want the report despite the timeouts", resp.StatusCode, body, err)
+	}
+}
@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// listUsers is the API handler for listing users. It supports the following query parameters:
+//
+//	cursor          - the `meta.next_cursor` of the previous page
+//	limit           - the page size, 20 by default and 100 at most
+//	first_name, last_name, email, status - filter users by exact match
+//	include_deleted - `true` to include soft deleted users
+func (s *APIServer) listUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter, violations := listFilterFromQuery(q)
+	limit := 0
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil {
+			violations = append(violations, usvcErrors.FieldViolation{Field: "limit", Description: "The limit must be an integer."})
+		}
+	}
+	if len(violations) > 0 {
+		usvcResponse.Error(w, r, usvcErrors.NewValidation(violations))
+		return
+	}
+
+	list, err := s.manager.List(r.Context(), filter, q.Get("cursor"), limit)
+	if err != nil {
+		usvcResponse.Error(w, r, err)
+		return
+	}
+
+	users := make([]*userResponse, 0, len(list.Users))
+	for _, u := range list.Users {
+		users = append(users, newUserResponse(u))
+	}
+	usvcResponse.Page(w, users, &usvcResponse.Meta{NextCursor: list.NextCursor})
+}
+
+// listFilterFromQuery reads the filter of users from the query parameters `first_name`, `last_name`, `email`,
+// `status` and `include_deleted`
+func listFilterFromQuery(q url.Values) (*userV1.ListFilter, []usvcErrors.FieldViolation) {
+	var violations []usvcErrors.FieldViolation
+	includeDeleted := false
+	if v := q.Get("include_deleted"); v != "" {
+		var err error
+		if includeDeleted, err = strconv.ParseBool(v); err != nil {
+			violations = append(violations, usvcErrors.FieldViolation{Field: "include_deleted", Description: "The include_deleted must be true or false."})
+		}
+	}
+	return &userV1.ListFilter{
+		FirstName:      q.Get("first_name"),
+		LastName:       q.Get("last_name"),
+		Email:          q.Get("email"),
+		Status:         userV1.UserStatus(q.Get("status")),
+		IncludeDeleted: includeDeleted,
+	}, violations
+}
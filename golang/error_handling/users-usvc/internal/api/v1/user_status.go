@@ -0,0 +1,32 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcRequest "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/request"
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// setUserStatusRequest - the request body of moving a user to another status
+type setUserStatusRequest struct {
+	Status string `json:"status" validate:"required"`
+}
+
+// setUserStatus is the API handler for moving a user to another status, e.g. to disable or re-enable the user
+func (s *APIServer) setUserStatus(w http.ResponseWriter, r *http.Request) {
+	req := &setUserStatusRequest{}
+	if err := usvcRequest.Decode(w, r, req); err != nil {
+		usvcResponse.Error(w, r, err)
+		return
+	}
+
+	user, err := s.manager.SetStatus(r.Context(), mux.Vars(r)["id"], userV1.UserStatus(req.Status))
+	if err != nil {
+		usvcResponse.Error(w, r, err)
+		return
+	}
+	usvcResponse.OK(w, newUserResponse(user))
+}
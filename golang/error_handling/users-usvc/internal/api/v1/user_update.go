@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcRequest "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/request"
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// updateUserRequest - the request body of updating a user. Only the fields present in the body are updated.
+type updateUserRequest struct {
+	FirstName *string `json:"first_name" validate:"max=100"`
+	LastName  *string `json:"last_name" validate:"max=100"`
+	Email     *string `json:"email" validate:"max=254"`
+}
+
+// updateUser is the API handler for updating a user. The `If-Match` header must be the ETag of the user the update
+// is based on, so that changes made by other requests in the meantime are not overwritten.
+func (s *APIServer) updateUser(w http.ResponseWriter, r *http.Request) {
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		usvcResponse.Error(w, r, err)
+		return
+	}
+
+	req := &updateUserRequest{}
+	if err := usvcRequest.Decode(w, r, req); err != nil {
+		usvcResponse.Error(w, r, err)
+		return
+	}
+
+	update, mask := &userV1.UserUpdate{}, []string{}
+	if req.FirstName != nil {
+		update.FirstName, mask = *req.FirstName, append(mask, userV1.UpdateMaskFirstName)
+	}
+	if req.LastName != nil {
+		update.LastName, mask = *req.LastName, append(mask, userV1.UpdateMaskLastName)
+	}
+	if req.Email != nil {
+		update.Email, mask = *req.Email, append(mask, userV1.UpdateMaskEmail)
+	}
+
+	user, err := s.manager.Update(r.Context(), mux.Vars(r)["id"], version, update, mask)
+	if err != nil {
+		usvcResponse.Error(w, r, err)
+		return
+	}
+	setETag(w, user)
+	usvcResponse.OK(w, newUserResponse(user))
+}
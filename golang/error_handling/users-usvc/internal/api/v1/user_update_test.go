@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+)
+
+func TestUpdateUserETags(t *testing.T) {
+	r := newTestRouter(newTestManager(t))
+	ID := createUser(t, r, "ann@example.com")
+	target := "/users/v1/" + ID
+
+	rec := serve(r, http.MethodGet, target, "", nil)
+	if etag := rec.Header().Get("ETag"); etag != `"1"` {
+		t.Fatalf("GET %s ETag = %s, want \"1\"", target, etag)
+	}
+
+	// The header must name a single version of the user
+	for name, ifMatch := range map[string]string{"missing": "", "weak": `W/"1"`, "any": "*", "list": `"1", "2"`, "not a version": `"one"`} {
+		t.Run(name, func(t *testing.T) {
+			headers := map[string]string{}
+			if ifMatch != "" {
+				headers["If-Match"] = ifMatch
+			}
+			rec := serve(r, http.MethodPatch, target, `{"first_name":"Anna"}`, headers)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("PATCH %s with If-Match %q = %d %s, want 400", target, ifMatch, rec.Code, rec.Body.String())
+			}
+			if env := decode(t, rec, nil); len(env.Error.InvalidParams) != 1 || env.Error.InvalidParams[0].Field != "If-Match" {
+				t.Errorf("PATCH %s with If-Match %q = %s, want If-Match invalid", target, ifMatch, rec.Body.String())
+			}
+		})
+	}
+
+	rec = serve(r, http.MethodPatch, target, `{"first_name":"Anna"}`, map[string]string{"If-Match": `"1"`})
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"2"` {
+		t.Fatalf("PATCH %s = %d %s with ETag %s, want 200 with ETag \"2\"", target, rec.Code, rec.Body.String(), rec.Header().Get("ETag"))
+	}
+
+	// The second update is based on the copy read before the first one
+	rec = serve(r, http.MethodPatch, target, `{"last_name":"Kim"}`, map[string]string{"If-Match": `"1"`})
+	if rec.Code != http.StatusConflict || problem(t, rec).Code != userV1.CodeVersionMismatch {
+		t.Errorf("PATCH %s with a stale ETag = %d %s, want 409 %s", target, rec.Code, rec.Body.String(), userV1.CodeVersionMismatch)
+	}
+
+	user := &userResponse{}
+	rec = serve(r, http.MethodGet, target, "", nil)
+	decode(t, rec, user)
+	if user.FirstName != "Anna" || user.LastName != "Lee" || rec.Header().Get("ETag") != `"2"` {
+		t.Errorf("GET %s = %s with ETag %s, want the first update only", target, rec.Body.String(), rec.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,117 @@
+package v1
+
+import (
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Diff compares two states of a struct, or pointers to it, and returns the fields which differ keyed by their names
+// in snake case. Pass nil as `before` for a created record or as `after` for a deleted one to get every field.
+// Values of sensitive fields (see `usvcErrors.IsSensitive`) are replaced with `[REDACTED]`, so the log shows that
+// a password changed but not its hash.
+func Diff(before, after interface{}) map[string]*Change {
+	b, a := structValue(before), structValue(after)
+	var t reflect.Type
+	switch {
+	case b.IsValid():
+		t = b.Type()
+	case a.IsValid():
+		t = a.Type()
+	default:
+		return nil
+	}
+
+	changes := map[string]*Change{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		var bv, av interface{}
+		if b.IsValid() {
+			bv = fieldValue(b.Field(i))
+		}
+		if a.IsValid() {
+			av = fieldValue(a.Field(i))
+		}
+		if reflect.DeepEqual(bv, av) {
+			continue
+		}
+		if usvcErrors.IsSensitive(sf.Name) {
+			if bv != nil {
+				bv = usvcErrors.Redacted
+			}
+			if av != nil {
+				av = usvcErrors.Redacted
+			}
+		}
+		changes[snakeCase(sf.Name)] = &Change{Before: bv, After: av}
+	}
+	return changes
+}
+
+// structValue returns the struct which the value points to, or an invalid value if it is nil
+func structValue(v interface{}) reflect.Value {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return reflect.Value{}
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return rv
+}
+
+// fieldValue returns the value of a field in the form stored in the log. Times are compared and stored as
+// RFC 3339 strings, and nil pointers as nil.
+func fieldValue(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return nil
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		// Named string types, e.g. statuses, are stored as plain strings
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Bool:
+		return v.Bool()
+	}
+	return v.Interface()
+}
+
+// snakeCase converts a Go field name to snake case, e.g. `PasswordHash` -> `password_hash`, `ID` -> `id`
+func snakeCase(name string) string {
+	b := &strings.Builder{}
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Start a new word at an upper case letter following a lower case one, or ending an acronym
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
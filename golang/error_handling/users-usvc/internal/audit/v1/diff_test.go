@@ -0,0 +1,92 @@
+package v1
+
+import (
+	"testing"
+	"time"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// testStatus - a named string type, like the statuses of users
+type testStatus string
+
+// testUser - a record with every kind of field which is diffed
+type testUser struct {
+	ID           string
+	FirstName    string
+	PasswordHash string
+	Status       testStatus
+	Version      int
+	DeletedAt    *time.Time
+	UpdatedAt    time.Time
+	internal     string
+}
+
+func TestDiff(t *testing.T) {
+	created := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	deleted := created.Add(time.Hour)
+	before := &testUser{ID: "u1", FirstName: "Ann", PasswordHash: "hash-1", Status: "active", Version: 1, UpdatedAt: created, internal: "a"}
+
+	t.Run("update", func(t *testing.T) {
+		after := *before
+		after.FirstName, after.PasswordHash, after.Version, after.DeletedAt, after.internal = "Anna", "hash-2", 2, &deleted, "b"
+		changes := Diff(before, after)
+
+		want := map[string]Change{
+			"first_name":    {"Ann", "Anna"},
+			"password_hash": {usvcErrors.Redacted, usvcErrors.Redacted},
+			"version":       {int64(1), int64(2)},
+			"deleted_at":    {nil, "2024-01-02T16:04:05Z"},
+		}
+		if len(changes) != len(want) {
+			t.Fatalf("Diff() = %d changes, want %d: %v", len(changes), len(want), changes)
+		}
+		for field, w := range want {
+			if c := changes[field]; c == nil || c.Before != w.Before || c.After != w.After {
+				t.Errorf("Diff()[%s] = %+v, want %+v", field, c, w)
+			}
+		}
+	})
+
+	t.Run("create", func(t *testing.T) {
+		changes := Diff(nil, before)
+		if c := changes["status"]; c == nil || c.Before != nil || c.After != "active" {
+			t.Errorf("Diff()[status] = %+v, want the status created", c)
+		}
+		if c := changes["password_hash"]; c == nil || c.Before != nil || c.After != usvcErrors.Redacted {
+			t.Errorf("Diff()[password_hash] = %+v, want the hash redacted", c)
+		}
+		// Unset fields are not changes
+		if c, ok := changes["deleted_at"]; ok {
+			t.Errorf("Diff()[deleted_at] = %+v, want no change", c)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if c := Diff(before, nil)["id"]; c == nil || c.Before != "u1" || c.After != nil {
+			t.Errorf("Diff()[id] = %+v, want the ID deleted", c)
+		}
+	})
+
+	t.Run("not structs", func(t *testing.T) {
+		if changes := Diff(nil, "u1"); changes != nil {
+			t.Errorf("Diff() = %v, want nil", changes)
+		}
+	})
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"ID":             "id",
+		"FirstName":      "first_name",
+		"PasswordHash":   "password_hash",
+		"SessionVersion": "session_version",
+		"TenantID":       "tenant_id",
+		"HTTPStatus":     "http_status",
+	}
+	for name, want := range tests {
+		if got := snakeCase(name); got != want {
+			t.Errorf("snakeCase(%s) = %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+	usvcTimeID "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/timeid"
+)
+
+// Record - an entry of the audit log. Records are append-only: stores never update or delete them.
+type Record struct {
+	// ID starts with the creation time, so records sort by time
+	ID string `json:"id"`
+	// Actor is the ID of the user who made the change. It is empty for unauthenticated requests, e.g. signing up.
+	Actor string `json:"actor"`
+	// TenantID is the tenant of the request which made the change. Stores only return the records of the tenant in
+	// the context of `Store.Query`.
+	TenantID string `json:"tenant_id"`
+	// Action is what happened, e.g. `user.update`
+	Action string `json:"action"`
+	// TargetID is the ID of the changed record
+	TargetID string `json:"target_id"`
+	// Changes are the fields which changed, keyed by field name. Sensitive values are redacted.
+	Changes map[string]*Change `json:"changes,omitempty"`
+	// RequestID is the ID of the request which made the change, see `WithRequestID`
+	RequestID string    `json:"request_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Change - the values of a field before and after a change. `Before` is nil for created records and `After` is nil
+// for deleted records.
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// NewRecord creates a record of the action taken on the target by the actor and the request in the context.
+// `before` and `after` are the states of the target, see `Diff`.
+func NewRecord(ctx context.Context, action, targetID string, before, after interface{}) (*Record, error) {
+	now := time.Now().UTC()
+	ID, err := usvcTimeID.New(now)
+	if err != nil {
+		return nil, fmt.Errorf("error generating a record ID, err: %s", err.Error())
+	}
+	return &Record{
+		ID:        ID,
+		Actor:     ActorFromContext(ctx),
+		TenantID:  usvcTenant.FromContext(ctx),
+		Action:    action,
+		TargetID:  targetID,
+		Changes:   Diff(before, after),
+		RequestID: RequestIDFromContext(ctx),
+		CreatedAt: now,
+	}, nil
+}
+
+// actorCtxKey - the context key of the actor
+type actorCtxKey struct{}
+
+// requestIDCtxKey - the context key of the request ID
+type requestIDCtxKey struct{}
+
+// WithActor returns a copy of the context carrying the ID of the user who makes the request
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorCtxKey{}, actor)
+}
+
+// ActorFromContext returns the actor put into the context by `WithActor`, or an empty string
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorCtxKey{}).(string)
+	return actor
+}
+
+// WithRequestID returns a copy of the context carrying the ID of the request
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID put into the context by `WithRequestID`, or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return requestID
+}
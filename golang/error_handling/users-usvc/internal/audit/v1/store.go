@@ -0,0 +1,66 @@
+package v1
+
+import (
+	"context"
+	"time"
+)
+
+// Default query settings
+const (
+	// DefaultQueryLimit - the number of records returned by a query if no limit is given
+	DefaultQueryLimit = 50
+	// MaxQueryLimit - the maximum number of records returned by a query
+	MaxQueryLimit = 500
+)
+
+// Query defines the conditions used to query records. Empty fields match any record.
+type Query struct {
+	Actor    string
+	Action   string
+	TargetID string
+	// Since and Until bound the creation time of records, inclusively and exclusively
+	Since time.Time
+	Until time.Time
+	// Cursor is the `NextCursor` of the previous page
+	Cursor string
+	// Limit is the page size. It is `DefaultQueryLimit` if it is not positive and at most `MaxQueryLimit`.
+	Limit int
+}
+
+// RecordList - a page of records, ordered by creation time
+type RecordList struct {
+	Records []*Record
+	// NextCursor is empty if there are no more records
+	NextCursor string
+}
+
+// Store defines the interface for persisting the audit log. Implementations only append records.
+// They should stop and return the context error once the given context is done.
+type Store interface {
+	// Append appends the record to the log
+	Append(ctx context.Context, record *Record) error
+	// Query returns a page of the records of the tenant in the context which match the query, from the oldest to
+	// the newest
+	Query(ctx context.Context, q *Query) (*RecordList, error)
+}
+
+// limit returns the page size of the query
+func (q *Query) limit() int {
+	switch {
+	case q.Limit <= 0:
+		return DefaultQueryLimit
+	case q.Limit > MaxQueryLimit:
+		return MaxQueryLimit
+	}
+	return q.Limit
+}
+
+// matches checks whether the record belongs to the tenant and matches the conditions of the query, except the cursor
+func (q *Query) matches(tenantID string, r *Record) bool {
+	return r.TenantID == tenantID &&
+		(q.Actor == "" || r.Actor == q.Actor) &&
+		(q.Action == "" || r.Action == q.Action) &&
+		(q.TargetID == "" || r.TargetID == q.TargetID) &&
+		(q.Since.IsZero() || !r.CreatedAt.Before(q.Since)) &&
+		(q.Until.IsZero() || r.CreatedAt.Before(q.Until))
+}
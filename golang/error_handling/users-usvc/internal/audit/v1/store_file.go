@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// fileStore is the implementation of Store interface which appends records to a JSON-lines file, one record per line.
+// Queries scan the whole file, so it suits local development and small deployments; use the SQL store otherwise.
+type fileStore struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFileStore creates an instance of Store which appends records to the file at the given path
+func NewFileStore(path string) (Store, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the audit log %s, err: %s", path, err.Error())
+	}
+	return &fileStore{
+		path: path,
+		f:    f,
+	}, nil
+}
+
+// Append - the implementation of the `Append` method
+func (s *fileStore) Append(ctx context.Context, record *Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// One write per record so that lines of concurrent writers never interleave
+	if _, err := s.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+// Query - the implementation of the `Query` method
+func (s *fileStore) Query(ctx context.Context, q *Query) (*RecordList, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tenantID := usvcTenant.FromContext(ctx)
+	limit := q.limit()
+	list := &RecordList{Records: []*Record{}}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), r); err != nil {
+			return nil, fmt.Errorf("error decoding the audit log %s, err: %s", s.path, err.Error())
+		}
+		if r.ID <= q.Cursor || !q.matches(tenantID, r) {
+			continue
+		}
+		if len(list.Records) == limit {
+			list.NextCursor = list.Records[limit-1].ID
+			break
+		}
+		list.Records = append(list.Records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,144 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	usvcSQLSchema "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/sqlschema"
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// sqlSchema - statements for creating the tables used by the SQL store.
+// They only use the SQL subset shared by SQLite and MySQL.
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS audit_records (
+		id         VARCHAR(64)  NOT NULL PRIMARY KEY,
+		tenant_id  VARCHAR(64)  NOT NULL DEFAULT 'default',
+		actor      VARCHAR(64)  NOT NULL,
+		action     VARCHAR(64)  NOT NULL,
+		target_id  VARCHAR(64)  NOT NULL,
+		changes    TEXT         NOT NULL,
+		request_id VARCHAR(128) NOT NULL,
+		created_at DATETIME     NOT NULL
+	)`,
+}
+
+// MigrateSQLSchema creates the tables used by the SQL store if they do not exist, and adds the tenant to the
+// tables created before it was recorded. Their rows are moved to the default tenant.
+func MigrateSQLSchema(ctx context.Context, db *sql.DB) error {
+	for _, stmt := range sqlSchema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("error migrating the audit schema, err: %s", err.Error())
+		}
+	}
+	if err := usvcSQLSchema.AddColumn(ctx, db, "audit_records", "tenant_id", `VARCHAR(64) NOT NULL DEFAULT 'default'`); err != nil {
+		return fmt.Errorf("error migrating the audit schema, err: %s", err.Error())
+	}
+	return nil
+}
+
+// sqlStore is the implementation of Store interface backed by `database/sql`.
+// It works with both SQLite and MySQL; MySQL DSNs need `parseTime=true`. Grant the database user of the service
+// INSERT and SELECT only on `audit_records` to make the log append-only in the database as well.
+type sqlStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore creates an instance of Store which stores records in the given database
+func NewSQLStore(db *sql.DB) Store {
+	return &sqlStore{
+		db: db,
+	}
+}
+
+// Append - the implementation of the `Append` method
+func (s *sqlStore) Append(ctx context.Context, record *Record) error {
+	return appendRecord(ctx, s.db, record)
+}
+
+// AppendTx appends the record in the transaction, so that it is stored if and only if the transaction commits.
+// Repositories which share the database with the SQL store call it to record their changes along with them.
+func AppendTx(ctx context.Context, tx *sql.Tx, record *Record) error {
+	return appendRecord(ctx, tx, record)
+}
+
+// execer - the part of `*sql.DB` and `*sql.Tx` which appends records
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// appendRecord inserts the record through the database or the transaction
+func appendRecord(ctx context.Context, db execer, record *Record) error {
+	changes, err := json.Marshal(record.Changes)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO audit_records (id, tenant_id, actor, action, target_id, changes, request_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.ID, record.TenantID, record.Actor, record.Action, record.TargetID, string(changes), record.RequestID, record.CreatedAt,
+	)
+	return err
+}
+
+// Query - the implementation of the `Query` method
+func (s *sqlStore) Query(ctx context.Context, q *Query) (*RecordList, error) {
+	conds := []string{`tenant_id = ?`, `id > ?`}
+	args := []interface{}{usvcTenant.FromContext(ctx), q.Cursor}
+	if q.Actor != "" {
+		conds = append(conds, `actor = ?`)
+		args = append(args, q.Actor)
+	}
+	if q.Action != "" {
+		conds = append(conds, `action = ?`)
+		args = append(args, q.Action)
+	}
+	if q.TargetID != "" {
+		conds = append(conds, `target_id = ?`)
+		args = append(args, q.TargetID)
+	}
+	if !q.Since.IsZero() {
+		conds = append(conds, `created_at >= ?`)
+		args = append(args, q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		conds = append(conds, `created_at < ?`)
+		args = append(args, q.Until.UTC())
+	}
+	// Fetch one more record to find out whether there is a next page
+	limit := q.limit()
+	args = append(args, limit+1)
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, tenant_id, actor, action, target_id, changes, request_id, created_at FROM audit_records WHERE `+
+			strings.Join(conds, ` AND `)+` ORDER BY id LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := &RecordList{Records: []*Record{}}
+	for rows.Next() {
+		r := &Record{}
+		var changes string
+		if err := rows.Scan(&r.ID, &r.TenantID, &r.Actor, &r.Action, &r.TargetID, &changes, &r.RequestID, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(changes), &r.Changes); err != nil {
+			return nil, fmt.Errorf("error decoding the changes of audit record %s, err: %s", r.ID, err.Error())
+		}
+		list.Records = append(list.Records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(list.Records) > limit {
+		list.Records = list.Records[:limit]
+		list.NextCursor = list.Records[limit-1].ID
+	}
+	return list, nil
+}
@@ -0,0 +1,193 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// testStores returns every Store implementation, each backed by an empty log
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening the database, err: %s", err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+	// Every connection to `:memory:` opens a database of its own
+	db.SetMaxOpenConns(1)
+	if err := MigrateSQLSchema(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	file, err := NewFileStore(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]Store{
+		"file": file,
+		"sql":  NewSQLStore(db),
+	}
+}
+
+// mustAppend appends a record of the action on the target, made by the actor in the tenant of the context
+func mustAppend(t *testing.T, ctx context.Context, s Store, actor, action, targetID string) *Record {
+	t.Helper()
+	record, err := NewRecord(WithActor(ctx, actor), action, targetID, nil, &struct{ Name string }{Name: targetID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(ctx, record); err != nil {
+		t.Fatalf("Append() err: %v", err)
+	}
+	return record
+}
+
+// recordIDs returns the IDs of the records
+func recordIDs(records []*Record) []string {
+	IDs := make([]string, 0, len(records))
+	for _, r := range records {
+		IDs = append(IDs, r.ID)
+	}
+	return IDs
+}
+
+func TestNewRecord(t *testing.T) {
+	ctx := WithRequestID(WithActor(usvcTenant.WithID(context.Background(), "acme"), "admin-1"), "req-1")
+	record, err := NewRecord(ctx, "user.update", "u1", nil, nil)
+	if err != nil {
+		t.Fatalf("NewRecord() err: %v", err)
+	}
+	if record.ID == "" || record.Actor != "admin-1" || record.TenantID != "acme" || record.RequestID != "req-1" || record.TargetID != "u1" {
+		t.Errorf("NewRecord() = %+v, want the actor, tenant and request of the context", record)
+	}
+}
+
+func TestStoreAppendAndQuery(t *testing.T) {
+	ctx := context.Background()
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			created := mustAppend(t, ctx, s, "", "user.create", "u1")
+			updated := mustAppend(t, ctx, s, "admin-1", "user.update", "u1")
+			other := mustAppend(t, ctx, s, "admin-1", "user.update", "u2")
+
+			list, err := s.Query(ctx, &Query{TargetID: "u1"})
+			if err != nil {
+				t.Fatalf("Query() err: %v", err)
+			}
+			if len(list.Records) != 2 || list.Records[0].ID != created.ID || list.Records[1].ID != updated.ID || list.NextCursor != "" {
+				t.Fatalf("Query() = %v, want the records of u1 from the oldest", recordIDs(list.Records))
+			}
+			got := list.Records[1]
+			if got.Actor != "admin-1" || got.Action != "user.update" || got.TenantID != usvcTenant.Default || !got.CreatedAt.Equal(updated.CreatedAt) {
+				t.Errorf("Query() record = %+v, want %+v", got, updated)
+			}
+			if c := got.Changes["name"]; c == nil || c.After != "u1" {
+				t.Errorf("Query() record changes = %v, want the name set", got.Changes)
+			}
+
+			tests := []struct {
+				name  string
+				query *Query
+				want  []string
+			}{
+				{"actor", &Query{Actor: "admin-1"}, []string{updated.ID, other.ID}},
+				{"action", &Query{Action: "user.create"}, []string{created.ID}},
+				{"since", &Query{Since: other.CreatedAt}, []string{other.ID}},
+				{"until", &Query{Until: updated.CreatedAt}, []string{created.ID}},
+				{"none", &Query{Actor: "nobody"}, []string{}},
+			}
+			for _, tt := range tests {
+				list, err := s.Query(ctx, tt.query)
+				if err != nil || len(list.Records) != len(tt.want) {
+					t.Errorf("Query() by %s = %v, %v, want %v", tt.name, recordIDs(list.Records), err, tt.want)
+					continue
+				}
+				for i, ID := range recordIDs(list.Records) {
+					if ID != tt.want[i] {
+						t.Errorf("Query() by %s = %v, want %v", tt.name, recordIDs(list.Records), tt.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestStoreQueryPagination(t *testing.T) {
+	ctx := context.Background()
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			var want []string
+			for i := 0; i < 5; i++ {
+				want = append(want, mustAppend(t, ctx, s, "admin-1", "user.update", "u1").ID)
+			}
+
+			var got []string
+			q := &Query{Limit: 2}
+			for pages := 0; ; pages++ {
+				if pages > 3 {
+					t.Fatal("Query() returned more than 3 pages")
+				}
+				list, err := s.Query(ctx, q)
+				if err != nil {
+					t.Fatalf("Query() err: %v", err)
+				}
+				got = append(got, recordIDs(list.Records)...)
+				if list.NextCursor == "" {
+					break
+				}
+				q.Cursor = list.NextCursor
+			}
+			if len(got) != len(want) {
+				t.Fatalf("Query() returned %v over the pages, want %v", got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("Query() returned %v over the pages, want %v", got, want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestStoreQueryTenant(t *testing.T) {
+	ctx := context.Background()
+	acme := usvcTenant.WithID(ctx, "acme")
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			mustAppend(t, ctx, s, "admin-1", "user.update", "u1")
+			acmeRecord := mustAppend(t, acme, s, "admin-1", "user.update", "u2")
+
+			list, err := s.Query(acme, &Query{})
+			if err != nil || len(list.Records) != 1 || list.Records[0].ID != acmeRecord.ID {
+				t.Errorf("Query() in acme = %v, %v, want only the record of acme", recordIDs(list.Records), err)
+			}
+			if list, _ := s.Query(ctx, &Query{TargetID: "u2"}); len(list.Records) != 0 {
+				t.Errorf("Query() in the default tenant returned the records of acme: %v", recordIDs(list.Records))
+			}
+		})
+	}
+}
+
+func TestStoreStopsOnDoneContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			record, err := NewRecord(ctx, "user.update", "u1", nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Append(ctx, record); !errors.Is(err, context.Canceled) {
+				t.Errorf("Append() err: %v, want context.Canceled", err)
+			}
+		})
+	}
+}
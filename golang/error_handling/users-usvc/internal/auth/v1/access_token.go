@@ -0,0 +1,83 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// Claims - the claims carried by access tokens. The subject is the user ID.
+type Claims struct {
+	jwt.RegisteredClaims
+	// TenantID is the tenant of the user. Requests with the token are scoped to this tenant, see `Middleware`.
+	TenantID string `json:"tid"`
+	Email    string `json:"email"`
+	// SessionVersion is the session version of the user when the session started
+	SessionVersion int `json:"sv"`
+}
+
+// issueAccessToken signs an access token for the user
+func (m *manager) issueAccessToken(user *userV1.User, now time.Time) (string, error) {
+	key := m.keys.SigningKey()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.accessTokenTTL)),
+		},
+		TenantID:       user.TenantID,
+		Email:          user.Email,
+		SessionVersion: user.SessionVersion,
+	})
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// VerifyAccessToken - the implementation of the `VerifyAccessToken` method
+func (m *manager) VerifyAccessToken(ctx context.Context, accessToken string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(accessToken, claims, m.publicKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, newCodedError(usvcErrors.ErrTypeUnauthorized, CodeInvalidAccessToken, nil, "The access token is invalid: %s.", err.Error())
+	}
+
+	// Tokens stay valid until they expire, so check that the session has not ended since the token was issued
+	user, err := m.users.Get(usvcTenant.WithID(ctx, claims.TenantID), claims.Subject)
+	if errors.Is(err, usvcErrors.ErrNotFound) {
+		return nil, newCodedError(usvcErrors.ErrTypeUnauthorized, CodeInvalidAccessToken, nil, "The access token is invalid as the user has been deleted.")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.SessionVersion != claims.SessionVersion {
+		return nil, newCodedError(usvcErrors.ErrTypeUnauthorized, CodeSessionEnded, nil, "The session has ended as the password has changed, please log in again.")
+	}
+	if user.Status != userV1.UserStatusActive {
+		return nil, newCodedError(usvcErrors.ErrTypeForbidden, userV1.CodeUserNotActive, map[string]string{"status": string(user.Status)},
+			"The user is %s and cannot sign in.", user.Status)
+	}
+	return claims, nil
+}
+
+// publicKey returns the public key which verifies the token, according to its `kid` header
+func (m *manager) publicKey(token *jwt.Token) (interface{}, error) {
+	ID, _ := token.Header["kid"].(string)
+	key, ok := m.keys.PublicKey(ID)
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", ID)
+	}
+	return key, nil
+}
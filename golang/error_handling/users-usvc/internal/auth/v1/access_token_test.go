@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyAccessTokenInvalidTokens(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUsers(t)
+	newActiveUser(t, ctx, users, "ann@example.com")
+	keys := newTestKeys(t)
+	m := NewManager(users, NewMemoryRepository(), keys)
+	token := mustLogin(t, ctx, m, "ann@example.com").AccessToken
+
+	tests := map[string]string{
+		"empty":     "",
+		"malformed": "not-a-token",
+		"tampered":  token[:len(token)-2] + "xx",
+		"expired":   mustLogin(t, ctx, NewManager(users, NewMemoryRepository(), keys, WithAccessTokenTTL(-time.Minute)), "ann@example.com").AccessToken,
+		"other issuer": mustLogin(t, ctx, NewManager(users, NewMemoryRepository(), keys, WithIssuer("another-usvc")),
+			"ann@example.com").AccessToken,
+		"unknown key": mustLogin(t, ctx, NewManager(users, NewMemoryRepository(), newTestKeys(t)), "ann@example.com").AccessToken,
+		"unsigned":    strings.Join(strings.Split(token, ".")[:2], ".") + ".",
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := m.VerifyAccessToken(ctx, token); errCode(err) != CodeInvalidAccessToken {
+				t.Errorf("VerifyAccessToken() err: %v, want %s", err, CodeInvalidAccessToken)
+			}
+		})
+	}
+}
+
+func TestVerifyAccessTokenAfterKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUsers(t)
+	newActiveUser(t, ctx, users, "ann@example.com")
+	keys := newTestKeys(t)
+	m := NewManager(users, NewMemoryRepository(), keys)
+	token := mustLogin(t, ctx, m, "ann@example.com").AccessToken
+
+	// Tokens signed by the previous key stay valid until the key is pruned
+	if err := keys.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.VerifyAccessToken(ctx, token); err != nil {
+		t.Errorf("VerifyAccessToken() after a rotation err: %v", err)
+	}
+	if err := keys.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.VerifyAccessToken(ctx, token); errCode(err) != CodeInvalidAccessToken {
+		t.Errorf("VerifyAccessToken() after the key is pruned err: %v, want %s", err, CodeInvalidAccessToken)
+	}
+}
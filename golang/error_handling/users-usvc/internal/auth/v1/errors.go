@@ -0,0 +1,28 @@
+package v1
+
+import (
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// Error codes which are more specific than error types
+const (
+	// CodeInvalidAccessToken - the access token is missing, malformed, expired or not signed by a known key
+	CodeInvalidAccessToken = "invalid_access_token"
+	// CodeInvalidRefreshToken - the refresh token does not exist, has expired or has been revoked
+	CodeInvalidRefreshToken = "invalid_refresh_token"
+	// CodeSessionEnded - the session has ended because the password of the user changed
+	CodeSessionEnded = "session_ended"
+)
+
+// newCodedError returns an error with given error type, code and optional metadata
+func newCodedError(errType usvcErrors.ErrType, code string, metadata map[string]string, format string, a ...interface{}) usvcErrors.Error {
+	usvcErrors.Helper()
+	return usvcErrors.NewCoded(errType, code, metadata, format, a...)
+}
+
+// newInternalError returns an internal server error caused by a downstream error, or a timeout or canceled error
+// if the downstream error is caused by the context of the request
+func newInternalError(cause error, format string, a ...interface{}) usvcErrors.Error {
+	usvcErrors.Helper()
+	return usvcErrors.WrapInternal(cause, format, a...)
+}
@@ -0,0 +1,179 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcOpenAPI "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/openapi"
+	usvcRequest "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/request"
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// claimsCtxKey - the context key of the claims of the authenticated user
+type claimsCtxKey struct{}
+
+// ClaimsFromContext returns the claims put into the request context by the middleware returned by `Middleware`
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsCtxKey{}).(*Claims)
+	return claims, ok
+}
+
+// RegisterRoutes registers the auth endpoints on the router:
+//
+//	POST /auth/v1/login        - exchange an email and a password for tokens
+//	POST /auth/v1/refresh      - exchange a refresh token for new tokens
+//	POST /auth/v1/logout       - revoke a refresh token
+//	GET  /auth/v1/jwks.json    - the public keys which verify access tokens
+func RegisterRoutes(r *mux.Router, m Manager) {
+	s := r.PathPrefix("/auth/v1").Subrouter()
+	s.HandleFunc("/login", loginHandler(m)).Methods(http.MethodPost)
+	s.HandleFunc("/refresh", refreshHandler(m)).Methods(http.MethodPost)
+	s.HandleFunc("/logout", logoutHandler(m)).Methods(http.MethodPost)
+	s.HandleFunc("/jwks.json", jwksHandler(m)).Methods(http.MethodGet)
+}
+
+// DescribeRoutes adds the endpoints registered by `RegisterRoutes` to the OpenAPI document
+func DescribeRoutes(d *usvcOpenAPI.Document) {
+	tags := []string{"auth"}
+	tokens := &usvcOpenAPI.Response{Description: "The issued tokens", Content: usvcOpenAPI.JSONContent(usvcOpenAPI.SchemaOf(Tokens{}))}
+
+	d.Add(http.MethodPost, "/auth/v1/login", &usvcOpenAPI.Operation{
+		OperationID: "login",
+		Summary:     "Exchange an email and a password for tokens",
+		Tags:        tags,
+		Parameters: []*usvcOpenAPI.Parameter{{
+			Name: usvcTenant.Header, In: "header", Schema: usvcOpenAPI.String("The tenant of the user, the default tenant if it is not given"),
+		}},
+		RequestBody: usvcOpenAPI.JSONBody(loginRequest{}),
+		Responses:   map[string]*usvcOpenAPI.Response{"200": tokens},
+	}, usvcErrors.ErrTypeBadRequest, usvcErrors.ErrTypeUnauthorized, usvcErrors.ErrTypeForbidden, usvcErrors.ErrTypeInternalServerErr)
+	d.Add(http.MethodPost, "/auth/v1/refresh", &usvcOpenAPI.Operation{
+		OperationID: "refreshTokens",
+		Summary:     "Exchange a refresh token for new tokens",
+		Tags:        tags,
+		RequestBody: usvcOpenAPI.JSONBody(refreshTokenRequest{}),
+		Responses:   map[string]*usvcOpenAPI.Response{"200": tokens},
+	}, usvcErrors.ErrTypeBadRequest, usvcErrors.ErrTypeUnauthorized, usvcErrors.ErrTypeInternalServerErr)
+	d.Add(http.MethodPost, "/auth/v1/logout", &usvcOpenAPI.Operation{
+		OperationID: "logout",
+		Summary:     "Revoke a refresh token",
+		Tags:        tags,
+		RequestBody: usvcOpenAPI.JSONBody(refreshTokenRequest{}),
+		Responses:   map[string]*usvcOpenAPI.Response{"204": {Description: "The refresh token is revoked"}},
+	}, usvcErrors.ErrTypeBadRequest, usvcErrors.ErrTypeInternalServerErr)
+	d.Add(http.MethodGet, "/auth/v1/jwks.json", &usvcOpenAPI.Operation{
+		OperationID: "getJWKSet",
+		Summary:     "Get the public keys which verify access tokens",
+		Tags:        tags,
+		Responses: map[string]*usvcOpenAPI.Response{
+			"200": {Description: "The JWK set", Content: usvcOpenAPI.JSONContent(usvcOpenAPI.SchemaOf(JWKSet{}))},
+		},
+	})
+}
+
+// Middleware returns the middleware which verifies the bearer access token of requests and puts its claims into
+// the request context. Requests without a valid token are rejected with 401. The request context is scoped to the
+// tenant of the token whatever tenant the request names, so users cannot reach the records of other tenants.
+func Middleware(m Manager) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !strings.EqualFold(scheme, "Bearer") || token == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="users-usvc"`)
+				usvcResponse.Error(w, r, newCodedError(usvcErrors.ErrTypeUnauthorized, CodeInvalidAccessToken, nil, "The bearer access token is missing."))
+				return
+			}
+
+			claims, err := m.VerifyAccessToken(r.Context(), token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="users-usvc", error="invalid_token"`)
+				usvcResponse.Error(w, r, err)
+				return
+			}
+			ctx := usvcTenant.WithID(r.Context(), claims.TenantID)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, claimsCtxKey{}, claims)))
+		})
+	}
+}
+
+// loginRequest - the request body of logging in
+type loginRequest struct {
+	Email    string `json:"email" validate:"required,max=254"`
+	Password string `json:"password" validate:"required,max=256"`
+}
+
+// refreshTokenRequest - the request body of refreshing tokens and logging out
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=256"`
+}
+
+// loginHandler is the API handler for logging in
+func loginHandler(m Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &loginRequest{}
+		if err := usvcRequest.Decode(w, r, req); err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+
+		tokens, err := m.Login(r.Context(), req.Email, req.Password)
+		if err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+		// Token responses must not be cached
+		w.Header().Set("Cache-Control", "no-store")
+		usvcResponse.JSON(w, http.StatusOK, tokens)
+	}
+}
+
+// refreshHandler is the API handler for refreshing tokens
+func refreshHandler(m Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &refreshTokenRequest{}
+		if err := usvcRequest.Decode(w, r, req); err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+
+		tokens, err := m.Refresh(r.Context(), req.RefreshToken)
+		if err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+		// Token responses must not be cached
+		w.Header().Set("Cache-Control", "no-store")
+		usvcResponse.JSON(w, http.StatusOK, tokens)
+	}
+}
+
+// logoutHandler is the API handler for logging out
+func logoutHandler(m Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &refreshTokenRequest{}
+		if err := usvcRequest.Decode(w, r, req); err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+
+		if err := m.Logout(r.Context(), req.RefreshToken); err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// jwksHandler is the API handler which serves the public keys
+func jwksHandler(m Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Verifiers cache the keys; a short max-age lets them pick up rotated keys quickly
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		usvcResponse.JSON(w, http.StatusOK, m.JWKSet())
+	}
+}
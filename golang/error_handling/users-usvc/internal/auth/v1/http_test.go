@@ -0,0 +1,151 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// newTestRouter registers the auth endpoints, plus `GET /me` behind the middleware which writes the subject of
+// the request
+func newTestRouter(m Manager) *mux.Router {
+	r := mux.NewRouter()
+	RegisterRoutes(r, m)
+	me := r.Path("/me").Subrouter()
+	me.Use(Middleware(m))
+	me.Methods(http.MethodGet).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := ClaimsFromContext(r.Context())
+		w.Write([]byte(claims.Subject))
+	})
+	return r
+}
+
+// serve sends a request with an optional JSON body and headers to the router
+func serve(r http.Handler, method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+// errorCode returns the code of the error in the envelope of the body
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	env := &usvcResponse.Envelope{}
+	if err := json.Unmarshal(rec.Body.Bytes(), env); err != nil || env.Error == nil {
+		t.Fatalf("the body %s is not an error envelope, err: %v", rec.Body.String(), err)
+	}
+	return env.Error.Code
+}
+
+func TestTokenHandlers(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUsers(t)
+	newActiveUser(t, ctx, users, "ann@example.com")
+	r := newTestRouter(NewManager(users, NewMemoryRepository(), newTestKeys(t)))
+
+	rec := serve(r, http.MethodPost, "/auth/v1/login", `{"email":"ann@example.com","password":"`+testPassword+`"}`, nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("POST /auth/v1/login = %d %s, want 200 and no-store", rec.Code, rec.Body.String())
+	}
+	tokens := &Tokens{}
+	if err := json.Unmarshal(rec.Body.Bytes(), tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = serve(r, http.MethodPost, "/auth/v1/refresh", `{"refresh_token":"`+tokens.RefreshToken+`"}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /auth/v1/refresh = %d %s, want 200", rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec = serve(r, http.MethodPost, "/auth/v1/logout", `{"refresh_token":"`+tokens.RefreshToken+`"}`, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("POST /auth/v1/logout = %d %s, want 204", rec.Code, rec.Body.String())
+	}
+	rec = serve(r, http.MethodPost, "/auth/v1/refresh", `{"refresh_token":"`+tokens.RefreshToken+`"}`, nil)
+	if rec.Code != http.StatusUnauthorized || errorCode(t, rec) != CodeInvalidRefreshToken {
+		t.Errorf("POST /auth/v1/refresh after logging out = %d %s, want 401 %s", rec.Code, rec.Body.String(), CodeInvalidRefreshToken)
+	}
+
+	tests := []struct {
+		name, body string
+		wantStatus int
+	}{
+		{"wrong password", `{"email":"ann@example.com","password":"Wrong-passw0rd"}`, http.StatusUnauthorized},
+		{"missing password", `{"email":"ann@example.com"}`, http.StatusBadRequest},
+		{"malformed body", `{"email":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(r, http.MethodPost, "/auth/v1/login", tt.body, nil); rec.Code != tt.wantStatus {
+				t.Errorf("POST /auth/v1/login = %d %s, want %d", rec.Code, rec.Body.String(), tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestJWKSHandler(t *testing.T) {
+	keys := newTestKeys(t)
+	r := newTestRouter(NewManager(newTestUsers(t), NewMemoryRepository(), keys))
+
+	rec := serve(r, http.MethodGet, "/auth/v1/jwks.json", "", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") == "" {
+		t.Fatalf("GET /auth/v1/jwks.json = %d %s, want 200 and cacheable", rec.Code, rec.Body.String())
+	}
+	set := &JWKSet{}
+	if err := json.Unmarshal(rec.Body.Bytes(), set); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 1 || set.Keys[0].KeyID != keys.SigningKey().ID {
+		t.Errorf("GET /auth/v1/jwks.json = %s, want the key %s", rec.Body.String(), keys.SigningKey().ID)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUsers(t)
+	ID := newActiveUser(t, ctx, users, "ann@example.com")
+	m := NewManager(users, NewMemoryRepository(), newTestKeys(t))
+	r := newTestRouter(m)
+	token := mustLogin(t, ctx, m, "ann@example.com").AccessToken
+
+	rec := serve(r, http.MethodGet, "/me", "", map[string]string{"Authorization": "Bearer " + token})
+	if rec.Code != http.StatusOK || rec.Body.String() != ID {
+		t.Errorf("GET /me = %d %s, want 200 %s", rec.Code, rec.Body.String(), ID)
+	}
+
+	tests := []struct {
+		name, authorization string
+		wantChallenge       string
+	}{
+		{"missing token", "", `Bearer realm="users-usvc"`},
+		{"other scheme", "Basic " + token, `Bearer realm="users-usvc"`},
+		{"invalid token", "Bearer not-a-token", `Bearer realm="users-usvc", error="invalid_token"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(r, http.MethodGet, "/me", "", map[string]string{"Authorization": tt.authorization})
+			if rec.Code != http.StatusUnauthorized || errorCode(t, rec) != CodeInvalidAccessToken {
+				t.Errorf("GET /me = %d %s, want 401 %s", rec.Code, rec.Body.String(), CodeInvalidAccessToken)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+				t.Errorf("WWW-Authenticate = %s, want %s", got, tt.wantChallenge)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package v1
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	usvcTimeID "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/timeid"
+)
+
+// DefaultMaxKeys - how many signing keys are kept by default, including the current one
+const DefaultMaxKeys = 3
+
+// keyFileExt - the extension of key files
+const keyFileExt = ".pem"
+
+// SigningKey - a key used to sign access tokens
+type SigningKey struct {
+	// ID is sent in the `kid` header of tokens so that verifiers can pick the right public key
+	ID         string
+	PrivateKey ed25519.PrivateKey
+}
+
+// JWK - a public key in the JSON Web Key format defined in RFC 7517 and RFC 8037
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+}
+
+// JWKSet - a set of public keys served to the services which verify access tokens
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyStore defines the interface for the keys used to sign and verify access tokens.
+// The newest key signs new tokens, while older keys are kept to verify the tokens they signed until they expire.
+type KeyStore interface {
+	// SigningKey returns the key which signs new tokens
+	SigningKey() *SigningKey
+	// PublicKey returns the public key with the given ID
+	PublicKey(ID string) (ed25519.PublicKey, bool)
+	// JWKSet returns the public keys in the JWK set format
+	JWKSet() *JWKSet
+	// Rotate generates a new signing key and removes the oldest keys beyond the configured number of keys
+	Rotate() error
+	// Reload reloads the keys from the storage, e.g. after another instance of the service rotated them
+	Reload() error
+}
+
+// fileKeyStore is the implementation of KeyStore interface which stores Ed25519 keys as PKCS #8 PEM files
+// in a local directory. Key IDs are the file names, which start with the creation time, so the newest key sorts last.
+type fileKeyStore struct {
+	dir     string
+	maxKeys int
+
+	mu   sync.RWMutex
+	keys []*SigningKey // ordered from the oldest to the newest
+}
+
+// NewFileKeyStore creates an instance of KeyStore which keeps at most `maxKeys` keys in the given directory.
+// A key is generated if the directory has none. Keep enough keys that the oldest one outlives the access tokens
+// it signed, i.e. `maxKeys - 1` rotation intervals should be longer than the access token TTL.
+func NewFileKeyStore(dir string, maxKeys int) (KeyStore, error) {
+	if maxKeys < 2 {
+		return nil, fmt.Errorf("at least 2 keys should be kept to rotate keys, got %d", maxKeys)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating the key directory %s, err: %s", dir, err.Error())
+	}
+
+	s := &fileKeyStore{
+		dir:     dir,
+		maxKeys: maxKeys,
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	if len(s.keys) == 0 {
+		if err := s.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// SigningKey - the implementation of the `SigningKey` method
+func (s *fileKeyStore) SigningKey() *SigningKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.keys[len(s.keys)-1]
+}
+
+// PublicKey - the implementation of the `PublicKey` method
+func (s *fileKeyStore) PublicKey(ID string) (ed25519.PublicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.keys {
+		if k.ID == ID {
+			return k.PrivateKey.Public().(ed25519.PublicKey), true
+		}
+	}
+	return nil, false
+}
+
+// JWKSet - the implementation of the `JWKSet` method
+func (s *fileKeyStore) JWKSet() *JWKSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := &JWKSet{Keys: make([]JWK, 0, len(s.keys))}
+	for _, k := range s.keys {
+		set.Keys = append(set.Keys, JWK{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(k.PrivateKey.Public().(ed25519.PublicKey)),
+			KeyID:     k.ID,
+			Algorithm: "EdDSA",
+			Use:       "sig",
+		})
+	}
+	return set
+}
+
+// Rotate - the implementation of the `Rotate` method
+func (s *fileKeyStore) Rotate() error {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("error generating a signing key, err: %s", err.Error())
+	}
+	ID, err := usvcTimeID.New(time.Now())
+	if err != nil {
+		return fmt.Errorf("error generating a key ID, err: %s", err.Error())
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return fmt.Errorf("error encoding the signing key %s, err: %s", ID, err.Error())
+	}
+	// Write to a temporary file first so that other instances never load a partially written key
+	tmp := filepath.Join(s.dir, "."+ID+".tmp")
+	if err := os.WriteFile(tmp, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return fmt.Errorf("error writing the signing key %s, err: %s", ID, err.Error())
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, ID+keyFileExt)); err != nil {
+		return fmt.Errorf("error writing the signing key %s, err: %s", ID, err.Error())
+	}
+
+	if err := s.Reload(); err != nil {
+		return err
+	}
+	return s.prune()
+}
+
+// Reload - the implementation of the `Reload` method
+func (s *fileKeyStore) Reload() error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*"+keyFileExt))
+	if err != nil {
+		return fmt.Errorf("error listing the keys in %s, err: %s", s.dir, err.Error())
+	}
+	sort.Strings(paths)
+
+	keys := make([]*SigningKey, 0, len(paths))
+	for _, path := range paths {
+		k, err := readKeyFile(path)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(keys) > 0 {
+		s.keys = keys
+	}
+	return nil
+}
+
+// prune removes the oldest keys beyond `maxKeys`
+func (s *fileKeyStore) prune() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.keys) > s.maxKeys {
+		path := filepath.Join(s.dir, s.keys[0].ID+keyFileExt)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing the key %s, err: %s", path, err.Error())
+		}
+		s.keys = s.keys[1:]
+	}
+	return nil
+}
+
+// readKeyFile reads an Ed25519 private key from a PKCS #8 PEM file
+func readKeyFile(path string) (*SigningKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the key %s, err: %s", path, err.Error())
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("the key %s is not a PEM encoded private key", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the key %s, err: %s", path, err.Error())
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the key %s is not an Ed25519 key", path)
+	}
+	return &SigningKey{
+		ID:         strings.TrimSuffix(filepath.Base(path), keyFileExt),
+		PrivateKey: priv,
+	}, nil
+}
@@ -0,0 +1,80 @@
+package v1
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewFileKeyStore(t *testing.T) {
+	if _, err := NewFileKeyStore(t.TempDir(), 1); err == nil {
+		t.Error("NewFileKeyStore() keeping 1 key err: nil, want an error")
+	}
+
+	dir := t.TempDir()
+	keys, err := NewFileKeyStore(dir, 2)
+	if err != nil {
+		t.Fatalf("NewFileKeyStore() err: %v", err)
+	}
+	// Another instance loads the key generated by the first one
+	other, err := NewFileKeyStore(dir, 2)
+	if err != nil {
+		t.Fatalf("NewFileKeyStore() err: %v", err)
+	}
+	if other.SigningKey().ID != keys.SigningKey().ID {
+		t.Errorf("SigningKey() of another instance = %s, want %s", other.SigningKey().ID, keys.SigningKey().ID)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "broken"+keyFileExt), []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileKeyStore(dir, 2); err == nil {
+		t.Error("NewFileKeyStore() with a broken key file err: nil, want an error")
+	}
+}
+
+func TestFileKeyStoreRotate(t *testing.T) {
+	dir := t.TempDir()
+	keys, err := NewFileKeyStore(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewFileKeyStore(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := keys.SigningKey().ID
+
+	if err := keys.Rotate(); err != nil {
+		t.Fatalf("Rotate() err: %v", err)
+	}
+	second := keys.SigningKey().ID
+	if second <= first {
+		t.Errorf("SigningKey() after Rotate() = %s, want a key newer than %s", second, first)
+	}
+	if _, ok := keys.PublicKey(first); !ok {
+		t.Errorf("PublicKey(%s) of the previous key is missing", first)
+	}
+	if set := keys.JWKSet(); len(set.Keys) != 2 {
+		t.Errorf("JWKSet() after Rotate() returned %d keys, want 2", len(set.Keys))
+	}
+
+	// The oldest key beyond the limit is removed from the store and the directory
+	if err := keys.Rotate(); err != nil {
+		t.Fatalf("Rotate() err: %v", err)
+	}
+	if _, ok := keys.PublicKey(first); ok {
+		t.Errorf("PublicKey(%s) of a pruned key is present", first)
+	}
+	if _, err := os.Stat(filepath.Join(dir, first+keyFileExt)); !os.IsNotExist(err) {
+		t.Errorf("the file of the pruned key %s still exists, err: %v", first, err)
+	}
+
+	// Other instances pick up the rotated keys on Reload()
+	if err := other.Reload(); err != nil {
+		t.Fatalf("Reload() err: %v", err)
+	}
+	if other.SigningKey().ID != keys.SigningKey().ID {
+		t.Errorf("SigningKey() after Reload() = %s, want %s", other.SigningKey().ID, keys.SigningKey().ID)
+	}
+}
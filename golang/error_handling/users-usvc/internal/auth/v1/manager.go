@@ -0,0 +1,102 @@
+package v1
+
+import (
+	"context"
+	"time"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+)
+
+// Default token settings
+const (
+	// DefaultIssuer - the `iss` claim of access tokens by default
+	DefaultIssuer = "users-usvc"
+	// DefaultAccessTokenTTL - how long access tokens are valid by default
+	DefaultAccessTokenTTL = 15 * time.Minute
+	// DefaultRefreshTokenTTL - how long refresh tokens are valid by default
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Tokens - the tokens issued when a user logs in or refreshes the session
+type Tokens struct {
+	AccessToken string `json:"access_token"`
+	// TokenType is always `Bearer`
+	TokenType string `json:"token_type"`
+	// ExpiresIn is the lifetime of the access token in seconds
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Manager defines the interface for authenticating users and issuing their tokens.
+// Access tokens are JWTs signed with the keys in the KeyStore, so other services can verify them with the public keys
+// served in the JWK set. Refresh tokens are opaque, single-use and can be revoked.
+type Manager interface {
+	// Login verifies the credentials of a user and issues a new pair of tokens
+	Login(ctx context.Context, email, password string) (*Tokens, error)
+	// Refresh exchanges a refresh token for a new pair of tokens. The given refresh token is revoked; using it again
+	// revokes all tokens issued since the login, as the token has likely been stolen.
+	Refresh(ctx context.Context, refreshToken string) (*Tokens, error)
+	// Logout revokes the refresh token and all tokens issued since the same login. Unknown tokens are ignored.
+	Logout(ctx context.Context, refreshToken string) error
+	// VerifyAccessToken verifies the signature, the issuer and the expiry of an access token and returns its claims.
+	// Tokens of users who have been deleted or deactivated, or whose password has changed since the token was issued,
+	// are rejected as well.
+	VerifyAccessToken(ctx context.Context, accessToken string) (*Claims, error)
+	// JWKSet returns the public keys which verify access tokens
+	JWKSet() *JWKSet
+}
+
+// manager is the implementation of Manager interface
+type manager struct {
+	users           userV1.Manager
+	repo            Repository
+	keys            KeyStore
+	issuer          string
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+}
+
+// Option configures optional settings of a Manager
+type Option func(m *manager)
+
+// WithIssuer sets the `iss` claim of access tokens. It is `users-usvc` if it is not set.
+func WithIssuer(issuer string) Option {
+	return func(m *manager) {
+		m.issuer = issuer
+	}
+}
+
+// WithAccessTokenTTL sets how long access tokens are valid. It is 15 minutes if it is not set.
+func WithAccessTokenTTL(ttl time.Duration) Option {
+	return func(m *manager) {
+		m.accessTokenTTL = ttl
+	}
+}
+
+// WithRefreshTokenTTL sets how long refresh tokens are valid. It is 30 days if it is not set.
+func WithRefreshTokenTTL(ttl time.Duration) Option {
+	return func(m *manager) {
+		m.refreshTokenTTL = ttl
+	}
+}
+
+// NewManager creates an instance of Manager which authenticates the users managed by the given user manager
+func NewManager(users userV1.Manager, repo Repository, keys KeyStore, opts ...Option) Manager {
+	m := &manager{
+		users:           users,
+		repo:            repo,
+		keys:            keys,
+		issuer:          DefaultIssuer,
+		accessTokenTTL:  DefaultAccessTokenTTL,
+		refreshTokenTTL: DefaultRefreshTokenTTL,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// JWKSet - the implementation of the `JWKSet` method
+func (m *manager) JWKSet() *JWKSet {
+	return m.keys.JWKSet()
+}
@@ -0,0 +1,116 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// testPassword - a password which passes the default password policy
+const testPassword = "Passw0rd!xyz"
+
+// testRepositories returns every Repository implementation, each backed by an empty store
+func testRepositories(t *testing.T) map[string]Repository {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening the database, err: %s", err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+	// Every connection to `:memory:` opens a database of its own
+	db.SetMaxOpenConns(1)
+	if err := userV1.MigrateSQLSchema(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateSQLSchema(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]Repository{
+		"memory": NewMemoryRepository(),
+		"sql":    NewSQLRepository(db),
+	}
+}
+
+// newTestUsers creates a user manager backed by a memory repository. Passwords are hashed with the minimum
+// bcrypt cost to keep tests fast, and emails are discarded.
+func newTestUsers(t *testing.T) userV1.Manager {
+	t.Helper()
+	cfg := userV1.DefaultPasswordConfig()
+	cfg.BcryptCost = bcrypt.MinCost
+	hasher, err := userV1.NewPasswordHasher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return userV1.NewManager(userV1.NewMemoryRepository(), userV1.WithPasswordHasher(hasher), userV1.WithMailer(userV1.NewWriterMailer(io.Discard)))
+}
+
+// newTestKeys creates a key store in a temporary directory
+func newTestKeys(t *testing.T) KeyStore {
+	t.Helper()
+	keys, err := NewFileKeyStore(t.TempDir(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// newActiveUser creates an active user with the given email and returns its ID
+func newActiveUser(t *testing.T, ctx context.Context, users userV1.Manager, email string) string {
+	t.Helper()
+	ID, err := users.Create(ctx, "Ann", "Lee", testPassword, email, "")
+	if err != nil {
+		t.Fatalf("Create(%s) err: %v", email, err)
+	}
+	if _, err := users.SetStatus(ctx, ID, userV1.UserStatusActive); err != nil {
+		t.Fatal(err)
+	}
+	return ID
+}
+
+// mustLogin logs the user with the given email in and returns the tokens
+func mustLogin(t *testing.T, ctx context.Context, m Manager, email string) *Tokens {
+	t.Helper()
+	tokens, err := m.Login(ctx, email, testPassword)
+	if err != nil {
+		t.Fatalf("Login(%s) err: %v", email, err)
+	}
+	return tokens
+}
+
+// errCode returns the code of the error, or an empty code if it is not an Error
+func errCode(err error) string {
+	if e, ok := usvcErrors.Convert(err); ok {
+		return e.Code()
+	}
+	return ""
+}
+
+// errType returns the type of the error, or an empty type if it is not an Error
+func errType(err error) usvcErrors.ErrType {
+	if e, ok := usvcErrors.Convert(err); ok {
+		return e.Type()
+	}
+	return ""
+}
+
+func TestManagerJWKSet(t *testing.T) {
+	keys := newTestKeys(t)
+	m := NewManager(newTestUsers(t), NewMemoryRepository(), keys)
+
+	set := m.JWKSet()
+	if len(set.Keys) != 1 {
+		t.Fatalf("JWKSet() returned %d keys, want 1", len(set.Keys))
+	}
+	key := set.Keys[0]
+	if key.KeyID != keys.SigningKey().ID || key.KeyType != "OKP" || key.Curve != "Ed25519" || key.Algorithm != "EdDSA" {
+		t.Errorf("JWKSet() = %+v, want the Ed25519 key %s", key, keys.SigningKey().ID)
+	}
+}
@@ -0,0 +1,40 @@
+package v1
+
+import (
+	"context"
+	"time"
+)
+
+// RefreshTokenRecord represents a refresh token. Only the SHA-256 hash of the token is stored.
+type RefreshTokenRecord struct {
+	// ID is the hex encoded SHA-256 hash of the token
+	ID string
+	// TenantID is the tenant of the user. Refreshes look the user up in this tenant rather than the tenant of the
+	// request, as refresh requests carry no access token.
+	TenantID string
+	UserID   string
+	// FamilyID is shared by the tokens issued by one login and the refreshes which follow it
+	FamilyID string
+	// SessionVersion is the session version of the user when the token was issued
+	SessionVersion int
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+	// RevokedAt is set when the token is used, the user logs out or the token family is revoked
+	RevokedAt *time.Time
+}
+
+// Repository defines the interface for persisting refresh tokens and their revocation list.
+// Like the user repository, it returns `userV1.ErrRecordNotFound` if a record does not exist.
+type Repository interface {
+	// CreateRefreshToken stores the given refresh token
+	CreateRefreshToken(ctx context.Context, token *RefreshTokenRecord) error
+	// GetRefreshToken returns the refresh token with the given ID, including revoked ones
+	GetRefreshToken(ctx context.Context, ID string) (*RefreshTokenRecord, error)
+	// RevokeRefreshToken revokes the refresh token with the given ID. It returns ErrRecordNotFound if the token
+	// does not exist or has been revoked, so that each token can be used once even by concurrent requests.
+	RevokeRefreshToken(ctx context.Context, ID string, revokedAt time.Time) error
+	// RevokeRefreshTokenFamily revokes all unrevoked refresh tokens of the given family
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string, revokedAt time.Time) error
+	// DeleteExpiredRefreshTokens removes the refresh tokens which expired before the given time
+	DeleteExpiredRefreshTokens(ctx context.Context, before time.Time) error
+}
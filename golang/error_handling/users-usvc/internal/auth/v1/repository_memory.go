@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+)
+
+// memoryRepository is the implementation of Repository interface which keeps refresh tokens in memory.
+// It is meant for tests.
+type memoryRepository struct {
+	mu     sync.Mutex
+	tokens map[string]*RefreshTokenRecord // ID -> token
+}
+
+// NewMemoryRepository creates an instance of Repository which keeps refresh tokens in memory
+func NewMemoryRepository() Repository {
+	return &memoryRepository{
+		tokens: map[string]*RefreshTokenRecord{},
+	}
+}
+
+// CreateRefreshToken - the implementation of the `CreateRefreshToken` method
+func (r *memoryRepository) CreateRefreshToken(ctx context.Context, token *RefreshTokenRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tokens[token.ID]; ok {
+		return userV1.ErrDuplicateRecord
+	}
+	t := *token
+	r.tokens[token.ID] = &t
+	return nil
+}
+
+// GetRefreshToken - the implementation of the `GetRefreshToken` method
+func (r *memoryRepository) GetRefreshToken(ctx context.Context, ID string) (*RefreshTokenRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[ID]
+	if !ok {
+		return nil, userV1.ErrRecordNotFound
+	}
+	t := *token
+	return &t, nil
+}
+
+// RevokeRefreshToken - the implementation of the `RevokeRefreshToken` method
+func (r *memoryRepository) RevokeRefreshToken(ctx context.Context, ID string, revokedAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[ID]
+	if !ok || token.RevokedAt != nil {
+		return userV1.ErrRecordNotFound
+	}
+	token.RevokedAt = &revokedAt
+	return nil
+}
+
+// RevokeRefreshTokenFamily - the implementation of the `RevokeRefreshTokenFamily` method
+func (r *memoryRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string, revokedAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			t := revokedAt
+			token.RevokedAt = &t
+		}
+	}
+	return nil
+}
+
+// DeleteExpiredRefreshTokens - the implementation of the `DeleteExpiredRefreshTokens` method
+func (r *memoryRepository) DeleteExpiredRefreshTokens(ctx context.Context, before time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for ID, token := range r.tokens {
+		if token.ExpiresAt.Before(before) {
+			delete(r.tokens, ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcSQLSchema "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/sqlschema"
+)
+
+// sqlSchema - statements for creating the tables used by the SQL repository
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id              VARCHAR(64) NOT NULL PRIMARY KEY,
+		tenant_id       VARCHAR(64) NOT NULL DEFAULT 'default',
+		user_id         VARCHAR(64) NOT NULL,
+		family_id       VARCHAR(64) NOT NULL,
+		session_version INT         NOT NULL,
+		created_at      DATETIME    NOT NULL,
+		expires_at      DATETIME    NOT NULL,
+		revoked_at      DATETIME    NULL
+	)`,
+}
+
+// MigrateSQLSchema creates the tables used by the SQL repository if they do not exist, and adds the tenant to the
+// tables created before it was recorded. Their rows are moved to the default tenant.
+func MigrateSQLSchema(ctx context.Context, db *sql.DB) error {
+	for _, stmt := range sqlSchema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("error migrating the auth schema, err: %s", err.Error())
+		}
+	}
+	if err := usvcSQLSchema.AddColumn(ctx, db, "refresh_tokens", "tenant_id", `VARCHAR(64) NOT NULL DEFAULT 'default'`); err != nil {
+		return fmt.Errorf("error migrating the auth schema, err: %s", err.Error())
+	}
+	return nil
+}
+
+// sqlRepository is the implementation of Repository interface backed by `database/sql`.
+// It works with both SQLite and MySQL; MySQL DSNs need `parseTime=true`.
+type sqlRepository struct {
+	db *sql.DB
+}
+
+// NewSQLRepository creates an instance of Repository which stores refresh tokens in the given database
+func NewSQLRepository(db *sql.DB) Repository {
+	return &sqlRepository{
+		db: db,
+	}
+}
+
+// CreateRefreshToken - the implementation of the `CreateRefreshToken` method
+func (r *sqlRepository) CreateRefreshToken(ctx context.Context, token *RefreshTokenRecord) error {
+	var revokedAt sql.NullTime
+	if token.RevokedAt != nil {
+		revokedAt = sql.NullTime{Time: *token.RevokedAt, Valid: true}
+	}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (id, tenant_id, user_id, family_id, session_version, created_at, expires_at, revoked_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		token.ID, token.TenantID, token.UserID, token.FamilyID, token.SessionVersion, token.CreatedAt, token.ExpiresAt, revokedAt,
+	)
+	return err
+}
+
+// GetRefreshToken - the implementation of the `GetRefreshToken` method
+func (r *sqlRepository) GetRefreshToken(ctx context.Context, ID string) (*RefreshTokenRecord, error) {
+	token := &RefreshTokenRecord{}
+	var revokedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, tenant_id, user_id, family_id, session_version, created_at, expires_at, revoked_at FROM refresh_tokens WHERE id = ?`,
+		ID,
+	).Scan(&token.ID, &token.TenantID, &token.UserID, &token.FamilyID, &token.SessionVersion, &token.CreatedAt, &token.ExpiresAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, userV1.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return token, nil
+}
+
+// RevokeRefreshToken - the implementation of the `RevokeRefreshToken` method
+func (r *sqlRepository) RevokeRefreshToken(ctx context.Context, ID string, revokedAt time.Time) error {
+	// The condition on revoked_at makes concurrent refreshes race on a single row update, so only one of them succeeds
+	res, err := r.db.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, revokedAt, ID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return userV1.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeRefreshTokenFamily - the implementation of the `RevokeRefreshTokenFamily` method
+func (r *sqlRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string, revokedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL`, revokedAt, familyID)
+	return err
+}
+
+// DeleteExpiredRefreshTokens - the implementation of the `DeleteExpiredRefreshTokens` method
+func (r *sqlRepository) DeleteExpiredRefreshTokens(ctx context.Context, before time.Time) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at < ?`, before)
+	return err
+}
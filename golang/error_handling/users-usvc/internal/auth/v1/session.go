@@ -0,0 +1,152 @@
+package v1
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// Login - the implementation of the `Login` method
+func (m *manager) Login(ctx context.Context, email, password string) (*Tokens, error) {
+	user, err := m.users.VerifyCredentials(ctx, email, password)
+	if err != nil {
+		return nil, err
+	}
+
+	familyID, err := randomToken()
+	if err != nil {
+		return nil, newInternalError(err, "Error generating the token family of user %s", user.ID)
+	}
+	return m.issueTokens(ctx, user, familyID)
+}
+
+// Refresh - the implementation of the `Refresh` method
+func (m *manager) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	record, err := m.repo.GetRefreshToken(ctx, hashToken(refreshToken))
+	if errors.Is(err, userV1.ErrRecordNotFound) {
+		return nil, newCodedError(usvcErrors.ErrTypeUnauthorized, CodeInvalidRefreshToken, nil, "The refresh token is invalid.")
+	}
+	if err != nil {
+		return nil, newInternalError(err, "Error getting the refresh token")
+	}
+
+	now := time.Now().UTC()
+	if record.RevokedAt != nil {
+		m.revokeFamily(ctx, record, "a revoked refresh token was used")
+		return nil, newCodedError(usvcErrors.ErrTypeUnauthorized, CodeInvalidRefreshToken, nil, "The refresh token has been revoked.")
+	}
+	if !now.Before(record.ExpiresAt) {
+		return nil, newCodedError(usvcErrors.ErrTypeUnauthorized, CodeInvalidRefreshToken, nil, "The refresh token has expired.")
+	}
+
+	user, err := m.users.Get(usvcTenant.WithID(ctx, record.TenantID), record.UserID)
+	if errors.Is(err, usvcErrors.ErrNotFound) {
+		m.revokeFamily(ctx, record, "the user has been deleted")
+		return nil, newCodedError(usvcErrors.ErrTypeUnauthorized, CodeInvalidRefreshToken, nil, "The refresh token is invalid.")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.SessionVersion != record.SessionVersion {
+		m.revokeFamily(ctx, record, "the password has changed")
+		return nil, newCodedError(usvcErrors.ErrTypeUnauthorized, CodeSessionEnded, nil, "The session has ended as the password has changed, please log in again.")
+	}
+	if user.Status != userV1.UserStatusActive {
+		m.revokeFamily(ctx, record, "the user is not active")
+		return nil, newCodedError(usvcErrors.ErrTypeForbidden, userV1.CodeUserNotActive, map[string]string{"status": string(user.Status)},
+			"The user is %s and cannot sign in.", user.Status)
+	}
+
+	err = m.repo.RevokeRefreshToken(ctx, record.ID, now)
+	if errors.Is(err, userV1.ErrRecordNotFound) {
+		// Another request used the token in the meantime
+		m.revokeFamily(ctx, record, "the refresh token was used concurrently")
+		return nil, newCodedError(usvcErrors.ErrTypeUnauthorized, CodeInvalidRefreshToken, nil, "The refresh token has been revoked.")
+	}
+	if err != nil {
+		return nil, newInternalError(err, "Error revoking the refresh token of user %s", user.ID)
+	}
+	return m.issueTokens(ctx, user, record.FamilyID)
+}
+
+// Logout - the implementation of the `Logout` method
+func (m *manager) Logout(ctx context.Context, refreshToken string) error {
+	record, err := m.repo.GetRefreshToken(ctx, hashToken(refreshToken))
+	if errors.Is(err, userV1.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return newInternalError(err, "Error getting the refresh token")
+	}
+
+	if err := m.repo.RevokeRefreshTokenFamily(ctx, record.FamilyID, time.Now().UTC()); err != nil {
+		return newInternalError(err, "Error revoking the refresh tokens of user %s", record.UserID)
+	}
+	return nil
+}
+
+// issueTokens issues an access token and a refresh token of the given family for the user
+func (m *manager) issueTokens(ctx context.Context, user *userV1.User, familyID string) (*Tokens, error) {
+	now := time.Now().UTC()
+	accessToken, err := m.issueAccessToken(user, now)
+	if err != nil {
+		return nil, newInternalError(err, "Error signing the access token of user %s", user.ID)
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, newInternalError(err, "Error generating the refresh token of user %s", user.ID)
+	}
+	err = m.repo.CreateRefreshToken(ctx, &RefreshTokenRecord{
+		ID:             hashToken(refreshToken),
+		TenantID:       user.TenantID,
+		UserID:         user.ID,
+		FamilyID:       familyID,
+		SessionVersion: user.SessionVersion,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(m.refreshTokenTTL),
+	})
+	if err != nil {
+		return nil, newInternalError(err, "Error storing the refresh token of user %s", user.ID)
+	}
+
+	return &Tokens{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(m.accessTokenTTL / time.Second),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// revokeFamily revokes the token family of a refresh token which cannot be used any more.
+// Failures are only logged as the request is rejected anyway.
+func (m *manager) revokeFamily(ctx context.Context, record *RefreshTokenRecord, reason string) {
+	log.Printf("[auth_v1] revoking the refresh tokens of user %s as %s", record.UserID, reason)
+	if err := m.repo.RevokeRefreshTokenFamily(ctx, record.FamilyID, time.Now().UTC()); err != nil {
+		log.Printf("[auth_v1] error revoking the refresh tokens of user %s, err: %s", record.UserID, err.Error())
+	}
+}
+
+// randomToken returns 32 random bytes encoded in base64url
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the ID under which a refresh token is stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
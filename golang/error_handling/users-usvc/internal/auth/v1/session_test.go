@@ -0,0 +1,185 @@
+package v1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+func TestLoginAndRefresh(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			users := newTestUsers(t)
+			ID := newActiveUser(t, ctx, users, "ann@example.com")
+			m := NewManager(users, repo, newTestKeys(t))
+
+			tokens := mustLogin(t, ctx, m, "Ann@example.com")
+			if tokens.TokenType != "Bearer" || tokens.ExpiresIn != int(DefaultAccessTokenTTL/time.Second) || tokens.RefreshToken == "" {
+				t.Errorf("Login() = %+v, want a bearer token valid for %s and a refresh token", tokens, DefaultAccessTokenTTL)
+			}
+			if claims, err := m.VerifyAccessToken(ctx, tokens.AccessToken); err != nil || claims.Subject != ID {
+				t.Errorf("VerifyAccessToken() = %+v, %v, want the claims of user %s", claims, err, ID)
+			}
+
+			refreshed, err := m.Refresh(ctx, tokens.RefreshToken)
+			if err != nil {
+				t.Fatalf("Refresh() err: %v", err)
+			}
+			if refreshed.RefreshToken == tokens.RefreshToken {
+				t.Error("Refresh() returned the same refresh token, want it rotated")
+			}
+			if _, err := m.VerifyAccessToken(ctx, refreshed.AccessToken); err != nil {
+				t.Errorf("VerifyAccessToken() of the refreshed token err: %v", err)
+			}
+
+			for name, token := range map[string]string{"unknown": "unknown", "empty": ""} {
+				if _, err := m.Refresh(ctx, token); errCode(err) != CodeInvalidRefreshToken {
+					t.Errorf("Refresh() with an %s token err: %v, want %s", name, err, CodeInvalidRefreshToken)
+				}
+			}
+		})
+	}
+}
+
+func TestLoginInvalidCredentials(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUsers(t)
+	newActiveUser(t, ctx, users, "ann@example.com")
+	m := NewManager(users, NewMemoryRepository(), newTestKeys(t))
+
+	if _, err := m.Login(ctx, "ann@example.com", "Wrong-passw0rd"); errCode(err) != userV1.CodeInvalidCredentials {
+		t.Errorf("Login() with a wrong password err: %v, want %s", err, userV1.CodeInvalidCredentials)
+	}
+	if _, err := m.Login(ctx, "bob@example.com", testPassword); errCode(err) != userV1.CodeInvalidCredentials {
+		t.Errorf("Login() of an unknown user err: %v, want %s", err, userV1.CodeInvalidCredentials)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			users := newTestUsers(t)
+			newActiveUser(t, ctx, users, "ann@example.com")
+			m := NewManager(users, repo, newTestKeys(t))
+
+			stolen := mustLogin(t, ctx, m, "ann@example.com")
+			other := mustLogin(t, ctx, m, "ann@example.com")
+			refreshed, err := m.Refresh(ctx, stolen.RefreshToken)
+			if err != nil {
+				t.Fatalf("Refresh() err: %v", err)
+			}
+
+			if _, err := m.Refresh(ctx, stolen.RefreshToken); errCode(err) != CodeInvalidRefreshToken {
+				t.Errorf("Refresh() with a used token err: %v, want %s", err, CodeInvalidRefreshToken)
+			}
+			// The reuse revokes the tokens issued since the same login, but not other sessions
+			if _, err := m.Refresh(ctx, refreshed.RefreshToken); errCode(err) != CodeInvalidRefreshToken {
+				t.Errorf("Refresh() with a token of a revoked family err: %v, want %s", err, CodeInvalidRefreshToken)
+			}
+			if _, err := m.Refresh(ctx, other.RefreshToken); err != nil {
+				t.Errorf("Refresh() of another session err: %v", err)
+			}
+		})
+	}
+}
+
+func TestRefreshExpiredToken(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUsers(t)
+	newActiveUser(t, ctx, users, "ann@example.com")
+	m := NewManager(users, NewMemoryRepository(), newTestKeys(t), WithRefreshTokenTTL(-time.Minute))
+
+	if _, err := m.Refresh(ctx, mustLogin(t, ctx, m, "ann@example.com").RefreshToken); errCode(err) != CodeInvalidRefreshToken {
+		t.Errorf("Refresh() with an expired token err: %v, want %s", err, CodeInvalidRefreshToken)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			users := newTestUsers(t)
+			newActiveUser(t, ctx, users, "ann@example.com")
+			m := NewManager(users, repo, newTestKeys(t))
+
+			tokens := mustLogin(t, ctx, m, "ann@example.com")
+			refreshed, err := m.Refresh(ctx, tokens.RefreshToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Logging out with any token of the session revokes the whole session
+			if err := m.Logout(ctx, tokens.RefreshToken); err != nil {
+				t.Fatalf("Logout() err: %v", err)
+			}
+			if _, err := m.Refresh(ctx, refreshed.RefreshToken); errCode(err) != CodeInvalidRefreshToken {
+				t.Errorf("Refresh() after Logout() err: %v, want %s", err, CodeInvalidRefreshToken)
+			}
+			if err := m.Logout(ctx, "unknown"); err != nil {
+				t.Errorf("Logout() with an unknown token err: %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestRefreshEndsSessions(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		change   func(t *testing.T, users userV1.Manager, ID string)
+		wantType usvcErrors.ErrType
+		wantCode string
+	}{
+		{
+			name: "password changed",
+			change: func(t *testing.T, users userV1.Manager, ID string) {
+				if err := users.ChangePassword(ctx, ID, testPassword, "N3w-passw0rd!"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantType: usvcErrors.ErrTypeUnauthorized,
+			wantCode: CodeSessionEnded,
+		},
+		{
+			name: "user disabled",
+			change: func(t *testing.T, users userV1.Manager, ID string) {
+				if _, err := users.SetStatus(ctx, ID, userV1.UserStatusDisabled); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantType: usvcErrors.ErrTypeForbidden,
+			wantCode: userV1.CodeUserNotActive,
+		},
+		{
+			name: "user deleted",
+			change: func(t *testing.T, users userV1.Manager, ID string) {
+				if err := users.Delete(ctx, ID, true); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantType: usvcErrors.ErrTypeUnauthorized,
+			wantCode: CodeInvalidRefreshToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := newTestUsers(t)
+			ID := newActiveUser(t, ctx, users, "ann@example.com")
+			m := NewManager(users, NewMemoryRepository(), newTestKeys(t))
+			tokens := mustLogin(t, ctx, m, "ann@example.com")
+
+			tt.change(t, users, ID)
+			_, err := m.Refresh(ctx, tokens.RefreshToken)
+			if errType(err) != tt.wantType || errCode(err) != tt.wantCode {
+				t.Errorf("Refresh() err: %v, want %s %s", err, tt.wantType, tt.wantCode)
+			}
+			if _, err := m.VerifyAccessToken(ctx, tokens.AccessToken); errType(err) != tt.wantType {
+				t.Errorf("VerifyAccessToken() err: %v, want %s", err, tt.wantType)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package v1
+
+import (
+	"context"
+	"testing"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+func TestTokensCarryTenant(t *testing.T) {
+	ctx := context.Background()
+	acme, beta := usvcTenant.WithID(ctx, "acme"), usvcTenant.WithID(ctx, "beta")
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			users := newTestUsers(t)
+			acmeID := newActiveUser(t, acme, users, "ann@example.com")
+			betaID := newActiveUser(t, beta, users, "ann@example.com")
+			m := NewManager(users, repo, newTestKeys(t))
+
+			// The same email logs in as the user of the tenant in the context
+			for tenant, want := range map[string]string{"acme": acmeID, "beta": betaID} {
+				tokens := mustLogin(t, usvcTenant.WithID(ctx, tenant), m, "ann@example.com")
+				// The tokens are verified and refreshed in their tenant whatever tenant the context names
+				claims, err := m.VerifyAccessToken(ctx, tokens.AccessToken)
+				if err != nil || claims.Subject != want || claims.TenantID != tenant {
+					t.Errorf("VerifyAccessToken() = %+v, %v, want user %s of %s", claims, err, want, tenant)
+				}
+				refreshed, err := m.Refresh(ctx, tokens.RefreshToken)
+				if err != nil {
+					t.Fatalf("Refresh() err: %v", err)
+				}
+				if claims, err := m.VerifyAccessToken(ctx, refreshed.AccessToken); err != nil || claims.Subject != want || claims.TenantID != tenant {
+					t.Errorf("VerifyAccessToken() of the refreshed token = %+v, %v, want user %s of %s", claims, err, want, tenant)
+				}
+			}
+
+			if _, err := m.Login(ctx, "ann@example.com", testPassword); errCode(err) != userV1.CodeInvalidCredentials {
+				t.Errorf("Login() in the default tenant err: %v, want %s", err, userV1.CodeInvalidCredentials)
+			}
+		})
+	}
+}
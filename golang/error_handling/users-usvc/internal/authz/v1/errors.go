@@ -0,0 +1,40 @@
+package v1
+
+import (
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// Error codes which are more specific than error types
+const (
+	// CodePermissionDenied - the caller does not have the permission required by the operation
+	CodePermissionDenied = "permission_denied"
+	// CodeRoleNotFound - the role does not exist
+	CodeRoleNotFound = "role_not_found"
+	// CodeRoleExists - a role with the same name exists
+	CodeRoleExists = "role_exists"
+	// CodeRoleBound - the role has been bound to the user
+	CodeRoleBound = "role_bound"
+	// CodeRoleNotBound - the role has not been bound to the user
+	CodeRoleNotBound = "role_not_bound"
+	// CodeBuiltInRole - built-in roles cannot be changed
+	CodeBuiltInRole = "built_in_role"
+)
+
+// newCodedError returns an error with given error type, code and optional metadata
+func newCodedError(errType usvcErrors.ErrType, code string, metadata map[string]string, format string, a ...interface{}) usvcErrors.Error {
+	usvcErrors.Helper()
+	return usvcErrors.NewCoded(errType, code, metadata, format, a...)
+}
+
+// newValidationError returns a bad request error which carries the given field violations
+func newValidationError(violations []usvcErrors.FieldViolation) usvcErrors.Error {
+	usvcErrors.Helper()
+	return usvcErrors.NewValidation(violations)
+}
+
+// newInternalError returns an internal server error caused by a downstream error, or a timeout or canceled error
+// if the downstream error is caused by the context of the request
+func newInternalError(cause error, format string, a ...interface{}) usvcErrors.Error {
+	usvcErrors.Helper()
+	return usvcErrors.WrapInternal(cause, format, a...)
+}
@@ -0,0 +1,198 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	authV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/auth/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcOpenAPI "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/openapi"
+	usvcRequest "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/request"
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// RegisterRoutes registers the endpoints which manage roles and their bindings on the router:
+//
+//	GET    /authz/v1/roles                   - list the roles
+//	POST   /authz/v1/roles                   - create a custom role
+//	GET    /authz/v1/roles/{name}            - get a role
+//	DELETE /authz/v1/roles/{name}            - delete a custom role
+//	GET    /authz/v1/users/{id}/roles        - list the roles bound to a user
+//	PUT    /authz/v1/users/{id}/roles/{role} - bind a role to a user
+//	DELETE /authz/v1/users/{id}/roles/{role} - unbind a role from a user
+//
+// Every endpoint authenticates the user with the given auth Manager and requires `PermissionRolesManage`.
+func RegisterRoutes(r *mux.Router, m Manager, auth authV1.Manager) {
+	s := r.PathPrefix("/authz/v1").Subrouter()
+	s.Use(authV1.Middleware(auth), Require(m, PermissionRolesManage))
+	s.HandleFunc("/roles", listRolesHandler(m)).Methods(http.MethodGet)
+	s.HandleFunc("/roles", createRoleHandler(m)).Methods(http.MethodPost)
+	s.HandleFunc("/roles/{name}", getRoleHandler(m)).Methods(http.MethodGet)
+	s.HandleFunc("/roles/{name}", deleteRoleHandler(m)).Methods(http.MethodDelete)
+	s.HandleFunc("/users/{id}/roles", listUserRolesHandler(m)).Methods(http.MethodGet)
+	s.HandleFunc("/users/{id}/roles/{role}", bindRoleHandler(m)).Methods(http.MethodPut)
+	s.HandleFunc("/users/{id}/roles/{role}", unbindRoleHandler(m)).Methods(http.MethodDelete)
+}
+
+// DescribeRoutes adds the endpoints registered by `RegisterRoutes` to the OpenAPI document
+func DescribeRoutes(d *usvcOpenAPI.Document) {
+	tags := []string{"authz"}
+	security := []map[string][]string{{usvcOpenAPI.SecurityBearer: {}}}
+	nameParam := &usvcOpenAPI.Parameter{Name: "name", In: "path", Required: true, Schema: usvcOpenAPI.String("The name of the role")}
+	idParam := &usvcOpenAPI.Parameter{Name: "id", In: "path", Required: true, Schema: usvcOpenAPI.String("The ID of the user")}
+	roleParam := &usvcOpenAPI.Parameter{Name: "role", In: "path", Required: true, Schema: usvcOpenAPI.String("The name of the role")}
+	// Every endpoint needs an access token with `PermissionRolesManage`
+	common := []usvcErrors.ErrType{usvcErrors.ErrTypeUnauthorized, usvcErrors.ErrTypeForbidden, usvcErrors.ErrTypeInternalServerErr}
+
+	d.Add(http.MethodGet, "/authz/v1/roles", &usvcOpenAPI.Operation{
+		OperationID: "listRoles",
+		Summary:     "List the roles",
+		Tags:        tags,
+		Security:    security,
+		Responses:   map[string]*usvcOpenAPI.Response{"200": usvcOpenAPI.DataResponse("The roles", []*Role{})},
+	}, common...)
+	d.Add(http.MethodPost, "/authz/v1/roles", &usvcOpenAPI.Operation{
+		OperationID: "createRole",
+		Summary:     "Create a custom role",
+		Tags:        tags,
+		Security:    security,
+		RequestBody: usvcOpenAPI.JSONBody(createRoleRequest{}),
+		Responses:   map[string]*usvcOpenAPI.Response{"201": usvcOpenAPI.DataResponse("The role is created", Role{})},
+	}, append(common, usvcErrors.ErrTypeBadRequest, usvcErrors.ErrTypeConflict)...)
+	d.Add(http.MethodGet, "/authz/v1/roles/{name}", &usvcOpenAPI.Operation{
+		OperationID: "getRole",
+		Summary:     "Get a role",
+		Tags:        tags,
+		Security:    security,
+		Parameters:  []*usvcOpenAPI.Parameter{nameParam},
+		Responses:   map[string]*usvcOpenAPI.Response{"200": usvcOpenAPI.DataResponse("The role", Role{})},
+	}, append(common, usvcErrors.ErrTypeNotFound)...)
+	d.Add(http.MethodDelete, "/authz/v1/roles/{name}", &usvcOpenAPI.Operation{
+		OperationID: "deleteRole",
+		Summary:     "Delete a custom role and its bindings",
+		Tags:        tags,
+		Security:    security,
+		Parameters:  []*usvcOpenAPI.Parameter{nameParam},
+		Responses:   map[string]*usvcOpenAPI.Response{"204": {Description: "The role is deleted"}},
+	}, append(common, usvcErrors.ErrTypeNotFound, usvcErrors.ErrTypeConflict)...)
+	d.Add(http.MethodGet, "/authz/v1/users/{id}/roles", &usvcOpenAPI.Operation{
+		OperationID: "listUserRoles",
+		Summary:     "List the roles bound to a user",
+		Tags:        tags,
+		Security:    security,
+		Parameters:  []*usvcOpenAPI.Parameter{idParam},
+		Responses:   map[string]*usvcOpenAPI.Response{"200": usvcOpenAPI.DataResponse("The roles of the user", []*Role{})},
+	}, common...)
+	d.Add(http.MethodPut, "/authz/v1/users/{id}/roles/{role}", &usvcOpenAPI.Operation{
+		OperationID: "bindRole",
+		Summary:     "Bind a role to a user",
+		Tags:        tags,
+		Security:    security,
+		Parameters:  []*usvcOpenAPI.Parameter{idParam, roleParam},
+		Responses:   map[string]*usvcOpenAPI.Response{"204": {Description: "The role is bound to the user"}},
+	}, append(common, usvcErrors.ErrTypeNotFound, usvcErrors.ErrTypeConflict)...)
+	d.Add(http.MethodDelete, "/authz/v1/users/{id}/roles/{role}", &usvcOpenAPI.Operation{
+		OperationID: "unbindRole",
+		Summary:     "Unbind a role from a user",
+		Tags:        tags,
+		Security:    security,
+		Parameters:  []*usvcOpenAPI.Parameter{idParam, roleParam},
+		Responses:   map[string]*usvcOpenAPI.Response{"204": {Description: "The role is unbound from the user"}},
+	}, append(common, usvcErrors.ErrTypeNotFound)...)
+}
+
+// createRoleRequest - the request body of creating a custom role
+type createRoleRequest struct {
+	Name        string       `json:"name" validate:"required,max=64"`
+	Permissions []Permission `json:"permissions" validate:"required,max=64"`
+}
+
+// listRolesHandler is the API handler for listing roles
+func listRolesHandler(m Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roles, err := m.ListRoles(r.Context())
+		if err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+		usvcResponse.OK(w, roles)
+	}
+}
+
+// createRoleHandler is the API handler for creating a custom role
+func createRoleHandler(m Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &createRoleRequest{}
+		if err := usvcRequest.Decode(w, r, req); err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+
+		role, err := m.CreateRole(r.Context(), req.Name, req.Permissions)
+		if err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+		usvcResponse.Created(w, "/authz/v1/roles/"+role.Name, role)
+	}
+}
+
+// getRoleHandler is the API handler for getting a role
+func getRoleHandler(m Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role, err := m.GetRole(r.Context(), mux.Vars(r)["name"])
+		if err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+		usvcResponse.OK(w, role)
+	}
+}
+
+// deleteRoleHandler is the API handler for deleting a custom role
+func deleteRoleHandler(m Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := m.DeleteRole(r.Context(), mux.Vars(r)["name"]); err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+		usvcResponse.NoContent(w)
+	}
+}
+
+// listUserRolesHandler is the API handler for listing the roles bound to a user
+func listUserRolesHandler(m Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roles, err := m.ListUserRoles(r.Context(), mux.Vars(r)["id"])
+		if err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+		usvcResponse.OK(w, roles)
+	}
+}
+
+// bindRoleHandler is the API handler for binding a role to a user
+func bindRoleHandler(m Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		if err := m.BindRole(r.Context(), vars["id"], vars["role"]); err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+		usvcResponse.NoContent(w)
+	}
+}
+
+// unbindRoleHandler is the API handler for unbinding a role from a user
+func unbindRoleHandler(m Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		if err := m.UnbindRole(r.Context(), vars["id"], vars["role"]); err != nil {
+			usvcResponse.Error(w, r, err)
+			return
+		}
+		usvcResponse.NoContent(w)
+	}
+}
@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// serve sends a request with an optional JSON body to the router as the given user
+func serve(r http.Handler, method, target, body, user string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+user)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRoleHandlers(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(NewMemoryRepository())
+	if err := m.BindRole(ctx, "admin", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.BindRole(ctx, "viewer", RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	r := mux.NewRouter()
+	RegisterRoutes(r, m, &tokenManager{})
+
+	rec := serve(r, http.MethodPost, "/authz/v1/roles", `{"name":"deleter","permissions":["users:read","users:delete"]}`, "admin")
+	role := &Role{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &usvcResponse.Envelope{Data: role}); rec.Code != http.StatusCreated || err != nil || role.Name != "deleter" {
+		t.Fatalf("POST /authz/v1/roles = %d %s, want 201 deleter", rec.Code, rec.Body.String())
+	}
+	if rec := serve(r, http.MethodPut, "/authz/v1/users/u1/roles/deleter", "", "admin"); rec.Code != http.StatusNoContent {
+		t.Errorf("PUT /authz/v1/users/u1/roles/deleter = %d %s, want 204", rec.Code, rec.Body.String())
+	}
+	if err := m.Authorize(ctx, "u1", PermissionUsersDelete); err != nil {
+		t.Errorf("Authorize() after binding the role err: %v", err)
+	}
+
+	rec = serve(r, http.MethodGet, "/authz/v1/users/u1/roles", "", "admin")
+	var roles []*Role
+	if err := json.Unmarshal(rec.Body.Bytes(), &usvcResponse.Envelope{Data: &roles}); rec.Code != http.StatusOK || err != nil || len(roles) != 1 || roles[0].Name != "deleter" {
+		t.Errorf("GET /authz/v1/users/u1/roles = %d %s, want deleter", rec.Code, rec.Body.String())
+	}
+	if rec := serve(r, http.MethodDelete, "/authz/v1/users/u1/roles/deleter", "", "admin"); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE /authz/v1/users/u1/roles/deleter = %d %s, want 204", rec.Code, rec.Body.String())
+	}
+	if rec := serve(r, http.MethodDelete, "/authz/v1/roles/deleter", "", "admin"); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE /authz/v1/roles/deleter = %d %s, want 204", rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		name, method, target, body, user string
+		wantStatus                       int
+	}{
+		{"without the permission", http.MethodGet, "/authz/v1/roles", "", "viewer", http.StatusForbidden},
+		{"missing role", http.MethodGet, "/authz/v1/roles/deleter", "", "admin", http.StatusNotFound},
+		{"built-in role", http.MethodDelete, "/authz/v1/roles/admin", "", "admin", http.StatusConflict},
+		{"malformed body", http.MethodPost, "/authz/v1/roles", `{"name":`, "admin", http.StatusBadRequest},
+		{"missing name", http.MethodPost, "/authz/v1/roles", `{"permissions":["users:read"]}`, "admin", http.StatusBadRequest},
+		{"unknown permission", http.MethodPost, "/authz/v1/roles", `{"name":"x","permissions":["users:fly"]}`, "admin", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(r, tt.method, tt.target, tt.body, tt.user); rec.Code != tt.wantStatus {
+				t.Errorf("%s %s as %s = %d %s, want %d", tt.method, tt.target, tt.user, rec.Code, rec.Body.String(), tt.wantStatus)
+			}
+		})
+	}
+}
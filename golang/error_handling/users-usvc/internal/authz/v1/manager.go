@@ -0,0 +1,203 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"time"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// roleNamePattern - the format of role names
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+
+// Manager defines the interface for managing roles, binding them to users and checking the permissions of users.
+// The built-in roles `admin` and `viewer` always exist; custom roles are stored in the repository.
+type Manager interface {
+	// CreateRole creates a custom role with the given permissions
+	CreateRole(ctx context.Context, name string, permissions []Permission) (*Role, error)
+	// GetRole returns the role with the given name
+	GetRole(ctx context.Context, name string) (*Role, error)
+	// ListRoles returns the built-in roles followed by the custom roles
+	ListRoles(ctx context.Context) ([]*Role, error)
+	// DeleteRole deletes a custom role and unbinds it from all users
+	DeleteRole(ctx context.Context, name string) error
+	// BindRole grants the role to the user
+	BindRole(ctx context.Context, userID, role string) error
+	// UnbindRole revokes the role from the user
+	UnbindRole(ctx context.Context, userID, role string) error
+	// ListUserRoles returns the roles bound to the user
+	ListUserRoles(ctx context.Context, userID string) ([]*Role, error)
+	// Authorize returns a forbidden error unless one of the roles of the user grants the permission
+	Authorize(ctx context.Context, userID string, permission Permission) error
+}
+
+// manager is the implementation of Manager interface
+type manager struct {
+	repo Repository
+}
+
+// NewManager creates an instance of Manager which stores roles and bindings in the given repository
+func NewManager(repo Repository) Manager {
+	return &manager{
+		repo: repo,
+	}
+}
+
+// CreateRole - the implementation of the `CreateRole` method
+func (m *manager) CreateRole(ctx context.Context, name string, permissions []Permission) (*Role, error) {
+	var violations []usvcErrors.FieldViolation
+	if !roleNamePattern.MatchString(name) {
+		violations = append(violations, usvcErrors.FieldViolation{Field: "name",
+			Description: "The name must start with a lowercase letter and contain at most 64 lowercase letters, digits, `_` or `-`."})
+	}
+	if len(permissions) == 0 {
+		violations = append(violations, usvcErrors.FieldViolation{Field: "permissions", Description: "The role must grant at least one permission."})
+	}
+	for _, p := range permissions {
+		if !knownPermissions[p] {
+			violations = append(violations, usvcErrors.FieldViolation{Field: "permissions", Description: "The permission " + string(p) + " does not exist."})
+		}
+	}
+	if len(violations) > 0 {
+		return nil, newValidationError(violations)
+	}
+	if _, ok := builtInRoles[name]; ok {
+		return nil, newCodedError(usvcErrors.ErrTypeConflict, CodeBuiltInRole, map[string]string{"role": name}, "The role %s is a built-in role.", name)
+	}
+
+	role := &Role{
+		Name:        name,
+		Permissions: permissions,
+		CreatedAt:   time.Now().UTC(),
+	}
+	err := m.repo.CreateRole(ctx, role)
+	if errors.Is(err, userV1.ErrDuplicateRecord) {
+		return nil, newCodedError(usvcErrors.ErrTypeConflict, CodeRoleExists, map[string]string{"role": name}, "The role %s exists.", name)
+	}
+	if err != nil {
+		return nil, newInternalError(err, "Error creating role %s", name)
+	}
+	return role, nil
+}
+
+// GetRole - the implementation of the `GetRole` method
+func (m *manager) GetRole(ctx context.Context, name string) (*Role, error) {
+	if role, ok := builtInRoles[name]; ok {
+		return copyRole(role), nil
+	}
+
+	role, err := m.repo.GetRole(ctx, name)
+	if errors.Is(err, userV1.ErrRecordNotFound) {
+		return nil, newCodedError(usvcErrors.ErrTypeNotFound, CodeRoleNotFound, map[string]string{"role": name}, "The role %s does not exist.", name)
+	}
+	if err != nil {
+		return nil, newInternalError(err, "Error getting role %s", name)
+	}
+	return role, nil
+}
+
+// ListRoles - the implementation of the `ListRoles` method
+func (m *manager) ListRoles(ctx context.Context) ([]*Role, error) {
+	roles := make([]*Role, 0, len(builtInRoles))
+	for _, role := range builtInRoles {
+		roles = append(roles, copyRole(role))
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+
+	custom, err := m.repo.ListRoles(ctx)
+	if err != nil {
+		return nil, newInternalError(err, "Error listing roles")
+	}
+	return append(roles, custom...), nil
+}
+
+// DeleteRole - the implementation of the `DeleteRole` method
+func (m *manager) DeleteRole(ctx context.Context, name string) error {
+	if _, ok := builtInRoles[name]; ok {
+		return newCodedError(usvcErrors.ErrTypeConflict, CodeBuiltInRole, map[string]string{"role": name}, "The role %s is a built-in role and cannot be deleted.", name)
+	}
+
+	err := m.repo.DeleteRole(ctx, name)
+	if errors.Is(err, userV1.ErrRecordNotFound) {
+		return newCodedError(usvcErrors.ErrTypeNotFound, CodeRoleNotFound, map[string]string{"role": name}, "The role %s does not exist.", name)
+	}
+	if err != nil {
+		return newInternalError(err, "Error deleting role %s", name)
+	}
+	return nil
+}
+
+// BindRole - the implementation of the `BindRole` method
+func (m *manager) BindRole(ctx context.Context, userID, role string) error {
+	if _, err := m.GetRole(ctx, role); err != nil {
+		return err
+	}
+
+	err := m.repo.CreateBinding(ctx, &Binding{
+		UserID:    userID,
+		Role:      role,
+		CreatedAt: time.Now().UTC(),
+	})
+	if errors.Is(err, userV1.ErrDuplicateRecord) {
+		return newCodedError(usvcErrors.ErrTypeConflict, CodeRoleBound, map[string]string{"user_id": userID, "role": role},
+			"The role %s has been bound to user %s.", role, userID)
+	}
+	if err != nil {
+		return newInternalError(err, "Error binding role %s to user %s", role, userID)
+	}
+	return nil
+}
+
+// UnbindRole - the implementation of the `UnbindRole` method
+func (m *manager) UnbindRole(ctx context.Context, userID, role string) error {
+	err := m.repo.DeleteBinding(ctx, userID, role)
+	if errors.Is(err, userV1.ErrRecordNotFound) {
+		return newCodedError(usvcErrors.ErrTypeNotFound, CodeRoleNotBound, map[string]string{"user_id": userID, "role": role},
+			"The role %s has not been bound to user %s.", role, userID)
+	}
+	if err != nil {
+		return newInternalError(err, "Error unbinding role %s from user %s", role, userID)
+	}
+	return nil
+}
+
+// ListUserRoles - the implementation of the `ListUserRoles` method
+func (m *manager) ListUserRoles(ctx context.Context, userID string) ([]*Role, error) {
+	bindings, err := m.repo.ListBindings(ctx, userID)
+	if err != nil {
+		return nil, newInternalError(err, "Error listing the roles of user %s", userID)
+	}
+
+	roles := make([]*Role, 0, len(bindings))
+	for _, b := range bindings {
+		role, err := m.GetRole(ctx, b.Role)
+		if errors.Is(err, usvcErrors.ErrNotFound) {
+			// The role was deleted after the bindings were listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// Authorize - the implementation of the `Authorize` method
+func (m *manager) Authorize(ctx context.Context, userID string, permission Permission) error {
+	roles, err := m.ListUserRoles(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if role.Grants(permission) {
+			return nil
+		}
+	}
+	return newCodedError(usvcErrors.ErrTypeForbidden, CodePermissionDenied, map[string]string{"permission": string(permission)},
+		"The permission %s is required to perform the operation.", permission)
+}
@@ -0,0 +1,215 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// testRepositories returns every Repository implementation, each backed by an empty store
+func testRepositories(t *testing.T) map[string]Repository {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening the database, err: %s", err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+	// Every connection to `:memory:` opens a database of its own
+	db.SetMaxOpenConns(1)
+	if err := MigrateSQLSchema(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]Repository{
+		"memory": NewMemoryRepository(),
+		"sql":    NewSQLRepository(db),
+	}
+}
+
+// errCode returns the code of the error, or an empty code if it is not an Error
+func errCode(err error) string {
+	if e, ok := usvcErrors.Convert(err); ok {
+		return e.Code()
+	}
+	return ""
+}
+
+// roleNames returns the names of the roles
+func roleNames(roles []*Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, r := range roles {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestCreateRole(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			m := NewManager(repo)
+
+			role, err := m.CreateRole(ctx, "deleter", []Permission{PermissionUsersRead, PermissionUsersDelete})
+			if err != nil {
+				t.Fatalf("CreateRole() err: %v", err)
+			}
+			got, err := m.GetRole(ctx, "deleter")
+			if err != nil || got.BuiltIn || !got.Grants(PermissionUsersDelete) || got.Grants(PermissionUsersList) {
+				t.Errorf("GetRole() = %+v, %v, want %+v", got, err, role)
+			}
+			if _, err := m.CreateRole(ctx, "deleter", []Permission{PermissionUsersRead}); errCode(err) != CodeRoleExists {
+				t.Errorf("CreateRole() of an existing role err: %v, want %s", err, CodeRoleExists)
+			}
+
+			roles, err := m.ListRoles(ctx)
+			if names := roleNames(roles); err != nil || len(names) != 3 || names[0] != RoleAdmin || names[1] != RoleViewer || names[2] != "deleter" {
+				t.Errorf("ListRoles() = %v, %v, want the built-in roles followed by deleter", names, err)
+			}
+		})
+	}
+}
+
+func TestCreateRoleInvalid(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(NewMemoryRepository())
+
+	tests := []struct {
+		name        string
+		roleName    string
+		permissions []Permission
+		wantCode    string
+		wantFields  []string
+	}{
+		{"invalid name", "Bad Role", []Permission{PermissionUsersRead}, usvcErrors.CodeInvalidFields, []string{"name"}},
+		{"no permissions", "empty", nil, usvcErrors.CodeInvalidFields, []string{"permissions"}},
+		{"unknown permission", "unknown", []Permission{"users:fly"}, usvcErrors.CodeInvalidFields, []string{"permissions"}},
+		{"built-in role", RoleAdmin, []Permission{PermissionUsersRead}, CodeBuiltInRole, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.CreateRole(ctx, tt.roleName, tt.permissions)
+			if errCode(err) != tt.wantCode {
+				t.Fatalf("CreateRole() err: %v, want %s", err, tt.wantCode)
+			}
+			e, _ := usvcErrors.Convert(err)
+			violations := e.Details()
+			if len(violations) != len(tt.wantFields) {
+				t.Fatalf("CreateRole() violations = %+v, want fields %v", violations, tt.wantFields)
+			}
+			for i, v := range violations {
+				if v.Field != tt.wantFields[i] {
+					t.Errorf("CreateRole() violation %d is of %s, want %s", i, v.Field, tt.wantFields[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteRole(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			m := NewManager(repo)
+			if _, err := m.CreateRole(ctx, "deleter", []Permission{PermissionUsersDelete}); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.BindRole(ctx, "u1", "deleter"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := m.DeleteRole(ctx, "deleter"); err != nil {
+				t.Fatalf("DeleteRole() err: %v", err)
+			}
+			// Deleting the role unbinds it
+			if roles, err := m.ListUserRoles(ctx, "u1"); err != nil || len(roles) != 0 {
+				t.Errorf("ListUserRoles() after DeleteRole() = %v, %v, want none", roleNames(roles), err)
+			}
+			if _, err := m.GetRole(ctx, "deleter"); errCode(err) != CodeRoleNotFound {
+				t.Errorf("GetRole() of a deleted role err: %v, want %s", err, CodeRoleNotFound)
+			}
+			if err := m.DeleteRole(ctx, "deleter"); errCode(err) != CodeRoleNotFound {
+				t.Errorf("DeleteRole() of a deleted role err: %v, want %s", err, CodeRoleNotFound)
+			}
+			if err := m.DeleteRole(ctx, RoleViewer); errCode(err) != CodeBuiltInRole {
+				t.Errorf("DeleteRole() of a built-in role err: %v, want %s", err, CodeBuiltInRole)
+			}
+		})
+	}
+}
+
+func TestBindRole(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			m := NewManager(repo)
+
+			if err := m.BindRole(ctx, "u1", RoleViewer); err != nil {
+				t.Fatalf("BindRole() err: %v", err)
+			}
+			if err := m.BindRole(ctx, "u1", RoleViewer); errCode(err) != CodeRoleBound {
+				t.Errorf("BindRole() of a bound role err: %v, want %s", err, CodeRoleBound)
+			}
+			if err := m.BindRole(ctx, "u1", "missing"); errCode(err) != CodeRoleNotFound {
+				t.Errorf("BindRole() of a missing role err: %v, want %s", err, CodeRoleNotFound)
+			}
+			if roles, err := m.ListUserRoles(ctx, "u1"); err != nil || len(roles) != 1 || roles[0].Name != RoleViewer {
+				t.Errorf("ListUserRoles() = %v, %v, want [%s]", roleNames(roles), err, RoleViewer)
+			}
+
+			if err := m.UnbindRole(ctx, "u1", RoleViewer); err != nil {
+				t.Fatalf("UnbindRole() err: %v", err)
+			}
+			if err := m.UnbindRole(ctx, "u1", RoleViewer); errCode(err) != CodeRoleNotBound {
+				t.Errorf("UnbindRole() of an unbound role err: %v, want %s", err, CodeRoleNotBound)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(NewMemoryRepository())
+	if _, err := m.CreateRole(ctx, "deleter", []Permission{PermissionUsersDelete}); err != nil {
+		t.Fatal(err)
+	}
+	bindings := map[string][]string{
+		"admin":  {RoleAdmin},
+		"viewer": {RoleViewer},
+		"both":   {RoleViewer, "deleter"},
+	}
+	for userID, roles := range bindings {
+		for _, role := range roles {
+			if err := m.BindRole(ctx, userID, role); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		userID     string
+		permission Permission
+		want       bool
+	}{
+		{"admin", PermissionRolesManage, true},
+		{"viewer", PermissionUsersList, true},
+		{"viewer", PermissionUsersDelete, false},
+		{"both", PermissionUsersDelete, true},
+		{"both", PermissionUsersList, true},
+		{"none", PermissionUsersRead, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.userID+"/"+string(tt.permission), func(t *testing.T) {
+			err := m.Authorize(ctx, tt.userID, tt.permission)
+			if tt.want && err != nil {
+				t.Errorf("Authorize() err: %v, want nil", err)
+			}
+			if !tt.want && errCode(err) != CodePermissionDenied {
+				t.Errorf("Authorize() err: %v, want %s", err, CodePermissionDenied)
+			}
+		})
+	}
+}
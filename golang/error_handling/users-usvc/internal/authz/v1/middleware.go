@@ -0,0 +1,49 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	authV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/auth/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// Require returns the middleware which only lets users with the given permission through, e.g.
+//
+//	r.Handle("/users/v1/", authzV1.Require(authz, authzV1.PermissionUsersList)(listHandler)).Methods(http.MethodGet)
+//
+// It must run after `authV1.Middleware`, which authenticates the user. Denials are reported as forbidden errors.
+func Require(m Manager, permission Permission) mux.MiddlewareFunc {
+	return require(m, permission, "")
+}
+
+// RequireOrSelf is like `Require` but also lets users through when the route variable `idVar` is their own ID,
+// e.g. so that users can read and update themselves without the permission to manage other users.
+func RequireOrSelf(m Manager, permission Permission, idVar string) mux.MiddlewareFunc {
+	return require(m, permission, idVar)
+}
+
+// require returns the middleware of `Require` and `RequireOrSelf`
+func require(m Manager, permission Permission, idVar string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := authV1.ClaimsFromContext(r.Context())
+			if !ok {
+				usvcResponse.Error(w, r, usvcErrors.NewCoded(usvcErrors.ErrTypeUnauthorized, authV1.CodeInvalidAccessToken, nil, "The request is not authenticated."))
+				return
+			}
+			if idVar != "" && mux.Vars(r)[idVar] == claims.Subject {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := m.Authorize(r.Context(), claims.Subject, permission); err != nil {
+				usvcResponse.Error(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+
+	authV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/auth/v1"
+)
+
+// tokenManager - an auth Manager whose access tokens are the IDs of the users
+type tokenManager struct {
+	authV1.Manager
+}
+
+// VerifyAccessToken - the implementation of the `VerifyAccessToken` method
+func (m *tokenManager) VerifyAccessToken(ctx context.Context, accessToken string) (*authV1.Claims, error) {
+	return &authV1.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: accessToken}}, nil
+}
+
+func TestRequire(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(NewMemoryRepository())
+	if err := m.BindRole(ctx, "viewer", RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	r := mux.NewRouter()
+	authed := r.NewRoute().Subrouter()
+	authed.Use(authV1.Middleware(&tokenManager{}))
+	authed.Handle("/users/{id}", RequireOrSelf(m, PermissionUsersRead, "id")(ok)).Methods(http.MethodGet)
+	authed.Handle("/users/{id}", Require(m, PermissionUsersDelete)(ok)).Methods(http.MethodDelete)
+	// Without the auth middleware the request is not authenticated
+	r.Handle("/unauthenticated", Require(m, PermissionUsersRead)(ok))
+
+	tests := []struct {
+		name, method, target, user string
+		wantStatus                 int
+	}{
+		{"granted", http.MethodGet, "/users/u1", "viewer", http.StatusOK},
+		{"denied", http.MethodGet, "/users/u1", "u2", http.StatusForbidden},
+		{"self", http.MethodGet, "/users/u2", "u2", http.StatusOK},
+		{"self without OrSelf", http.MethodDelete, "/users/u2", "u2", http.StatusForbidden},
+		{"not granted by the role", http.MethodDelete, "/users/u1", "viewer", http.StatusForbidden},
+		{"unauthenticated", http.MethodGet, "/unauthenticated", "viewer", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.user)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s %s as %s = %d %s, want %d", tt.method, tt.target, tt.user, rec.Code, rec.Body.String(), tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package v1
+
+import (
+	"time"
+)
+
+// Permission - an operation which can be granted to users through roles, in the form of `<resource>:<action>`
+type Permission string
+
+// Permissions
+const (
+	// PermissionAll - grants every permission
+	PermissionAll Permission = "*"
+
+	// PermissionUsersCreate - create users on behalf of others
+	PermissionUsersCreate Permission = "users:create"
+	// PermissionUsersRead - read any user
+	PermissionUsersRead Permission = "users:read"
+	// PermissionUsersList - list users
+	PermissionUsersList Permission = "users:list"
+	// PermissionUsersUpdate - update any user
+	PermissionUsersUpdate Permission = "users:update"
+	// PermissionUsersDelete - delete any user
+	PermissionUsersDelete Permission = "users:delete"
+	// PermissionUsersManageStatus - disable and enable users
+	PermissionUsersManageStatus Permission = "users:manage_status"
+	// PermissionUsersImport - create users in bulk from a file
+	PermissionUsersImport Permission = "users:import"
+	// PermissionUsersExport - export all users to a file
+	PermissionUsersExport Permission = "users:export"
+
+	// PermissionRolesManage - manage roles and bind them to users
+	PermissionRolesManage Permission = "roles:manage"
+
+	// PermissionAuditRead - query the audit log
+	PermissionAuditRead Permission = "audit:read"
+)
+
+// knownPermissions - the permissions which can be granted to custom roles
+var knownPermissions = map[Permission]bool{
+	PermissionAll:               true,
+	PermissionUsersCreate:       true,
+	PermissionUsersRead:         true,
+	PermissionUsersList:         true,
+	PermissionUsersUpdate:       true,
+	PermissionUsersDelete:       true,
+	PermissionUsersManageStatus: true,
+	PermissionUsersImport:       true,
+	PermissionUsersExport:       true,
+	PermissionRolesManage:       true,
+	PermissionAuditRead:         true,
+}
+
+// Role - a named set of permissions
+type Role struct {
+	Name        string       `json:"name"`
+	Permissions []Permission `json:"permissions"`
+	// BuiltIn tells whether the role is defined by the service. Built-in roles cannot be changed or deleted.
+	BuiltIn   bool      `json:"built_in"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Grants checks whether the role grants the permission
+func (r *Role) Grants(permission Permission) bool {
+	for _, p := range r.Permissions {
+		if p == PermissionAll || p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Built-in roles
+const (
+	// RoleAdmin - administrators, who have every permission
+	RoleAdmin = "admin"
+	// RoleViewer - support staff, who can read and list users
+	RoleViewer = "viewer"
+)
+
+// builtInRoles - the roles defined by the service
+var builtInRoles = map[string]*Role{
+	RoleAdmin: {
+		Name:        RoleAdmin,
+		Permissions: []Permission{PermissionAll},
+		BuiltIn:     true,
+	},
+	RoleViewer: {
+		Name:        RoleViewer,
+		Permissions: []Permission{PermissionUsersRead, PermissionUsersList},
+		BuiltIn:     true,
+	},
+}
+
+// Binding - a role bound to a user
+type Binding struct {
+	UserID    string
+	Role      string
+	CreatedAt time.Time
+}
@@ -0,0 +1,23 @@
+package v1
+
+import (
+	"testing"
+)
+
+func TestRoleGrants(t *testing.T) {
+	tests := []struct {
+		role       *Role
+		permission Permission
+		want       bool
+	}{
+		{builtInRoles[RoleAdmin], PermissionRolesManage, true},
+		{builtInRoles[RoleViewer], PermissionUsersRead, true},
+		{builtInRoles[RoleViewer], PermissionUsersUpdate, false},
+		{&Role{Name: "empty"}, PermissionUsersRead, false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Grants(tt.permission); got != tt.want {
+			t.Errorf("%s.Grants(%s) = %t, want %t", tt.role.Name, tt.permission, got, tt.want)
+		}
+	}
+}
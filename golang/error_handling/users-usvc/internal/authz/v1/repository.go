@@ -0,0 +1,28 @@
+package v1
+
+import (
+	"context"
+)
+
+// Repository defines the interface for persisting custom roles and role bindings. Built-in roles are not stored.
+// Like the user repository, it returns `userV1.ErrRecordNotFound` and `userV1.ErrDuplicateRecord`.
+//
+// Every method is scoped to the tenant in the context, see `tenant.FromContext`: role names are unique per tenant,
+// and the roles and bindings of other tenants are reported as ErrRecordNotFound, as if they did not exist.
+type Repository interface {
+	// CreateRole stores the given role. It returns ErrDuplicateRecord if the name has been used.
+	CreateRole(ctx context.Context, role *Role) error
+	// GetRole returns the role with the given name. It returns ErrRecordNotFound if no role matches.
+	GetRole(ctx context.Context, name string) (*Role, error)
+	// ListRoles returns all stored roles ordered by name
+	ListRoles(ctx context.Context) ([]*Role, error)
+	// DeleteRole removes the role with the given name and its bindings. It returns ErrRecordNotFound if the role does not exist.
+	DeleteRole(ctx context.Context, name string) error
+
+	// CreateBinding stores the given binding. It returns ErrDuplicateRecord if the role has been bound to the user.
+	CreateBinding(ctx context.Context, binding *Binding) error
+	// DeleteBinding removes the binding of the role to the user. It returns ErrRecordNotFound if the binding does not exist.
+	DeleteBinding(ctx context.Context, userID, role string) error
+	// ListBindings returns the bindings of the user ordered by role
+	ListBindings(ctx context.Context, userID string) ([]*Binding, error)
+}
@@ -0,0 +1,166 @@
+package v1
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// tenantKey - a key which is unique within a tenant, e.g. a role name
+type tenantKey struct {
+	tenant string
+	key    string
+}
+
+// memoryRepository is the implementation of Repository interface which keeps roles and bindings in memory.
+// It is meant for tests.
+type memoryRepository struct {
+	mu       sync.RWMutex
+	roles    map[tenantKey]*Role               // tenant, name -> role
+	bindings map[tenantKey]map[string]*Binding // tenant, user ID -> role -> binding
+}
+
+// NewMemoryRepository creates an instance of Repository which keeps roles and bindings in memory
+func NewMemoryRepository() Repository {
+	return &memoryRepository{
+		roles:    map[tenantKey]*Role{},
+		bindings: map[tenantKey]map[string]*Binding{},
+	}
+}
+
+// CreateRole - the implementation of the `CreateRole` method
+func (r *memoryRepository) CreateRole(ctx context.Context, role *Role) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := tenantKey{usvcTenant.FromContext(ctx), role.Name}
+	if _, ok := r.roles[key]; ok {
+		return userV1.ErrDuplicateRecord
+	}
+	r.roles[key] = copyRole(role)
+	return nil
+}
+
+// GetRole - the implementation of the `GetRole` method
+func (r *memoryRepository) GetRole(ctx context.Context, name string) (*Role, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	role, ok := r.roles[tenantKey{usvcTenant.FromContext(ctx), name}]
+	if !ok {
+		return nil, userV1.ErrRecordNotFound
+	}
+	return copyRole(role), nil
+}
+
+// ListRoles - the implementation of the `ListRoles` method
+func (r *memoryRepository) ListRoles(ctx context.Context) ([]*Role, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenant := usvcTenant.FromContext(ctx)
+	roles := []*Role{}
+	for key, role := range r.roles {
+		if key.tenant == tenant {
+			roles = append(roles, copyRole(role))
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+// DeleteRole - the implementation of the `DeleteRole` method
+func (r *memoryRepository) DeleteRole(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant := usvcTenant.FromContext(ctx)
+	if _, ok := r.roles[tenantKey{tenant, name}]; !ok {
+		return userV1.ErrRecordNotFound
+	}
+	delete(r.roles, tenantKey{tenant, name})
+	for key, bindings := range r.bindings {
+		if key.tenant == tenant {
+			delete(bindings, name)
+		}
+	}
+	return nil
+}
+
+// CreateBinding - the implementation of the `CreateBinding` method
+func (r *memoryRepository) CreateBinding(ctx context.Context, binding *Binding) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := tenantKey{usvcTenant.FromContext(ctx), binding.UserID}
+	bindings, ok := r.bindings[key]
+	if !ok {
+		bindings = map[string]*Binding{}
+		r.bindings[key] = bindings
+	}
+	if _, ok := bindings[binding.Role]; ok {
+		return userV1.ErrDuplicateRecord
+	}
+	b := *binding
+	bindings[binding.Role] = &b
+	return nil
+}
+
+// DeleteBinding - the implementation of the `DeleteBinding` method
+func (r *memoryRepository) DeleteBinding(ctx context.Context, userID, role string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := tenantKey{usvcTenant.FromContext(ctx), userID}
+	if _, ok := r.bindings[key][role]; !ok {
+		return userV1.ErrRecordNotFound
+	}
+	delete(r.bindings[key], role)
+	return nil
+}
+
+// ListBindings - the implementation of the `ListBindings` method
+func (r *memoryRepository) ListBindings(ctx context.Context, userID string) ([]*Binding, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored := r.bindings[tenantKey{usvcTenant.FromContext(ctx), userID}]
+	bindings := make([]*Binding, 0, len(stored))
+	for _, binding := range stored {
+		b := *binding
+		bindings = append(bindings, &b)
+	}
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].Role < bindings[j].Role })
+	return bindings, nil
+}
+
+// copyRole returns a deep copy of the given role so that callers cannot modify the stored one
+func copyRole(role *Role) *Role {
+	c := *role
+	c.Permissions = append([]Permission{}, role.Permissions...)
+	return &c
+}
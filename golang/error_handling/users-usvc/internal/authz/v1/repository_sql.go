@@ -0,0 +1,248 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	userV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/user/v1"
+	usvcSQLSchema "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/sqlschema"
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// rolesTable - the definition of the roles table
+const rolesTable = `(
+		tenant_id   VARCHAR(64)   NOT NULL DEFAULT 'default',
+		name        VARCHAR(64)   NOT NULL,
+		permissions VARCHAR(1024) NOT NULL,
+		created_at  DATETIME      NOT NULL,
+		PRIMARY KEY (tenant_id, name)
+	)`
+
+// roleBindingsTable - the definition of the role_bindings table
+const roleBindingsTable = `(
+		tenant_id  VARCHAR(64) NOT NULL DEFAULT 'default',
+		user_id    VARCHAR(64) NOT NULL,
+		role       VARCHAR(64) NOT NULL,
+		created_at DATETIME    NOT NULL,
+		PRIMARY KEY (tenant_id, user_id, role)
+	)`
+
+// sqlSchema - statements for creating the tables used by the SQL repository
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS roles ` + rolesTable,
+	`CREATE TABLE IF NOT EXISTS role_bindings ` + roleBindingsTable,
+}
+
+// MigrateSQLSchema creates the tables used by the SQL repository if they do not exist, and migrates the tables
+// created before roles were scoped to tenants. Their rows are moved to the default tenant.
+func MigrateSQLSchema(ctx context.Context, db *sql.DB) error {
+	for _, stmt := range sqlSchema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("error migrating the authz schema, err: %s", err.Error())
+		}
+	}
+	if err := migrateTenantSQLSchema(ctx, db); err != nil {
+		return fmt.Errorf("error migrating the authz schema, err: %s", err.Error())
+	}
+	return nil
+}
+
+// migrateTenantSQLSchema rebuilds the tables created before roles were scoped to tenants, as their primary keys
+// must include the tenant
+func migrateTenantSQLSchema(ctx context.Context, db *sql.DB) error {
+	rebuilds := []struct {
+		table, definition, columns string
+	}{
+		{"roles", rolesTable, `name, permissions, created_at`},
+		{"role_bindings", roleBindingsTable, `user_id, role, created_at`},
+	}
+	for _, rebuild := range rebuilds {
+		ok, err := usvcSQLSchema.HasColumn(ctx, db, rebuild.table, "tenant_id")
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		if err := usvcSQLSchema.RebuildTable(ctx, db, rebuild.table, rebuild.definition, rebuild.columns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqlRepository is the implementation of Repository interface backed by `database/sql`.
+// It works with both SQLite and MySQL; MySQL DSNs need `parseTime=true`.
+// Every query is conditioned on the tenant in the context.
+type sqlRepository struct {
+	db *sql.DB
+}
+
+// NewSQLRepository creates an instance of Repository which stores roles and bindings in the given database
+func NewSQLRepository(db *sql.DB) Repository {
+	return &sqlRepository{
+		db: db,
+	}
+}
+
+// CreateRole - the implementation of the `CreateRole` method
+func (r *sqlRepository) CreateRole(ctx context.Context, role *Role) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO roles (tenant_id, name, permissions, created_at) VALUES (?, ?, ?, ?)`,
+		usvcTenant.FromContext(ctx), role.Name, joinPermissions(role.Permissions), role.CreatedAt,
+	)
+	if err != nil {
+		if isDuplicateKeyErr(err) {
+			return fmt.Errorf("%w: %w", userV1.ErrDuplicateRecord, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// GetRole - the implementation of the `GetRole` method
+func (r *sqlRepository) GetRole(ctx context.Context, name string) (*Role, error) {
+	role, err := scanRole(r.db.QueryRowContext(ctx, `SELECT name, permissions, created_at FROM roles WHERE tenant_id = ? AND name = ?`, usvcTenant.FromContext(ctx), name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, userV1.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// ListRoles - the implementation of the `ListRoles` method
+func (r *sqlRepository) ListRoles(ctx context.Context) ([]*Role, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT name, permissions, created_at FROM roles WHERE tenant_id = ? ORDER BY name`, usvcTenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []*Role{}
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// DeleteRole - the implementation of the `DeleteRole` method
+func (r *sqlRepository) DeleteRole(ctx context.Context, name string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tenantID := usvcTenant.FromContext(ctx)
+	res, err := tx.ExecContext(ctx, `DELETE FROM roles WHERE tenant_id = ? AND name = ?`, tenantID, name)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return userV1.ErrRecordNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM role_bindings WHERE tenant_id = ? AND role = ?`, tenantID, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateBinding - the implementation of the `CreateBinding` method
+func (r *sqlRepository) CreateBinding(ctx context.Context, binding *Binding) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO role_bindings (tenant_id, user_id, role, created_at) VALUES (?, ?, ?, ?)`,
+		usvcTenant.FromContext(ctx), binding.UserID, binding.Role, binding.CreatedAt,
+	)
+	if err != nil {
+		if isDuplicateKeyErr(err) {
+			return fmt.Errorf("%w: %w", userV1.ErrDuplicateRecord, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteBinding - the implementation of the `DeleteBinding` method
+func (r *sqlRepository) DeleteBinding(ctx context.Context, userID, role string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM role_bindings WHERE tenant_id = ? AND user_id = ? AND role = ?`, usvcTenant.FromContext(ctx), userID, role)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return userV1.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListBindings - the implementation of the `ListBindings` method
+func (r *sqlRepository) ListBindings(ctx context.Context, userID string) ([]*Binding, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT user_id, role, created_at FROM role_bindings WHERE tenant_id = ? AND user_id = ? ORDER BY role`,
+		usvcTenant.FromContext(ctx), userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bindings := []*Binding{}
+	for rows.Next() {
+		b := &Binding{}
+		if err := rows.Scan(&b.UserID, &b.Role, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings, rows.Err()
+}
+
+// scanner is implemented by both `*sql.Row` and `*sql.Rows`
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRole reads a role from a row
+func scanRole(s scanner) (*Role, error) {
+	role := &Role{}
+	var permissions string
+	if err := s.Scan(&role.Name, &permissions, &role.CreatedAt); err != nil {
+		return nil, err
+	}
+	for _, p := range strings.Fields(permissions) {
+		role.Permissions = append(role.Permissions, Permission(p))
+	}
+	return role, nil
+}
+
+// joinPermissions encodes permissions as a space separated list
+func joinPermissions(permissions []Permission) string {
+	s := make([]string, len(permissions))
+	for i, p := range permissions {
+		s[i] = string(p)
+	}
+	return strings.Join(s, " ")
+}
+
+// isDuplicateKeyErr checks whether the given error is a unique constraint violation reported by SQLite or MySQL
+func isDuplicateKeyErr(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed") || // SQLite
+		strings.Contains(msg, "Error 1062") // MySQL: ER_DUP_ENTRY
+}
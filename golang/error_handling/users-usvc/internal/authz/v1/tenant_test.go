@@ -0,0 +1,99 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+func TestManagerTenantIsolation(t *testing.T) {
+	ctx := context.Background()
+	acme := usvcTenant.WithID(ctx, "acme")
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			m := NewManager(repo)
+			if _, err := m.CreateRole(acme, "deleter", []Permission{PermissionUsersDelete}); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.BindRole(acme, "u1", "deleter"); err != nil {
+				t.Fatal(err)
+			}
+
+			// The role and the binding of acme do not exist in the default tenant
+			if _, err := m.GetRole(ctx, "deleter"); errCode(err) != CodeRoleNotFound {
+				t.Errorf("GetRole() of the role of acme err: %v, want %s", err, CodeRoleNotFound)
+			}
+			if roles, err := m.ListRoles(ctx); err != nil || len(roles) != len(builtInRoles) {
+				t.Errorf("ListRoles() = %v, %v, want the built-in roles", roleNames(roles), err)
+			}
+			if err := m.Authorize(ctx, "u1", PermissionUsersDelete); errCode(err) != CodePermissionDenied {
+				t.Errorf("Authorize() with the binding of acme err: %v, want %s", err, CodePermissionDenied)
+			}
+			if err := m.UnbindRole(ctx, "u1", "deleter"); errCode(err) != CodeRoleNotBound {
+				t.Errorf("UnbindRole() of the binding of acme err: %v, want %s", err, CodeRoleNotBound)
+			}
+
+			// Role names are unique per tenant, and deleting a role does not touch the other tenants
+			if _, err := m.CreateRole(ctx, "deleter", []Permission{PermissionUsersRead}); err != nil {
+				t.Errorf("CreateRole() with the name of a role of acme err: %v", err)
+			}
+			if err := m.DeleteRole(ctx, "deleter"); err != nil {
+				t.Fatalf("DeleteRole() err: %v", err)
+			}
+			if err := m.Authorize(acme, "u1", PermissionUsersDelete); err != nil {
+				t.Errorf("Authorize() in acme after the role of the default tenant was deleted err: %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrateSQLSchemaFromUntenantedSchema(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// The schema before roles were scoped to tenants
+	now := time.Now().UTC()
+	for _, stmt := range []string{
+		`CREATE TABLE roles (name VARCHAR(64) NOT NULL PRIMARY KEY, permissions VARCHAR(1024) NOT NULL, created_at DATETIME NOT NULL)`,
+		`CREATE TABLE role_bindings (user_id VARCHAR(64) NOT NULL, role VARCHAR(64) NOT NULL, created_at DATETIME NOT NULL,
+			PRIMARY KEY (user_id, role))`,
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO roles (name, permissions, created_at) VALUES ('deleter', 'users:delete', ?)`, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO role_bindings (user_id, role, created_at) VALUES ('u1', 'deleter', ?)`, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// Migrating again changes nothing
+	for i := 0; i < 2; i++ {
+		if err := MigrateSQLSchema(ctx, db); err != nil {
+			t.Fatalf("MigrateSQLSchema() err: %v", err)
+		}
+	}
+
+	m := NewManager(NewSQLRepository(db))
+	if err := m.Authorize(ctx, "u1", PermissionUsersDelete); err != nil {
+		t.Errorf("Authorize() with the migrated binding err: %v", err)
+	}
+	// The role name is now unique per tenant
+	acme := usvcTenant.WithID(ctx, "acme")
+	if _, err := m.CreateRole(acme, "deleter", []Permission{PermissionUsersRead}); err != nil {
+		t.Errorf("CreateRole() with the name in another tenant err: %v", err)
+	}
+	if err := m.BindRole(acme, "u1", "deleter"); err != nil {
+		t.Errorf("BindRole() of the same user and role in another tenant err: %v", err)
+	}
+}
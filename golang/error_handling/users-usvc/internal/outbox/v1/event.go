@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	usvcTimeID "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/timeid"
+)
+
+// Event - a domain event stored in the outbox. Events are written in the same transaction as the change they
+// describe, and the Relay delivers them to a Publisher afterwards, so no event is lost if the service dies
+// between the two steps.
+type Event struct {
+	// ID starts with the creation time, so events sort in the order they happened. Consumers use it to drop
+	// duplicates, as events are delivered at least once.
+	ID string `json:"id"`
+	// Type is what happened, e.g. `user.created`
+	Type string `json:"type"`
+	// AggregateID is the ID of the changed record, e.g. the user ID
+	AggregateID string `json:"aggregate_id"`
+	// Payload is the JSON encoded state of the record after the change
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	// PublishedAt is set when the event has been delivered
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// NewEvent creates an event of the given type with the JSON encoded payload
+func NewEvent(eventType, aggregateID string, payload interface{}) (*Event, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding the payload of the %s event, err: %s", eventType, err.Error())
+	}
+	now := time.Now().UTC()
+	ID, err := usvcTimeID.New(now)
+	if err != nil {
+		return nil, fmt.Errorf("error generating an event ID, err: %s", err.Error())
+	}
+	return &Event{
+		ID:          ID,
+		Type:        eventType,
+		AggregateID: aggregateID,
+		Payload:     b,
+		CreatedAt:   now,
+	}, nil
+}
+
+// Store defines the interface for reading the outbox. It is implemented by the repositories which write events
+// along with their records, e.g. the user repository.
+type Store interface {
+	// ListPendingEvents returns at most `limit` events which have not been published, from the oldest to the newest
+	ListPendingEvents(ctx context.Context, limit int) ([]*Event, error)
+	// MarkEventsPublished marks the events with the given IDs as published
+	MarkEventsPublished(ctx context.Context, IDs []string, publishedAt time.Time) error
+	// DeletePublishedEvents removes the events published before the given time
+	DeletePublishedEvents(ctx context.Context, before time.Time) error
+}
+
+// Publisher defines the interface for delivering events to other services, e.g. through a message broker
+type Publisher interface {
+	// Publish delivers the event. The event is retried if an error is returned.
+	Publish(ctx context.Context, event *Event) error
+}
@@ -0,0 +1,83 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Handler handles an event delivered by the InProcessPublisher. The event is retried if an error is returned.
+type Handler func(ctx context.Context, event *Event) error
+
+// InProcessPublisher is the implementation of Publisher interface which delivers events to handlers in the same
+// process. It is meant for local testing and for reacting to events inside the service.
+type InProcessPublisher struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler // event type -> handlers; "" subscribes to all events
+}
+
+// NewInProcessPublisher creates an instance of InProcessPublisher without handlers
+func NewInProcessPublisher() *InProcessPublisher {
+	return &InProcessPublisher{
+		handlers: map[string][]Handler{},
+	}
+}
+
+// Subscribe registers the handler for events of the given type, or for all events if the type is empty
+func (p *InProcessPublisher) Subscribe(eventType string, h Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.handlers[eventType] = append(p.handlers[eventType], h)
+}
+
+// Publish - the implementation of the `Publish` method. Handlers run one by one; if one fails, the event is retried
+// with all of them, so handlers should be idempotent.
+func (p *InProcessPublisher) Publish(ctx context.Context, event *Event) error {
+	p.mu.RLock()
+	handlers := append(append([]Handler{}, p.handlers[""]...), p.handlers[event.Type]...)
+	p.mu.RUnlock()
+
+	for _, h := range handlers {
+		if err := h(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filePublisher is the implementation of Publisher interface which appends events to a JSON-lines file, one event
+// per line. It is meant for local testing, e.g. `tail -f` the file to watch the events.
+type filePublisher struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFilePublisher creates an instance of Publisher which appends events to the file at the given path
+func NewFilePublisher(path string) (Publisher, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the event file %s, err: %s", path, err.Error())
+	}
+	return &filePublisher{f: f}, nil
+}
+
+// Publish - the implementation of the `Publish` method
+func (p *filePublisher) Publish(ctx context.Context, event *Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return p.f.Sync()
+}
@@ -0,0 +1,84 @@
+package v1
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInProcessPublisher(t *testing.T) {
+	ctx := context.Background()
+	p := NewInProcessPublisher()
+	var got []string
+	p.Subscribe("", func(ctx context.Context, e *Event) error {
+		got = append(got, "all:"+e.Type)
+		return nil
+	})
+	p.Subscribe("user.created", func(ctx context.Context, e *Event) error {
+		got = append(got, "created:"+e.AggregateID)
+		return nil
+	})
+
+	for _, eventType := range []string{"user.created", "user.deleted"} {
+		e, err := NewEvent(eventType, "u1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Publish(ctx, e); err != nil {
+			t.Fatalf("Publish() err: %v", err)
+		}
+	}
+	want := []string{"all:user.created", "created:u1", "all:user.deleted"}
+	if len(got) != len(want) {
+		t.Fatalf("the handled events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("the handled events = %v, want %v", got, want)
+		}
+	}
+
+	p.Subscribe("user.deleted", func(ctx context.Context, e *Event) error { return errors.New("handler failed") })
+	e, _ := NewEvent("user.deleted", "u1", nil)
+	if err := p.Publish(ctx, e); err == nil {
+		t.Error("Publish() with a failing handler err: nil, want the error so that the event is retried")
+	}
+}
+
+func TestFilePublisher(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	p, err := NewFilePublisher(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEvent("user.created", "u1", map[string]string{"email": "ann@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := p.Publish(ctx, e); err != nil {
+			t.Fatalf("Publish() err: %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(f); scanner.Scan(); lines++ {
+		got := &Event{}
+		if err := json.Unmarshal(scanner.Bytes(), got); err != nil || got.ID != e.ID || string(got.Payload) != `{"email":"ann@example.com"}` {
+			t.Errorf("line %d = %s, want the event, err: %v", lines, scanner.Text(), err)
+		}
+	}
+	if lines != 2 {
+		t.Errorf("the file has %d lines, want one per event", lines)
+	}
+}
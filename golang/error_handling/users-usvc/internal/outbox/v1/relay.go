@@ -0,0 +1,130 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Default relay settings
+const (
+	// DefaultPollInterval - how often the relay polls the outbox by default
+	DefaultPollInterval = time.Second
+	// DefaultBatchSize - how many events the relay reads from the outbox at a time by default
+	DefaultBatchSize = 100
+	// DefaultRetention - how long published events are kept by default
+	DefaultRetention = 7 * 24 * time.Hour
+)
+
+// Relay delivers the events in the outbox to a publisher, in the order they were written. An event is marked as
+// published only after the publisher accepts it, so events are delivered at least once: if the service dies after
+// publishing an event but before marking it, the event is published again. Running a relay in several instances of
+// the service is safe but delivers more duplicates.
+type Relay struct {
+	store        Store
+	publisher    Publisher
+	pollInterval time.Duration
+	batchSize    int
+	retention    time.Duration
+}
+
+// RelayOption configures optional settings of a Relay
+type RelayOption func(r *Relay)
+
+// WithPollInterval sets how often the outbox is polled. It is 1 second if it is not set.
+func WithPollInterval(interval time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.pollInterval = interval
+	}
+}
+
+// WithBatchSize sets how many events are read from the outbox at a time. It is 100 if it is not set.
+func WithBatchSize(size int) RelayOption {
+	return func(r *Relay) {
+		r.batchSize = size
+	}
+}
+
+// WithRetention sets how long published events are kept, e.g. to replay them. It is 7 days if it is not set.
+func WithRetention(retention time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.retention = retention
+	}
+}
+
+// NewRelay creates a relay which delivers the events in the store to the publisher
+func NewRelay(store Store, publisher Publisher, opts ...RelayOption) *Relay {
+	r := &Relay{
+		store:        store,
+		publisher:    publisher,
+		pollInterval: DefaultPollInterval,
+		batchSize:    DefaultBatchSize,
+		retention:    DefaultRetention,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run relays events until the context is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Time{}
+	for {
+		// Drain the outbox before waiting for the next tick, e.g. after a burst of changes
+		for {
+			n, err := r.RelayOnce(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Printf("[outbox_v1] error relaying events, err: %s", err.Error())
+				break
+			}
+			if n < r.batchSize {
+				break
+			}
+		}
+		if time.Since(lastCleanup) > time.Hour {
+			if err := r.store.DeletePublishedEvents(ctx, time.Now().UTC().Add(-r.retention)); err != nil {
+				log.Printf("[outbox_v1] error deleting published events, err: %s", err.Error())
+			}
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publishes a batch of pending events and returns how many were published. It stops at the first event
+// which fails so that events are delivered in order; the event is retried in the next batch.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	events, err := r.store.ListPendingEvents(ctx, r.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var published []string
+	var publishErr error
+	for _, e := range events {
+		if err := r.publisher.Publish(ctx, e); err != nil {
+			publishErr = fmt.Errorf("error publishing event %s (%s), err: %s", e.ID, e.Type, err.Error())
+			break
+		}
+		published = append(published, e.ID)
+	}
+	if len(published) > 0 {
+		if err := r.store.MarkEventsPublished(ctx, published, time.Now().UTC()); err != nil {
+			return 0, err
+		}
+	}
+	return len(published), publishErr
+}
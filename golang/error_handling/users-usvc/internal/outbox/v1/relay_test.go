@@ -0,0 +1,192 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore - a Store which keeps events in memory, in the order they were added
+type memoryStore struct {
+	mu      sync.Mutex
+	events  []*Event
+	markErr error
+}
+
+// ListPendingEvents - the implementation of the `ListPendingEvents` method
+func (s *memoryStore) ListPendingEvents(ctx context.Context, limit int) ([]*Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var pending []*Event
+	for _, e := range s.events {
+		if e.PublishedAt == nil && len(pending) < limit {
+			pending = append(pending, e)
+		}
+	}
+	return pending, nil
+}
+
+// MarkEventsPublished - the implementation of the `MarkEventsPublished` method
+func (s *memoryStore) MarkEventsPublished(ctx context.Context, IDs []string, publishedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.markErr != nil {
+		return s.markErr
+	}
+	for _, e := range s.events {
+		for _, ID := range IDs {
+			if e.ID == ID {
+				t := publishedAt
+				e.PublishedAt = &t
+			}
+		}
+	}
+	return nil
+}
+
+// DeletePublishedEvents - the implementation of the `DeletePublishedEvents` method
+func (s *memoryStore) DeletePublishedEvents(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.events[:0]
+	for _, e := range s.events {
+		if e.PublishedAt == nil || !e.PublishedAt.Before(before) {
+			kept = append(kept, e)
+		}
+	}
+	s.events = kept
+	return nil
+}
+
+// pending returns the number of events which have not been published
+func (s *memoryStore) pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, e := range s.events {
+		if e.PublishedAt == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// newTestStore creates a store with n pending events of the aggregates `a0`, `a1`, ...
+func newTestStore(t *testing.T, n int) *memoryStore {
+	t.Helper()
+	s := &memoryStore{}
+	for i := 0; i < n; i++ {
+		e, err := NewEvent("user.created", fmt.Sprintf("a%d", i), map[string]int{"i": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.events = append(s.events, e)
+	}
+	return s
+}
+
+// recordingPublisher - a Publisher which records the aggregates of the events it accepts and fails on the aggregate
+// in `failOn`
+type recordingPublisher struct {
+	mu         sync.Mutex
+	aggregates []string
+	failOn     string
+}
+
+// Publish - the implementation of the `Publish` method
+func (p *recordingPublisher) Publish(ctx context.Context, event *Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if event.AggregateID == p.failOn {
+		return errors.New("broker unavailable")
+	}
+	p.aggregates = append(p.aggregates, event.AggregateID)
+	return nil
+}
+
+func TestRelayOnce(t *testing.T) {
+	ctx := context.Background()
+	store, publisher := newTestStore(t, 3), &recordingPublisher{}
+	r := NewRelay(store, publisher, WithBatchSize(2))
+
+	if n, err := r.RelayOnce(ctx); n != 2 || err != nil {
+		t.Fatalf("RelayOnce() = %d, %v, want a batch of 2", n, err)
+	}
+	if n, err := r.RelayOnce(ctx); n != 1 || err != nil {
+		t.Fatalf("RelayOnce() = %d, %v, want the last event", n, err)
+	}
+	if n, err := r.RelayOnce(ctx); n != 0 || err != nil {
+		t.Errorf("RelayOnce() of an empty outbox = %d, %v, want 0", n, err)
+	}
+	if fmt.Sprint(publisher.aggregates) != "[a0 a1 a2]" || store.pending() != 0 {
+		t.Errorf("the published events = %v with %d pending, want [a0 a1 a2] in order", publisher.aggregates, store.pending())
+	}
+}
+
+func TestRelayOnceStopsAtFailedEvent(t *testing.T) {
+	ctx := context.Background()
+	store, publisher := newTestStore(t, 3), &recordingPublisher{failOn: "a1"}
+	r := NewRelay(store, publisher)
+
+	// Later events wait for the failed one, so events are delivered in order
+	if n, err := r.RelayOnce(ctx); n != 1 || err == nil {
+		t.Fatalf("RelayOnce() = %d, %v, want 1 published and an error", n, err)
+	}
+	if store.pending() != 2 {
+		t.Errorf("%d events are pending, want the failed event and the one after it", store.pending())
+	}
+
+	publisher.failOn = ""
+	if n, err := r.RelayOnce(ctx); n != 2 || err != nil {
+		t.Fatalf("RelayOnce() retried = %d, %v, want the 2 pending events", n, err)
+	}
+	if fmt.Sprint(publisher.aggregates) != "[a0 a1 a2]" {
+		t.Errorf("the published events = %v, want [a0 a1 a2]", publisher.aggregates)
+	}
+}
+
+func TestRelayOnceRepublishesUnmarkedEvents(t *testing.T) {
+	ctx := context.Background()
+	store, publisher := newTestStore(t, 1), &recordingPublisher{}
+	store.markErr = errors.New("database unavailable")
+	r := NewRelay(store, publisher)
+
+	if _, err := r.RelayOnce(ctx); err == nil {
+		t.Fatal("RelayOnce() err: nil, want the error of marking the events")
+	}
+	// Events are delivered at least once: the event is published again as it was not marked
+	store.markErr = nil
+	if n, err := r.RelayOnce(ctx); n != 1 || err != nil {
+		t.Fatalf("RelayOnce() = %d, %v, want the event published again", n, err)
+	}
+	if fmt.Sprint(publisher.aggregates) != "[a0 a0]" {
+		t.Errorf("the published events = %v, want a0 twice", publisher.aggregates)
+	}
+}
+
+func TestRelayRun(t *testing.T) {
+	store, publisher := newTestStore(t, 5), &recordingPublisher{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewRelay(store, publisher, WithBatchSize(2), WithPollInterval(time.Millisecond)).Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for store.pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after the context was canceled")
+	}
+	if store.pending() != 0 {
+		t.Errorf("%d events are pending after Run(), want none", store.pending())
+	}
+}
@@ -0,0 +1,63 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// ActivateUser - the implementation of the `ActivateUser` method
+func (m *manager) ActivateUser(ctx context.Context, token string) (*User, error) {
+	c, err := m.verifyToken(token, TokenPurposeActivation)
+	if err != nil {
+		return nil, err
+	}
+	ctx = usvcTenant.WithID(ctx, c.TenantID)
+
+	user, err := m.Get(ctx, c.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != UserStatusPending {
+		return nil, newCodedError(ErrTypeConflict, CodeInvalidStatusTransition, map[string]string{"id": user.ID, "status": string(user.Status)},
+			"The user %s is %s and cannot be activated.", user.ID, user.Status)
+	}
+
+	if err := m.consumeToken(ctx, c); err != nil {
+		return nil, err
+	}
+	return m.transition(ctx, AuditActionActivate, user, UserStatusActive)
+}
+
+// SendActivationEmail - the implementation of the `SendActivationEmail` method
+func (m *manager) SendActivationEmail(ctx context.Context, ID string) error {
+	user, err := m.Get(ctx, ID)
+	if err != nil {
+		return err
+	}
+	if user.Status != UserStatusPending {
+		return newCodedError(ErrTypeConflict, CodeInvalidStatusTransition, map[string]string{"id": user.ID, "status": string(user.Status)},
+			"The user %s is %s and cannot be activated.", user.ID, user.Status)
+	}
+	return m.sendActivationEmail(ctx, user)
+}
+
+// sendActivationEmail issues an activation token for the user and mails it
+func (m *manager) sendActivationEmail(ctx context.Context, user *User) error {
+	token, err := m.issueToken(ctx, user.ID, TokenPurposeActivation, m.activationTTL)
+	if err != nil {
+		return newInternalError(err, "Error issuing the activation token of user %s", user.ID)
+	}
+
+	err = m.mailer.Send(ctx, &Message{
+		To:      user.Email,
+		Subject: "Activate your account",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease verify your email and activate your account with the link below. "+
+			"It expires in %s.\n\n%s", user.FirstName, m.activationTTL, tokenLink(m.activationURL, token)),
+	})
+	if err != nil {
+		return newInternalError(err, "Error sending the activation email to user %s", user.ID)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package v1
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testActivationURL and testPasswordResetURL - the pages which the links in test emails point to
+const (
+	testActivationURL    = "https://example.com/activate"
+	testPasswordResetURL = "https://example.com/reset-password"
+)
+
+// recordingMailer - a Mailer which records the messages sent
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []*Message
+}
+
+// Send - the implementation of the `Send` method
+func (m *recordingMailer) Send(ctx context.Context, msg *Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// lastToken returns the token in the link of the last message sent to the given address
+func (m *recordingMailer) lastToken(t *testing.T, to string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		msg := m.messages[i]
+		if msg.To != to {
+			continue
+		}
+		_, link, ok := strings.Cut(msg.Body, "?token=")
+		if !ok {
+			t.Fatalf("the message to %s has no token link: %s", to, msg.Body)
+		}
+		token, err := url.QueryUnescape(strings.Fields(link)[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	t.Fatalf("no message has been sent to %s", to)
+	return ""
+}
+
+// newTestMailManager creates a manager which records the emails it sends
+func newTestMailManager(t *testing.T, repo Repository, opts ...Option) (Manager, *recordingMailer) {
+	t.Helper()
+	mailer := &recordingMailer{}
+	opts = append([]Option{WithMailer(mailer), WithActivationURL(testActivationURL), WithPasswordResetURL(testPasswordResetURL)}, opts...)
+	return newTestManager(t, repo, opts...), mailer
+}
+
+// errCode returns the code of the error, or an empty code if it is not an Error
+func errCode(err error) string {
+	if e, ok := ConvertError(err); ok {
+		return e.Code()
+	}
+	return ""
+}
+
+func TestActivateUser(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			m, mailer := newTestMailManager(t, repo)
+			ID := mustCreate(t, ctx, m, "ann@example.com")
+			token := mailer.lastToken(t, "ann@example.com")
+
+			user, err := m.ActivateUser(ctx, token)
+			if err != nil {
+				t.Fatalf("ActivateUser() err: %v", err)
+			}
+			if user.ID != ID || user.Status != UserStatusActive {
+				t.Errorf("ActivateUser() = %+v, want user %s active", user, ID)
+			}
+			if _, err := m.VerifyCredentials(ctx, "ann@example.com", testPassword); err != nil {
+				t.Errorf("VerifyCredentials() of the activated user err: %v", err)
+			}
+
+			if _, err := m.ActivateUser(ctx, token); errCode(err) != CodeInvalidStatusTransition {
+				t.Errorf("ActivateUser() of an active user err: %v, want %s", err, CodeInvalidStatusTransition)
+			}
+			if err := m.SendActivationEmail(ctx, ID); errCode(err) != CodeInvalidStatusTransition {
+				t.Errorf("SendActivationEmail() to an active user err: %v, want %s", err, CodeInvalidStatusTransition)
+			}
+		})
+	}
+}
+
+func TestActivateUserInvalidTokens(t *testing.T) {
+	ctx := context.Background()
+	m, mailer := newTestMailManager(t, nil)
+	mustCreate(t, ctx, m, "ann@example.com")
+	token := mailer.lastToken(t, "ann@example.com")
+
+	requestPasswordReset(t, ctx, m, "ann@example.com")
+	resetToken := mailer.lastToken(t, "ann@example.com")
+
+	other, otherMailer := newTestMailManager(t, nil, WithTokenSecret([]byte("another secret")))
+	mustCreate(t, ctx, other, "ann@example.com")
+
+	tests := map[string]string{
+		"empty":                 "",
+		"malformed":             "not-a-token",
+		"tampered":              token[:len(token)-2] + "xx",
+		"password reset token":  resetToken,
+		"signed by another key": otherMailer.lastToken(t, "ann@example.com"),
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := m.ActivateUser(ctx, token); errCode(err) != CodeInvalidToken {
+				t.Errorf("ActivateUser() err: %v, want %s", err, CodeInvalidToken)
+			}
+		})
+	}
+}
+
+func TestActivateUserExpiredToken(t *testing.T) {
+	ctx := context.Background()
+	m, mailer := newTestMailManager(t, nil, WithActivationTokenTTL(-time.Minute))
+	ID := mustCreate(t, ctx, m, "ann@example.com")
+
+	if _, err := m.ActivateUser(ctx, mailer.lastToken(t, "ann@example.com")); errCode(err) != CodeTokenExpired {
+		t.Errorf("ActivateUser() with an expired token err: %v, want %s", err, CodeTokenExpired)
+	}
+	if user, _ := m.Get(ctx, ID); user.Status != UserStatusPending {
+		t.Errorf("the status after an expired activation = %s, want %s", user.Status, UserStatusPending)
+	}
+}
+
+func TestSendActivationEmail(t *testing.T) {
+	ctx := context.Background()
+	m, mailer := newTestMailManager(t, nil)
+	ID := mustCreate(t, ctx, m, "ann@example.com")
+	first := mailer.lastToken(t, "ann@example.com")
+
+	if err := m.SendActivationEmail(ctx, ID); err != nil {
+		t.Fatalf("SendActivationEmail() err: %v", err)
+	}
+	second := mailer.lastToken(t, "ann@example.com")
+	if second == first {
+		t.Fatal("SendActivationEmail() sent the same token again")
+	}
+	if _, err := m.ActivateUser(ctx, second); err != nil {
+		t.Errorf("ActivateUser() with the new token err: %v", err)
+	}
+	if err := m.SendActivationEmail(ctx, "missing"); errType(err) != ErrTypeNotFound {
+		t.Errorf("SendActivationEmail() to a missing user err: %v, want %s", err, ErrTypeNotFound)
+	}
+}
@@ -0,0 +1,39 @@
+package v1
+
+import (
+	"context"
+
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditActionCreate         = "user.create"
+	AuditActionUpdate         = "user.update"
+	AuditActionDelete         = "user.delete"
+	AuditActionHardDelete     = "user.hard_delete"
+	AuditActionActivate       = "user.activate"
+	AuditActionSetStatus      = "user.set_status"
+	AuditActionResetPassword  = "user.reset_password"
+	AuditActionChangePassword = "user.change_password"
+	AuditActionImport         = "user.import"
+)
+
+// newAuditRecord creates the record of the change in the audit log. `before` is nil for created users and `after` is
+// nil for hard deleted users. The record is passed to the repository along with the change, which stores both in one
+// transaction, so a change is never stored without its record. The actor and the request ID of the record are read
+// from the context, see `auditV1.WithActor`.
+func newAuditRecord(ctx context.Context, action string, before, after *User) (*auditV1.Record, error) {
+	targetID := ""
+	if after != nil {
+		targetID = after.ID
+	} else if before != nil {
+		targetID = before.ID
+	}
+
+	record, err := auditV1.NewRecord(ctx, action, targetID, before, after)
+	if err != nil {
+		return nil, newInternalError(err, "Error creating the audit record of %s of user %s", action, targetID)
+	}
+	return record, nil
+}
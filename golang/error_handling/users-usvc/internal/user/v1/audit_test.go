@@ -0,0 +1,171 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// newTestAuditLog creates an audit log in a temporary file
+func newTestAuditLog(t *testing.T) auditV1.Store {
+	t.Helper()
+	store, err := auditV1.NewFileStore(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// auditedRepository - a repository and the audit log which it records changes in
+type auditedRepository struct {
+	repo     Repository
+	auditLog auditV1.Store
+}
+
+// testAuditedRepositories returns every Repository implementation which records changes, each backed by an empty
+// store and an empty audit log
+func testAuditedRepositories(t *testing.T) map[string]auditedRepository {
+	t.Helper()
+	fileLog := newTestAuditLog(t)
+	db := newTestDB(t)
+	return map[string]auditedRepository{
+		"memory": {NewMemoryRepository(WithMemoryAuditLog(fileLog)), fileLog},
+		"sql":    {NewSQLRepository(db), auditV1.NewSQLStore(db)},
+	}
+}
+
+func TestManagerRecordsChanges(t *testing.T) {
+	for name, audited := range testAuditedRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			m := newTestManager(t, audited.repo)
+			ctx := auditV1.WithRequestID(context.Background(), "req-1")
+			ID := mustCreate(t, ctx, m, "ann@example.com")
+
+			admin := auditV1.WithActor(ctx, "admin-1")
+			if _, err := m.SetStatus(admin, ID, UserStatusActive); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.Update(admin, ID, 2, &UserUpdate{FirstName: "Anna"}, []string{UpdateMaskFirstName}); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.ChangePassword(auditV1.WithActor(ctx, ID), ID, testPassword, "N3w-passw0rd!"); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Delete(admin, ID, true); err != nil {
+				t.Fatal(err)
+			}
+
+			list, err := audited.auditLog.Query(context.Background(), &auditV1.Query{TargetID: ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []struct{ action, actor string }{
+				{AuditActionCreate, ""},
+				{AuditActionSetStatus, "admin-1"},
+				{AuditActionUpdate, "admin-1"},
+				{AuditActionChangePassword, ID},
+				{AuditActionHardDelete, "admin-1"},
+			}
+			if len(list.Records) != len(want) {
+				t.Fatalf("the audit log has %d records of the user, want %d", len(list.Records), len(want))
+			}
+			for i, w := range want {
+				r := list.Records[i]
+				if r.Action != w.action || r.Actor != w.actor || r.RequestID != "req-1" {
+					t.Errorf("record %d = %s by %q in %s, want %s by %q in req-1", i, r.Action, r.Actor, r.RequestID, w.action, w.actor)
+				}
+			}
+
+			if c := list.Records[2].Changes["first_name"]; c == nil || c.Before != "Ann" || c.After != "Anna" {
+				t.Errorf("the update record changes first_name by %+v, want Ann to Anna", c)
+			}
+			// The log shows that the password changed but never the hashes
+			for _, r := range list.Records {
+				if c := r.Changes["password_hash"]; c != nil && (c.Before != nil && c.Before != usvcErrors.Redacted || c.After != nil && c.After != usvcErrors.Redacted) {
+					t.Errorf("the %s record exposes the password hash: %+v", r.Action, c)
+				}
+			}
+			if c := list.Records[3].Changes["password_hash"]; c == nil {
+				t.Errorf("the %s record does not show that the password changed", AuditActionChangePassword)
+			}
+		})
+	}
+}
+
+// failingAuditLog - an audit log whose `Append` fails once `err` is set
+type failingAuditLog struct {
+	auditV1.Store
+	err error
+}
+
+// Append - the implementation of the `Append` method
+func (s *failingAuditLog) Append(ctx context.Context, record *auditV1.Record) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.Store.Append(ctx, record)
+}
+
+func TestManagerDoesNotChangeWithoutRecords(t *testing.T) {
+	ctx := context.Background()
+	auditLog := &failingAuditLog{Store: newTestAuditLog(t)}
+	repo := NewMemoryRepository(WithMemoryAuditLog(auditLog))
+	m := newTestManager(t, repo)
+	ID := mustCreate(t, ctx, m, "ann@example.com")
+
+	auditLog.err = errors.New("disk full")
+	if _, err := m.Update(ctx, ID, 1, &UserUpdate{FirstName: "Anna"}, []string{UpdateMaskFirstName}); errType(err) != ErrTypeInternalServerErr {
+		t.Errorf("Update() err: %v, want %s", err, ErrTypeInternalServerErr)
+	}
+	if user, err := m.Get(ctx, ID); err != nil || user.FirstName != "Ann" {
+		t.Errorf("Get() = %+v, %v, want the user unchanged", user, err)
+	}
+	if _, err := m.Create(ctx, "Bob", "Lee", testPassword, "bob@example.com", ""); errType(err) != ErrTypeInternalServerErr {
+		t.Errorf("Create() err: %v, want %s", err, ErrTypeInternalServerErr)
+	}
+	if _, err := m.GetByEmail(ctx, "bob@example.com"); errType(err) != ErrTypeNotFound {
+		t.Errorf("GetByEmail() of the user not created err: %v, want %s", err, ErrTypeNotFound)
+	}
+	// Nor are the events of the failed changes written
+	if events, err := repo.ListPendingEvents(ctx, 10); err != nil || len(events) != 1 {
+		t.Errorf("ListPendingEvents() = %d events, %v, want only the event of the first user", len(events), err)
+	}
+}
+
+func TestSQLRepositoryStoresChangesWithRecords(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	repo, auditLog := NewSQLRepository(db), auditV1.NewSQLStore(db)
+
+	user := newTestUser("u1", "ann@example.com")
+	record, err := auditV1.NewRecord(ctx, AuditActionCreate, user.ID, nil, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateUser(ctx, user, record); err != nil {
+		t.Fatalf("CreateUser() err: %v", err)
+	}
+
+	// Appending the same record again fails, which rolls back the update
+	updated := newTestUser("u1", "anna@example.com")
+	updated.Version = 2
+	if err := repo.UpdateUser(ctx, updated, record); err == nil {
+		t.Fatal("UpdateUser() with a record which has been stored err: nil, want an error")
+	}
+	if got, err := repo.GetUser(ctx, "u1"); err != nil || got.Email != "ann@example.com" {
+		t.Errorf("GetUser() = %+v, %v, want the user unchanged", got, err)
+	}
+
+	// A failed change rolls back its record
+	if err := repo.DeleteUser(ctx, "u2", &auditV1.Record{ID: "r2", Action: AuditActionHardDelete, TargetID: "u2"}); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("DeleteUser() of a missing user err: %v, want ErrRecordNotFound", err)
+	}
+	list, err := auditLog.Query(ctx, &auditV1.Query{})
+	if err != nil || len(list.Records) != 1 || list.Records[0].ID != record.ID {
+		t.Errorf("Query() = %+v, %v, want only the record of the creation", list, err)
+	}
+}
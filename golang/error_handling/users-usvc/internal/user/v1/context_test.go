@@ -0,0 +1,86 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestManagerStopsOnDoneContext(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	contexts := []struct {
+		name string
+		ctx  context.Context
+		want ErrType
+	}{
+		{"canceled", canceled, ErrTypeCanceled},
+		{"expired", expired, ErrTypeTimeout},
+	}
+	for name, repo := range testRepositories(t) {
+		m := newTestManager(t, repo)
+		ID := mustCreate(t, context.Background(), m, "ann@example.com")
+
+		calls := map[string]func(ctx context.Context) error{
+			"Create": func(ctx context.Context) error {
+				_, err := m.Create(ctx, "Bob", "Lee", testPassword, "bob@example.com", "")
+				return err
+			},
+			"Get": func(ctx context.Context) error {
+				_, err := m.Get(ctx, ID)
+				return err
+			},
+			"List": func(ctx context.Context) error {
+				_, err := m.List(ctx, &ListFilter{}, "", 0)
+				return err
+			},
+			"Update": func(ctx context.Context) error {
+				_, err := m.Update(ctx, ID, 1, &UserUpdate{FirstName: "Anna"}, []string{UpdateMaskFirstName})
+				return err
+			},
+			"Delete": func(ctx context.Context) error {
+				return m.Delete(ctx, ID, true)
+			},
+			"VerifyCredentials": func(ctx context.Context) error {
+				_, err := m.VerifyCredentials(ctx, "ann@example.com", testPassword)
+				return err
+			},
+		}
+		for _, c := range contexts {
+			for method, call := range calls {
+				t.Run(name+"/"+c.name+"/"+method, func(t *testing.T) {
+					if err := call(c.ctx); errType(err) != c.want {
+						t.Errorf("%s() err: %v, want %s", method, err, c.want)
+					}
+				})
+			}
+		}
+
+		// Nothing has been changed by the calls above
+		if user, err := m.Get(context.Background(), ID); err != nil || user.FirstName != "Ann" {
+			t.Errorf("%s: Get() after the calls with done contexts = %+v, %v, want the user unchanged", name, user, err)
+		}
+	}
+}
+
+func TestRepositoryStopsOnDoneContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := repo.CreateUser(ctx, newTestUser("u1", "ann@example.com"), nil); !errors.Is(err, context.Canceled) {
+				t.Errorf("CreateUser() err: %v, want context.Canceled", err)
+			}
+			if _, err := repo.GetUser(ctx, "u1"); !errors.Is(err, context.Canceled) {
+				t.Errorf("GetUser() err: %v, want context.Canceled", err)
+			}
+			if _, err := repo.ListUsers(ctx, &ListFilter{}, "", 10); !errors.Is(err, context.Canceled) {
+				t.Errorf("ListUsers() err: %v, want context.Canceled", err)
+			}
+		})
+	}
+}
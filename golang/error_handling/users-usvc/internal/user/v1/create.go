@@ -1,66 +1,109 @@
 package v1
 
 import (
-	"fmt"
-)
+	"context"
+	"errors"
+	"log"
+	"time"
 
-// Create - the implementation of the `Create` method. It uses builtin errors to do the error handling
-func (m *manager) Create(firstName, lastName, password, email string) (string, error) {
-	var ID string
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
 
-	if `the password containers some characters that the system cann't recognize` {
-		return ID, fmt.Errorf("The password contains some invalid characters.")
+// Create - the implementation of the `Create` method. It uses the second solution to do the error handling.
+func (m *manager) Create(ctx context.Context, firstName, lastName, password, email, idempotencyKey string) (string, error) {
+	if idempotencyKey != "" {
+		return m.createIdempotently(ctx, firstName, lastName, password, email, idempotencyKey)
 	}
+	return m.create(ctx, firstName, lastName, password, email)
+}
 
-	if `a user with the given email already exists` {
-		return ID, fmt.Errorf("The email %s has been used by another user.", email)
-	}
-	
-	ID, err := mysqlClient.CreateUser(firstName, lastName, password, email)
+// create validates the input and stores the user
+func (m *manager) create(ctx context.Context, firstName, lastName, password, email string) (string, error) {
+	firstName, lastName, email, err := m.validateNewUser(ctx, firstName, lastName, password, email)
 	if err != nil {
-		return ID, fmt.Errorf("Error creating user {Name: %s %s, Email: %s}, err: %s", firstName, lastName, email, err.Error())
+		return "", err
 	}
 
-	return ID, nil
-}
-
-// Create - the implementation of the `Create` method. It uses the first solution to do the error handling.
-func (m *manager) Create(firstName, lastName, password, email string) (string, error) {
-	var ID string
-
-	if `the password containers some characters that the system cann't recognize` {
-		return ID, newBadRequestErr("The password contains some invalid characters.")
+	user, err := m.newPendingUser(ctx, firstName, lastName, password, email)
+	if err != nil {
+		return "", err
 	}
+	ID := user.ID
 
-	if `a user with the given email already exists` {
-		return ID, newConflictErr("The email %s has been used by another user.", email)
+	record, err := newAuditRecord(ctx, AuditActionCreate, nil, user)
+	if err != nil {
+		return "", err
+	}
+	event, err := newUserEvent(EventTypeUserCreated, user)
+	if err != nil {
+		return "", newInternalError(err, "Error creating the event of user %s", ID)
+	}
+	err = m.repo.CreateUser(ctx, user, record, event)
+	if errors.Is(err, ErrDuplicateRecord) {
+		// Another request took the email between the check above and the insert
+		return "", wrapError(err, newCodedError(ErrTypeConflict, CodeEmailTaken, map[string]string{"email": email}, "The email %s has been used by another user.", email))
 	}
-	
-	ID, err := mysqlClient.CreateUser(firstName, lastName, password, email)
 	if err != nil {
-		return ID, newInternelServerErr("Error creating user {Name: %s %s, Email: %s}, err: %s", firstName, lastName, email, err.Error())
+		return "", newInternalError(err, "Error creating user {Name: %s %s, Email: %s}", firstName, lastName, email)
+	}
+
+	if err := m.sendActivationEmail(ctx, user); err != nil {
+		// The user has been created; another email can be sent through `SendActivationEmail`
+		log.Printf("[user_v1] error sending the activation email to user %s, err: %+v", ID, err)
 	}
 
 	return ID, nil
 }
 
+// validateNewUser validates the input of creating a user and checks that the email has not been used. It returns
+// the normalized names and email.
+func (m *manager) validateNewUser(ctx context.Context, firstName, lastName, password, email string) (string, string, string, error) {
+	var violations []FieldViolation
+	firstName, vs := m.validator.ValidateName(FieldFirstName, firstName)
+	violations = append(violations, vs...)
+	lastName, vs = m.validator.ValidateName(FieldLastName, lastName)
+	violations = append(violations, vs...)
+	email, vs = m.validator.ValidateEmail(email)
+	violations = append(violations, vs...)
+	violations = append(violations, m.validator.ValidatePassword(password)...)
+	if len(violations) > 0 {
+		return "", "", "", newValidationError(violations)
+	}
 
-// Create - the implementation of the `Create` method. It uses the second solution to do the error handling.
-func (m *manager) Create(firstName, lastName, password, email string) (string, error) {
-	var ID string
-
-	if `the password containers some characters that the system cann't recognize` {
-		return ID, newError(ErrTypeBadRequest, "The password contains some invalid characters.") 
+	_, err := m.repo.GetUserByEmail(ctx, email)
+	if err == nil {
+		return "", "", "", newCodedError(ErrTypeConflict, CodeEmailTaken, map[string]string{"email": email}, "The email %s has been used by another user.", email)
+	}
+	if !errors.Is(err, ErrRecordNotFound) {
+		return "", "", "", newInternalError(err, "Error checking the email %s", email)
 	}
+	return firstName, lastName, email, nil
+}
 
-	if `a user with the given email already exists` {
-		return ID, newError(ErrTypeConflict, "The email %s has been used by another user.", email)
+// newPendingUser creates a pending user of the tenant in the context with a new ID and the hash of the password.
+// The input must be validated.
+func (m *manager) newPendingUser(ctx context.Context, firstName, lastName, password, email string) (*User, error) {
+	ID, err := newID()
+	if err != nil {
+		return nil, newInternalError(err, "Error generating user ID")
 	}
-	
-	ID, err := mysqlClient.CreateUser(firstName, lastName, password, email)
+
+	hash, err := m.hasher.Hash(password)
 	if err != nil {
-		return ID, newError(ErrTypeInternalServerErr, "Error creating user {Name: %s %s, Email: %s}, err: %s", firstName, lastName, email, err.Error())
+		return nil, newInternalError(err, "Error hashing the password")
 	}
 
-	return ID, nil
+	now := time.Now().UTC()
+	return &User{
+		ID:           ID,
+		TenantID:     usvcTenant.FromContext(ctx),
+		FirstName:    firstName,
+		LastName:     lastName,
+		Email:        email,
+		PasswordHash: hash,
+		Status:       UserStatusPending,
+		Version:      1,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}, nil
 }
@@ -0,0 +1,57 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+// VerifyCredentials - the implementation of the `VerifyCredentials` method. It transparently rehashes the password
+// if it was hashed with an algorithm or cost other than the configured ones.
+func (m *manager) VerifyCredentials(ctx context.Context, email, password string) (*User, error) {
+	email = normalizeEmail(email)
+	user, err := m.repo.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, ErrRecordNotFound) {
+		return nil, newInternalError(err, "Error getting user by email %s", email)
+	}
+	if errors.Is(err, ErrRecordNotFound) || user.DeletedAt != nil {
+		// Verify against a dummy hash so that the response time does not reveal whether the email has been registered
+		m.hasher.Verify(m.dummyHash, password)
+		return nil, newCodedError(ErrTypeUnauthorized, CodeInvalidCredentials, nil, "The email or the password is incorrect.")
+	}
+
+	ok, err := m.hasher.Verify(user.PasswordHash, password)
+	if err != nil {
+		return nil, newInternalError(err, "Error verifying the password of user %s", user.ID)
+	}
+	if !ok {
+		return nil, newCodedError(ErrTypeUnauthorized, CodeInvalidCredentials, nil, "The email or the password is incorrect.")
+	}
+	if user.Status != UserStatusActive {
+		// The password is correct, so it is safe to tell why the user cannot sign in
+		return nil, newCodedError(ErrTypeForbidden, CodeUserNotActive, map[string]string{"status": string(user.Status)},
+			"The user is %s and cannot sign in.", user.Status)
+	}
+
+	if m.hasher.NeedsRehash(user.PasswordHash) {
+		m.rehashPassword(ctx, user, password)
+	}
+	return user, nil
+}
+
+// rehashPassword hashes the password with the configured algorithm and cost and stores the new hash.
+// Failures are only logged as the old hash is still valid. A rehash is not a change of the user, so its version,
+// which is its ETag, and its update time are kept, and it is neither recorded in the audit log nor written as an event.
+func (m *manager) rehashPassword(ctx context.Context, user *User, password string) {
+	hash, err := m.hasher.Hash(password)
+	if err != nil {
+		log.Printf("[user_v1] error rehashing the password of user %s, err: %s", user.ID, err.Error())
+		return
+	}
+
+	if err := m.repo.UpdatePasswordHash(ctx, user.ID, user.Version, hash); err != nil {
+		log.Printf("[user_v1] error storing the rehashed password of user %s, err: %s", user.ID, err.Error())
+		return
+	}
+	user.PasswordHash = hash
+}
@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Delete - the implementation of the `Delete` method
+func (m *manager) Delete(ctx context.Context, ID string, hard bool) error {
+	user, err := m.repo.GetUser(ctx, ID)
+	if errors.Is(err, ErrRecordNotFound) || (err == nil && user.DeletedAt != nil && !hard) {
+		return newCodedError(ErrTypeNotFound, CodeUserNotFound, map[string]string{"id": ID}, "The user %s does not exist.", ID)
+	}
+	if err != nil {
+		return newInternalError(err, "Error getting user %s", ID)
+	}
+
+	before := *user
+	action, after := AuditActionHardDelete, (*User)(nil)
+	if !hard {
+		action, after = AuditActionDelete, user
+		now := time.Now().UTC()
+		user.DeletedAt = &now
+		user.Version++
+		user.UpdatedAt = now
+	}
+	record, err := newAuditRecord(ctx, action, &before, after)
+	if err != nil {
+		return err
+	}
+	event, err := newUserEvent(EventTypeUserDeleted, user)
+	if err != nil {
+		return newInternalError(err, "Error creating the event of user %s", ID)
+	}
+	if hard {
+		err = m.repo.DeleteUser(ctx, ID, record, event)
+	} else {
+		err = m.repo.UpdateUser(ctx, user, record, event)
+	}
+	if errors.Is(err, ErrVersionMismatch) {
+		return newVersionMismatchError(err, ID)
+	}
+	if errors.Is(err, ErrRecordNotFound) {
+		return newCodedError(ErrTypeNotFound, CodeUserNotFound, map[string]string{"id": ID}, "The user %s does not exist.", ID)
+	}
+	if err != nil {
+		return newInternalError(err, "Error deleting user %s", ID)
+	}
+
+	return nil
+}
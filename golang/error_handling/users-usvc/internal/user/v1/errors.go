@@ -0,0 +1,147 @@
+package v1
+
+import (
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// The errors of this package are defined in the shared errors package. The aliases below keep the error handling
+// of callers working, whether they switch on `Type()` or on the error type structs.
+
+/*************************************************************************/
+// Error Types
+
+// ErrType - error type
+type ErrType = usvcErrors.ErrType
+
+// Error types
+const (
+	// ErrTypeBadRequest - bad request
+	ErrTypeBadRequest = usvcErrors.ErrTypeBadRequest
+	// ErrTypeConflict - resource conflicts
+	ErrTypeConflict = usvcErrors.ErrTypeConflict
+	// ErrTypeNotFound - resource not found
+	ErrTypeNotFound = usvcErrors.ErrTypeNotFound
+	// ErrTypeUnauthorized - the credentials are missing or invalid
+	ErrTypeUnauthorized = usvcErrors.ErrTypeUnauthorized
+	// ErrTypeForbidden - the caller is not allowed to perform the operation
+	ErrTypeForbidden = usvcErrors.ErrTypeForbidden
+	// ErrTypeRateLimited - the caller has sent too many requests
+	ErrTypeRateLimited = usvcErrors.ErrTypeRateLimited
+	// ErrTypeInternalServerErr - internal server error
+	ErrTypeInternalServerErr = usvcErrors.ErrTypeInternalServerErr
+	// ErrTypeUnavailable - the service or one of its dependencies is temporarily unavailable
+	ErrTypeUnavailable = usvcErrors.ErrTypeUnavailable
+	// ErrTypeTimeout - the deadline of the request passed before the operation completed
+	ErrTypeTimeout = usvcErrors.ErrTypeTimeout
+	// ErrTypeCanceled - the request was canceled by the caller
+	ErrTypeCanceled = usvcErrors.ErrTypeCanceled
+	// ErrTypeUnknown - Unknown error
+	ErrTypeUnknown = usvcErrors.ErrTypeUnknown
+)
+
+// Sentinel errors, one per error type, to be used with `errors.Is`
+var (
+	ErrBadRequest        = usvcErrors.ErrBadRequest
+	ErrConflict          = usvcErrors.ErrConflict
+	ErrNotFound          = usvcErrors.ErrNotFound
+	ErrUnauthorized      = usvcErrors.ErrUnauthorized
+	ErrForbidden         = usvcErrors.ErrForbidden
+	ErrRateLimited       = usvcErrors.ErrRateLimited
+	ErrInternalServerErr = usvcErrors.ErrInternalServerErr
+	ErrUnavailable       = usvcErrors.ErrUnavailable
+	ErrTimeout           = usvcErrors.ErrTimeout
+	ErrCanceled          = usvcErrors.ErrCanceled
+	ErrUnknown           = usvcErrors.ErrUnknown
+)
+
+/************************************************************************/
+// Error definition
+
+// Error interface defines the errors used in this package
+type Error = usvcErrors.Error
+
+// FieldViolation describes why a field of the input is invalid
+type FieldViolation = usvcErrors.FieldViolation
+
+// Error type structs, for callers which do the error handling with type switches
+type (
+	// BadRequestErr represents bad request errors
+	BadRequestErr = usvcErrors.BadRequestErr
+	// ConflictErr represents resource conflict errors
+	ConflictErr = usvcErrors.ConflictErr
+	// NotFoundErr represents resource not found errors
+	NotFoundErr = usvcErrors.NotFoundErr
+	// UnauthorizedErr represents errors caused by missing or invalid credentials
+	UnauthorizedErr = usvcErrors.UnauthorizedErr
+	// ForbiddenErr represents errors caused by callers which are not allowed to perform the operation
+	ForbiddenErr = usvcErrors.ForbiddenErr
+	// InternelServerErr represents internal server errors
+	InternelServerErr = usvcErrors.InternelServerErr
+)
+
+// Error codes which are more specific than error types
+const (
+	// CodeInvalidFields - some fields of the input are invalid, see `Details()`
+	CodeInvalidFields = usvcErrors.CodeInvalidFields
+	// CodeEmailTaken - the email has been used by another user
+	CodeEmailTaken = "email_taken"
+	// CodeUserNotFound - the user does not exist
+	CodeUserNotFound = "user_not_found"
+	// CodeInvalidCredentials - the email or the password is incorrect
+	CodeInvalidCredentials = "invalid_credentials"
+	// CodeIdempotencyKeyReused - the idempotency key has been used by a request with different input
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	// CodeIdempotencyKeyInProgress - a request with the same idempotency key is still being processed
+	CodeIdempotencyKeyInProgress = "idempotency_key_in_progress"
+	// CodeInvalidToken - the token is malformed, has been tampered with or has been used
+	CodeInvalidToken = "invalid_token"
+	// CodeTokenExpired - the token has expired
+	CodeTokenExpired = "token_expired"
+	// CodeInvalidStatusTransition - the user cannot move from its current status to the requested one
+	CodeInvalidStatusTransition = "invalid_status_transition"
+	// CodeUserNotActive - the user has not been activated or has been disabled
+	CodeUserNotActive = "user_not_active"
+	// CodeIncorrectPassword - the current password given to change the password is incorrect
+	CodeIncorrectPassword = "incorrect_password"
+	// CodeVersionMismatch - the user has been changed since the version the request is based on
+	CodeVersionMismatch = "version_mismatch"
+	// CodeMalformedImport - the imported file is malformed, e.g. the header row of a CSV file misses a column
+	CodeMalformedImport = "malformed_import"
+	// CodeMalformedImportRow - a row of the imported file is malformed, e.g. a line which is not valid JSON
+	CodeMalformedImportRow = "malformed_import_row"
+)
+
+// newError returns an error with given error type
+func newError(errType ErrType, format string, a ...interface{}) Error {
+	usvcErrors.Helper()
+	return usvcErrors.New(errType, format, a...)
+}
+
+// newCodedError returns an error with given error type, code and optional metadata
+func newCodedError(errType ErrType, code string, metadata map[string]string, format string, a ...interface{}) Error {
+	usvcErrors.Helper()
+	return usvcErrors.NewCoded(errType, code, metadata, format, a...)
+}
+
+// newValidationError returns a bad request error which carries the given field violations
+func newValidationError(violations []FieldViolation) Error {
+	usvcErrors.Helper()
+	return usvcErrors.NewValidation(violations)
+}
+
+// newInternalError returns an internal server error caused by a downstream error, or a timeout or canceled error
+// if the downstream error is caused by the context of the request
+func newInternalError(cause error, format string, a ...interface{}) Error {
+	usvcErrors.Helper()
+	return usvcErrors.WrapInternal(cause, format, a...)
+}
+
+// wrapError returns a copy of an error created by `newError` or `newCodedError` which records the downstream error causing it
+func wrapError(cause error, err Error) Error {
+	return usvcErrors.Wrap(cause, err)
+}
+
+// ConvertError - try converting an `error` interface to an `Error` interface
+func ConvertError(err error) (Error, bool) {
+	return usvcErrors.Convert(err)
+}
@@ -0,0 +1,118 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+func TestCreateFieldViolations(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, nil)
+
+	_, err := m.Create(ctx, "", "L3e", "short", "not an email", "")
+	e, ok := ConvertError(err)
+	if !ok {
+		t.Fatalf("Create() err: %v, want an Error", err)
+	}
+	if e.Type() != ErrTypeBadRequest || e.Code() != CodeInvalidFields {
+		t.Errorf("Create() err: %s/%s, want %s/%s", e.Type(), e.Code(), ErrTypeBadRequest, CodeInvalidFields)
+	}
+
+	// Every invalid field is reported, not only the first one
+	fields := map[string]int{}
+	for _, violation := range e.Details() {
+		if violation.Description == "" {
+			t.Errorf("violation of field %s has no description", violation.Field)
+		}
+		fields[violation.Field]++
+	}
+	for _, field := range []string{FieldFirstName, FieldLastName, FieldEmail, FieldPassword} {
+		if fields[field] == 0 {
+			t.Errorf("Details() = %v, want a violation of field %s", e.Details(), field)
+		}
+	}
+	if fields[FieldPassword] < 2 {
+		t.Errorf("Details() = %v, want every violation of the password policy", e.Details())
+	}
+}
+
+func TestErrorDetailsOfOtherErrors(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, nil)
+
+	_, err := m.Get(ctx, "missing")
+	e, ok := ConvertError(err)
+	if !ok {
+		t.Fatalf("Get() err: %v, want an Error", err)
+	}
+	if len(e.Details()) != 0 {
+		t.Errorf("Details() of a not found error = %v, want none", e.Details())
+	}
+	if e.Code() != CodeUserNotFound || e.Metadata()["id"] != "missing" {
+		t.Errorf("Get() err: code %s, metadata %v, want %s with the ID", e.Code(), e.Metadata(), CodeUserNotFound)
+	}
+}
+
+// failingRepository - a Repository whose `GetUser` fails with the given error
+type failingRepository struct {
+	Repository
+	err error
+}
+
+// GetUser - the implementation of the `GetUser` method
+func (r *failingRepository) GetUser(ctx context.Context, ID string) (*User, error) {
+	return nil, r.err
+}
+
+func TestErrorWrapping(t *testing.T) {
+	ctx := context.Background()
+	dbErr := errors.New("connection refused")
+	m := newTestManager(t, &failingRepository{Repository: NewMemoryRepository(), err: dbErr})
+
+	_, err := m.Get(ctx, "u1")
+	if !errors.Is(err, dbErr) {
+		t.Errorf("errors.Is(err, cause) = false, err: %v", err)
+	}
+	if !errors.Is(err, ErrInternalServerErr) || errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() err: %v, want it to match only ErrInternalServerErr", err)
+	}
+	// Callers which do the error handling with type switches still get the error type structs
+	var internalErr *InternelServerErr
+	if !errors.As(err, &internalErr) {
+		t.Errorf("errors.As(err, *InternelServerErr) = false, err: %T", err)
+	}
+	if !strings.Contains(err.Error(), dbErr.Error()) {
+		t.Errorf("Error() = %q, want the message of the cause", err.Error())
+	}
+	if problem := usvcErrors.NewProblemDetails(err); strings.Contains(problem.Detail, dbErr.Error()) {
+		t.Errorf("NewProblemDetails().Detail = %q, want the cause left out", problem.Detail)
+	}
+
+	// Errors wrapped by callers can still be converted
+	wrapped := fmt.Errorf("error getting the user: %w", err)
+	if e, ok := ConvertError(wrapped); !ok || e.Type() != ErrTypeInternalServerErr {
+		t.Errorf("ConvertError() of a wrapped error = %v, %v", e, ok)
+	}
+	if _, ok := ConvertError(dbErr); ok {
+		t.Error("ConvertError() of a plain error = true, want false")
+	}
+}
+
+func TestErrorWrappingContextErrors(t *testing.T) {
+	tests := map[error]ErrType{
+		context.DeadlineExceeded: ErrTypeTimeout,
+		context.Canceled:         ErrTypeCanceled,
+	}
+	for cause, want := range tests {
+		m := newTestManager(t, &failingRepository{Repository: NewMemoryRepository(), err: fmt.Errorf("query failed: %w", cause)})
+		_, err := m.Get(context.Background(), "u1")
+		if errType(err) != want || !errors.Is(err, cause) {
+			t.Errorf("Get() with a repository failing with %v err: %v, want %s", cause, err, want)
+		}
+	}
+}
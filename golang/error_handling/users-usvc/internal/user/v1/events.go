@@ -0,0 +1,44 @@
+package v1
+
+import (
+	"time"
+
+	outboxV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/outbox/v1"
+)
+
+// Types of the events written to the outbox when users change. Relay them to other services with `outboxV1.Relay`.
+const (
+	EventTypeUserCreated = "user.created"
+	EventTypeUserUpdated = "user.updated"
+	EventTypeUserDeleted = "user.deleted"
+)
+
+// UserEvent - the payload of user events, i.e. the state of the user after the change. The password hash is left out.
+type UserEvent struct {
+	ID        string     `json:"id"`
+	TenantID  string     `json:"tenant_id"`
+	FirstName string     `json:"first_name"`
+	LastName  string     `json:"last_name"`
+	Email     string     `json:"email"`
+	Status    UserStatus `json:"status"`
+	Version   int        `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// newUserEvent creates the outbox event of a change of the user
+func newUserEvent(eventType string, user *User) (*outboxV1.Event, error) {
+	return outboxV1.NewEvent(eventType, user.ID, &UserEvent{
+		ID:        user.ID,
+		TenantID:  user.TenantID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Email:     user.Email,
+		Status:    user.Status,
+		Version:   user.Version,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		DeletedAt: user.DeletedAt,
+	})
+}
@@ -0,0 +1,175 @@
+package v1
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportPageSize - how many users `Export` reads from the repository at a time
+const exportPageSize = 500
+
+// ExportWriter writes the users exported by `Export`, see `NewCSVExportWriter` and `NewJSONLinesExportWriter`.
+// `Flush` is called after every page of users, so that exports are streamed rather than buffered.
+type ExportWriter interface {
+	Write(user *User) error
+	Flush() error
+}
+
+// Export - the implementation of the `Export` method. It reads the users page by page with keyset pagination,
+// the same way as `List`.
+func (m *manager) Export(ctx context.Context, filter *ListFilter, w ExportWriter) error {
+	afterID := ""
+	for {
+		users, err := m.repo.ListUsers(ctx, filter, afterID, exportPageSize)
+		if err != nil {
+			return newInternalError(err, "Error exporting the users after %q", afterID)
+		}
+		for _, user := range users {
+			if err := w.Write(user); err != nil {
+				return newInternalError(err, "Error writing user %s", user.ID)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return newInternalError(err, "Error flushing the exported users")
+		}
+		if len(users) < exportPageSize {
+			return nil
+		}
+		afterID = users[len(users)-1].ID
+	}
+}
+
+// exportColumns - the columns of exported CSV files. The password hash is never exported.
+var exportColumns = []string{"id", FieldFirstName, FieldLastName, FieldEmail, "status", "version", "created_at", "updated_at", "deleted_at"}
+
+// flusher is implemented by writers which buffer data, e.g. `http.ResponseWriter`
+type flusher interface {
+	Flush()
+}
+
+// csvExportWriter is the implementation of ExportWriter interface which writes CSV
+type csvExportWriter struct {
+	w   io.Writer
+	csv *csv.Writer
+	// headerWritten tells whether the header row has been written
+	headerWritten bool
+}
+
+// NewCSVExportWriter creates an instance of ExportWriter which writes CSV with a header row to the given writer.
+// The header row is written even if no user is exported. Cells starting with `=`, `+`, `-` or `@` are prefixed with
+// `'`, so that spreadsheets opening the file do not run them as formulas.
+func NewCSVExportWriter(w io.Writer) ExportWriter {
+	return &csvExportWriter{
+		w:   w,
+		csv: csv.NewWriter(w),
+	}
+}
+
+// Write - the implementation of the `Write` method
+func (w *csvExportWriter) Write(user *User) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	deletedAt := ""
+	if user.DeletedAt != nil {
+		deletedAt = user.DeletedAt.Format(time.RFC3339Nano)
+	}
+	return w.csv.Write([]string{
+		csvCell(user.ID), csvCell(user.FirstName), csvCell(user.LastName), csvCell(user.Email), string(user.Status),
+		strconv.Itoa(user.Version), user.CreatedAt.Format(time.RFC3339Nano), user.UpdatedAt.Format(time.RFC3339Nano), deletedAt,
+	})
+}
+
+// csvCell prefixes a cell starting with a formula character with `'`
+func csvCell(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// Flush - the implementation of the `Flush` method
+func (w *csvExportWriter) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
+	}
+	if f, ok := w.w.(flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// writeHeader writes the header row if it has not been written
+func (w *csvExportWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+	return w.csv.Write(exportColumns)
+}
+
+// exportedUser - the representation of a user in JSON-lines exports
+type exportedUser struct {
+	ID        string     `json:"id"`
+	FirstName string     `json:"first_name"`
+	LastName  string     `json:"last_name"`
+	Email     string     `json:"email"`
+	Status    UserStatus `json:"status"`
+	Version   int        `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// jsonLinesExportWriter is the implementation of ExportWriter interface which writes JSON lines
+type jsonLinesExportWriter struct {
+	w   io.Writer
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewJSONLinesExportWriter creates an instance of ExportWriter which writes one JSON object per user to the given writer
+func NewJSONLinesExportWriter(w io.Writer) ExportWriter {
+	buf := bufio.NewWriter(w)
+	return &jsonLinesExportWriter{
+		w:   w,
+		buf: buf,
+		enc: json.NewEncoder(buf),
+	}
+}
+
+// Write - the implementation of the `Write` method
+func (w *jsonLinesExportWriter) Write(user *User) error {
+	return w.enc.Encode(&exportedUser{
+		ID:        user.ID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Email:     user.Email,
+		Status:    user.Status,
+		Version:   user.Version,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		DeletedAt: user.DeletedAt,
+	})
+}
+
+// Flush - the implementation of the `Flush` method
+func (w *jsonLinesExportWriter) Flush() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if f, ok := w.w.(flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
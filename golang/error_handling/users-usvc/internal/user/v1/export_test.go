@@ -0,0 +1,121 @@
+package v1
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, nil)
+	annID := mustCreate(t, ctx, m, "ann@example.com")
+	mustCreate(t, ctx, m, "bob@example.com")
+
+	var out strings.Builder
+	if err := m.Export(ctx, &ListFilter{}, NewCSVExportWriter(&out)); err != nil {
+		t.Fatalf("Export() err: %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(out.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("the export is not valid CSV, err: %v", err)
+	}
+	if len(records) != 3 || strings.Join(records[0], ",") != strings.Join(exportColumns, ",") {
+		t.Fatalf("Export() as CSV = %q, want the header row and 2 users", out.String())
+	}
+	// Users are exported in the order of their IDs, which are random
+	ann := records[1]
+	if ann[3] != "ann@example.com" {
+		ann = records[2]
+	}
+	if ann[0] != annID || ann[3] != "ann@example.com" || ann[4] != string(UserStatusPending) {
+		t.Errorf("the exported rows = %v, want ann pending", records[1:])
+	}
+	if strings.Contains(out.String(), "$2a$") {
+		t.Errorf("Export() as CSV exposes the password hashes: %s", out.String())
+	}
+
+	out.Reset()
+	if err := m.Export(ctx, &ListFilter{Email: "bob@example.com"}, NewJSONLinesExportWriter(&out)); err != nil {
+		t.Fatalf("Export() err: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	user := &exportedUser{}
+	if err := json.Unmarshal([]byte(lines[0]), user); len(lines) != 1 || err != nil || user.Email != "bob@example.com" || user.Version != 1 {
+		t.Errorf("Export() of bob as JSON lines = %q, want bob", out.String())
+	}
+
+	// The header row is written even if no user matches
+	out.Reset()
+	if err := m.Export(ctx, &ListFilter{FirstName: "Nobody"}, NewCSVExportWriter(&out)); err != nil || out.String() != strings.Join(exportColumns, ",")+"\n" {
+		t.Errorf("Export() of no users = %q, %v, want the header row", out.String(), err)
+	}
+}
+
+// recordingExportWriter - an ExportWriter which records how many users are written before each flush
+type recordingExportWriter struct {
+	written int
+	flushes []int
+}
+
+// Write - the implementation of the `Write` method
+func (w *recordingExportWriter) Write(user *User) error {
+	w.written++
+	return nil
+}
+
+// Flush - the implementation of the `Flush` method
+func (w *recordingExportWriter) Flush() error {
+	w.flushes = append(w.flushes, w.written)
+	return nil
+}
+
+func TestExportFlushesEveryPage(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	users := make([]*User, 0, exportPageSize+1)
+	for i := 0; i <= exportPageSize; i++ {
+		users = append(users, newTestUser(fmt.Sprintf("u%04d", i), fmt.Sprintf("user%d@example.com", i)))
+	}
+	if err := repo.CreateUsers(ctx, users, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &recordingExportWriter{}
+	if err := newTestManager(t, repo).Export(ctx, &ListFilter{}, w); err != nil {
+		t.Fatalf("Export() err: %v", err)
+	}
+	if w.written != exportPageSize+1 || len(w.flushes) != 2 || w.flushes[0] != exportPageSize {
+		t.Errorf("Export() wrote %d users with flushes after %v, want %d flushed after every page", w.written, w.flushes, exportPageSize+1)
+	}
+}
+
+func TestCSVExportEscapesFormulas(t *testing.T) {
+	tests := map[string]string{
+		"=HYPERLINK(\"x\")": "'=HYPERLINK(\"x\")",
+		"+1":                "'+1",
+		"-1":                "'-1",
+		"@SUM(A1)":          "'@SUM(A1)",
+		"Ann":               "Ann",
+		"":                  "",
+	}
+	for name, want := range tests {
+		var out strings.Builder
+		user := newTestUser("u1", "ann@example.com")
+		user.FirstName = name
+		w := NewCSVExportWriter(&out)
+		if err := w.Write(user); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(strings.NewReader(out.String())).ReadAll()
+		if err != nil || len(records) != 2 || records[1][1] != want {
+			t.Errorf("Write() of the first name %q = %q, %v, want %q", name, out.String(), err, want)
+		}
+	}
+}
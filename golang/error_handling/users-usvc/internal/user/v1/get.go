@@ -0,0 +1,31 @@
+package v1
+
+import (
+	"context"
+	"errors"
+)
+
+// Get - the implementation of the `Get` method
+func (m *manager) Get(ctx context.Context, ID string) (*User, error) {
+	user, err := m.repo.GetUser(ctx, ID)
+	if errors.Is(err, ErrRecordNotFound) || (err == nil && user.DeletedAt != nil) {
+		return nil, newCodedError(ErrTypeNotFound, CodeUserNotFound, map[string]string{"id": ID}, "The user %s does not exist.", ID)
+	}
+	if err != nil {
+		return nil, newInternalError(err, "Error getting user %s", ID)
+	}
+	return user, nil
+}
+
+// GetByEmail - the implementation of the `GetByEmail` method
+func (m *manager) GetByEmail(ctx context.Context, email string) (*User, error) {
+	email = normalizeEmail(email)
+	user, err := m.repo.GetUserByEmail(ctx, email)
+	if errors.Is(err, ErrRecordNotFound) || (err == nil && user.DeletedAt != nil) {
+		return nil, newCodedError(ErrTypeNotFound, CodeUserNotFound, nil, "The user with email %s does not exist.", email)
+	}
+	if err != nil {
+		return nil, newInternalError(err, "Error getting user by email %s", email)
+	}
+	return user, nil
+}
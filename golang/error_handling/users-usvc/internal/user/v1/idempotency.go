@@ -0,0 +1,92 @@
+package v1
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+)
+
+// DefaultIdempotencyTTL - how long idempotency keys are kept by default
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength - the maximum length of an idempotency key
+const maxIdempotencyKeyLength = 255
+
+// createIdempotently creates a user once per idempotency key. The key is reserved before the user is created,
+// so concurrent requests with the same key cannot create two users, and it is released if the creation fails
+// so that the request can be retried.
+func (m *manager) createIdempotently(ctx context.Context, firstName, lastName, password, email, key string) (string, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		return "", newValidationError([]FieldViolation{{Field: "idempotency_key", Description: "The idempotency key must not exceed 255 characters."}})
+	}
+
+	now := time.Now().UTC()
+	record := &IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash(firstName, lastName, email),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(m.idempotencyTTL),
+	}
+	err := m.repo.CreateIdempotencyRecord(ctx, record)
+	if errors.Is(err, ErrDuplicateRecord) {
+		return m.replay(ctx, record)
+	}
+	if err != nil {
+		return "", newInternalError(err, "Error reserving the idempotency key %s", key)
+	}
+
+	// The key is released and its result stored even if the request is canceled, e.g. when a timeout made the
+	// creation fail, so that retries are not rejected as in progress until the key expires
+	releaseCtx := context.WithoutCancel(ctx)
+	ID, err := m.create(ctx, firstName, lastName, password, email)
+	if err != nil {
+		if dErr := m.repo.DeleteIdempotencyRecord(releaseCtx, key); dErr != nil {
+			log.Printf("[user_v1] error releasing the idempotency key %s, err: %s", key, dErr.Error())
+		}
+		return "", err
+	}
+
+	record.UserID = ID
+	if err := m.repo.UpdateIdempotencyRecord(releaseCtx, record); err != nil {
+		// The user has been created; a retry will get an in-progress conflict instead of a duplicate user
+		log.Printf("[user_v1] error storing the result of the idempotency key %s, err: %s", key, err.Error())
+	}
+	return ID, nil
+}
+
+// replay returns the result of the request which used the same idempotency key before
+func (m *manager) replay(ctx context.Context, record *IdempotencyRecord) (string, error) {
+	stored, err := m.repo.GetIdempotencyRecord(ctx, record.Key)
+	if errors.Is(err, ErrRecordNotFound) {
+		// The first request failed and released the key in the meantime
+		return "", newCodedError(ErrTypeConflict, CodeIdempotencyKeyInProgress, map[string]string{"idempotency_key": record.Key},
+			"A request with the idempotency key %s is being processed, please retry later.", record.Key)
+	}
+	if err != nil {
+		return "", newInternalError(err, "Error getting the idempotency key %s", record.Key)
+	}
+
+	if stored.RequestHash != record.RequestHash {
+		return "", newCodedError(ErrTypeConflict, CodeIdempotencyKeyReused, map[string]string{"idempotency_key": record.Key},
+			"The idempotency key %s has been used by a request with different input.", record.Key)
+	}
+	if stored.UserID == "" {
+		return "", newCodedError(ErrTypeConflict, CodeIdempotencyKeyInProgress, map[string]string{"idempotency_key": record.Key},
+			"A request with the idempotency key %s is being processed, please retry later.", record.Key)
+	}
+	return stored.UserID, nil
+}
+
+// requestHash returns the fingerprint of the input of `Create`. The password is left out so that it is never
+// stored with a fast hash.
+func requestHash(firstName, lastName, email string) string {
+	h := sha256.New()
+	for _, field := range []string{firstName, lastName, normalizeEmail(email)} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,117 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+	outboxV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/outbox/v1"
+)
+
+func TestCreateIdempotently(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			m := newTestManager(t, repo)
+
+			ID, err := m.Create(ctx, "Ann", "Lee", testPassword, "ann@example.com", "key-1")
+			if err != nil {
+				t.Fatalf("Create() err: %v", err)
+			}
+			retryID, err := m.Create(ctx, "Ann", "Lee", testPassword, "ANN@example.com", "key-1")
+			if err != nil || retryID != ID {
+				t.Errorf("Create() retried with the same key = %s, %v, want %s", retryID, err, ID)
+			}
+			if list, _ := m.List(ctx, &ListFilter{}, "", 0); len(list.Users) != 1 {
+				t.Errorf("List() after a retry returned %d users, want 1", len(list.Users))
+			}
+
+			_, err = m.Create(ctx, "Bob", "Lee", testPassword, "bob@example.com", "key-1")
+			if e, ok := ConvertError(err); !ok || e.Code() != CodeIdempotencyKeyReused {
+				t.Errorf("Create() with a reused key err: %v, want %s", err, CodeIdempotencyKeyReused)
+			}
+		})
+	}
+}
+
+func TestCreateIdempotentlyReleasesFailedKeys(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, nil)
+
+	if _, err := m.Create(ctx, "Ann", "Lee", "weak", "ann@example.com", "key-1"); errType(err) != ErrTypeBadRequest {
+		t.Fatalf("Create() with a weak password err: %v, want %s", err, ErrTypeBadRequest)
+	}
+	// The failed request does not hold the key, so it can be retried with the input fixed
+	if _, err := m.Create(ctx, "Ann", "Lee", testPassword, "ann@example.com", "key-1"); err != nil {
+		t.Errorf("Create() retried after a failure err: %v", err)
+	}
+
+	if _, err := m.Create(ctx, "Ann", "Lee", testPassword, "bob@example.com", strings.Repeat("k", maxIdempotencyKeyLength+1)); errType(err) != ErrTypeBadRequest {
+		t.Errorf("Create() with a long key err: %v, want %s", err, ErrTypeBadRequest)
+	}
+}
+
+// cancelingRepository - a Repository whose `CreateUser` fails as the request is canceled while the user is stored
+type cancelingRepository struct {
+	Repository
+	cancel context.CancelFunc
+}
+
+// CreateUser - the implementation of the `CreateUser` method
+func (r *cancelingRepository) CreateUser(ctx context.Context, user *User, record *auditV1.Record, events ...*outboxV1.Event) error {
+	r.cancel()
+	return ctx.Err()
+}
+
+func TestCreateIdempotentlyReleasesKeysOfCanceledRequests(t *testing.T) {
+	repo := NewMemoryRepository()
+	ctx, cancel := context.WithCancel(context.Background())
+	m := newTestManager(t, &cancelingRepository{Repository: repo, cancel: cancel})
+
+	if _, err := m.Create(ctx, "Ann", "Lee", testPassword, "ann@example.com", "key-1"); errType(err) != ErrTypeCanceled {
+		t.Fatalf("Create() canceled while storing the user err: %v, want %s", err, ErrTypeCanceled)
+	}
+	if _, err := repo.GetIdempotencyRecord(context.Background(), "key-1"); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("GetIdempotencyRecord() after a canceled request err: %v, want ErrRecordNotFound", err)
+	}
+
+	// A retry is not rejected as in progress
+	if _, err := newTestManager(t, repo).Create(context.Background(), "Ann", "Lee", testPassword, "ann@example.com", "key-1"); err != nil {
+		t.Errorf("Create() retried after a canceled request err: %v", err)
+	}
+}
+
+func TestRepositoryIdempotencyRecords(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now().UTC()
+			record := &IdempotencyRecord{Key: "key-1", RequestHash: "hash", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+			if err := repo.CreateIdempotencyRecord(ctx, record); err != nil {
+				t.Fatalf("CreateIdempotencyRecord() err: %v", err)
+			}
+			// Concurrent requests with the same key cannot both reserve it
+			if err := repo.CreateIdempotencyRecord(ctx, record); !errors.Is(err, ErrDuplicateRecord) {
+				t.Errorf("CreateIdempotencyRecord() with a reserved key err: %v, want ErrDuplicateRecord", err)
+			}
+
+			record.UserID = "u1"
+			if err := repo.UpdateIdempotencyRecord(ctx, record); err != nil {
+				t.Fatalf("UpdateIdempotencyRecord() err: %v", err)
+			}
+			if got, err := repo.GetIdempotencyRecord(ctx, "key-1"); err != nil || got.UserID != "u1" || got.RequestHash != "hash" {
+				t.Errorf("GetIdempotencyRecord() = %+v, %v, want the result of user u1", got, err)
+			}
+
+			if err := repo.DeleteIdempotencyRecord(ctx, "key-1"); err != nil {
+				t.Fatalf("DeleteIdempotencyRecord() err: %v", err)
+			}
+			if _, err := repo.GetIdempotencyRecord(ctx, "key-1"); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("GetIdempotencyRecord() of a released key err: %v, want ErrRecordNotFound", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strconv"
+
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+	outboxV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/outbox/v1"
+)
+
+// Batch sizes used by `Import`
+const (
+	// DefaultImportBatchSize - the batch size used when the given size is not positive
+	DefaultImportBatchSize = 100
+	// MaxImportBatchSize - the maximum batch size
+	MaxImportBatchSize = 1000
+)
+
+// ImportRow represents a user to be created by `Import`
+type ImportRow struct {
+	// Line is the line of the row in the imported file, which the result of the row refers to
+	Line      int
+	FirstName string
+	LastName  string
+	Email     string
+	Password  string
+	// Err is set if the row is malformed, e.g. a CSV record with a missing field. The row is reported as failed.
+	Err Error
+}
+
+// ImportReader reads the rows of an import one by one, see `NewCSVImportReader` and `NewJSONLinesImportReader`.
+// `Next` returns io.EOF after the last row. Malformed rows are returned with `ImportRow.Err` set, while errors
+// returned by `Next` stop the import.
+type ImportReader interface {
+	Next() (*ImportRow, error)
+}
+
+// ImportOptions defines the optional settings of `Import`
+type ImportOptions struct {
+	// DryRun only validates the rows, no user is created
+	DryRun bool
+	// BatchSize is how many rows are created in one transaction. `DefaultImportBatchSize` is used if it is not positive.
+	BatchSize int
+}
+
+// ImportResult represents the result of a row
+type ImportResult struct {
+	Line  int
+	Email string
+	// ID is the ID of the created user. It is empty for failed rows and in dry runs.
+	ID string
+	// Err is the reason why the row failed, nil if it succeeded
+	Err Error
+}
+
+// ImportReport represents the result of an import
+type ImportReport struct {
+	DryRun    bool
+	Succeeded int
+	Failed    int
+	// FailedByType counts the failed rows by error type, e.g. bad requests for invalid rows and conflicts for taken emails
+	FailedByType map[ErrType]int
+	// Results are the results of the rows, in the order of the rows
+	Results []*ImportResult
+}
+
+// add adds the result of a row to the report
+func (r *ImportReport) add(result *ImportResult) {
+	r.Results = append(r.Results, result)
+	if result.Err != nil {
+		r.Failed++
+		r.FailedByType[result.Err.Type()]++
+		return
+	}
+	r.Succeeded++
+}
+
+// Import - the implementation of the `Import` method. The rows are read and created one batch at a time, so that
+// large files are not held in memory and each batch is stored in one transaction.
+func (m *manager) Import(ctx context.Context, rows ImportReader, opts *ImportOptions) (*ImportReport, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultImportBatchSize
+	}
+	if batchSize > MaxImportBatchSize {
+		return nil, newError(ErrTypeBadRequest, "The batch size %d exceeds the maximum %d.", batchSize, MaxImportBatchSize)
+	}
+
+	report := &ImportReport{DryRun: opts.DryRun, FailedByType: map[ErrType]int{}}
+	// emails maps the emails of the imported rows to their lines, so that rows with the same email are rejected
+	emails := map[string]int{}
+	line := 0
+	for eof := false; !eof; {
+		batch := make([]*ImportRow, 0, batchSize)
+		for len(batch) < batchSize {
+			row, err := rows.Next()
+			if errors.Is(err, io.EOF) {
+				eof = true
+				break
+			}
+			if err != nil {
+				// The batches before have been created, so their results are returned with the error
+				if e, ok := ConvertError(err); ok {
+					return report, e
+				}
+				return report, wrapError(err, newCodedError(ErrTypeBadRequest, CodeMalformedImport, map[string]string{"line": strconv.Itoa(line)},
+					"Error reading the row after line %d: %s", line, err.Error()))
+			}
+			line = row.Line
+			batch = append(batch, row)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		if err := ctx.Err(); err != nil {
+			return report, newInternalError(err, "Error importing users")
+		}
+		for _, result := range m.importBatch(ctx, batch, emails, opts.DryRun) {
+			report.add(result)
+		}
+	}
+	return report, nil
+}
+
+// importBatch validates the rows of a batch and creates the users of the valid rows in one transaction. `emails`
+// maps the emails of the rows imported by the batches before to their lines. The emails of the batch are only added
+// to it once the batch has been stored, or validated in dry runs, so that the rows of a failed batch do not reject
+// later rows with the same emails.
+func (m *manager) importBatch(ctx context.Context, batch []*ImportRow, emails map[string]int, dryRun bool) []*ImportResult {
+	results := make([]*ImportResult, 0, len(batch))
+	// batchEmails maps the emails of the valid rows of the batch to their lines
+	batchEmails := map[string]int{}
+	// users are the users to be created and pending are the results of them
+	var users []*User
+	var pending []*ImportResult
+	for _, row := range batch {
+		result := &ImportResult{Line: row.Line, Email: row.Email, Err: row.Err}
+		results = append(results, result)
+		if result.Err != nil {
+			continue
+		}
+
+		user, err := m.importRow(ctx, row, emails, batchEmails, dryRun)
+		if err != nil {
+			result.Err = toError(err)
+			continue
+		}
+		result.Email = user.Email
+		if !dryRun {
+			users, pending = append(users, user), append(pending, result)
+		}
+	}
+	if dryRun {
+		for email, line := range batchEmails {
+			emails[email] = line
+		}
+		return results
+	}
+	if len(users) == 0 {
+		return results
+	}
+
+	records := make([]*auditV1.Record, 0, len(users))
+	events := make([]*outboxV1.Event, 0, len(users))
+	for _, user := range users {
+		record, err := newAuditRecord(ctx, AuditActionImport, nil, user)
+		if err == nil {
+			var event *outboxV1.Event
+			if event, err = newUserEvent(EventTypeUserCreated, user); err != nil {
+				err = newInternalError(err, "Error creating the event of user %s", user.ID)
+			}
+			records, events = append(records, record), append(events, event)
+		}
+		if err != nil {
+			for _, result := range pending {
+				result.Err = toError(err)
+			}
+			return results
+		}
+	}
+
+	err := m.repo.CreateUsers(ctx, users, records, events...)
+	if errors.Is(err, ErrDuplicateRecord) {
+		// Other requests took some of the emails after they were checked; create the users one by one to find them
+		for i, user := range users {
+			pending[i].Err = m.importUser(ctx, user, records[i], events[i])
+		}
+	} else if err != nil {
+		err = newInternalError(err, "Error importing the users at lines %d-%d", batch[0].Line, batch[len(batch)-1].Line)
+		for _, result := range pending {
+			result.Err = toError(err)
+		}
+		return results
+	}
+
+	for i, user := range users {
+		if pending[i].Err != nil {
+			continue
+		}
+		pending[i].ID = user.ID
+		emails[user.Email] = pending[i].Line
+		if err := m.sendActivationEmail(ctx, user); err != nil {
+			// The user has been created; another email can be sent through `SendActivationEmail`
+			log.Printf("[user_v1] error sending the activation email to user %s, err: %+v", user.ID, err)
+		}
+	}
+	return results
+}
+
+// importRow validates a row with the same rules as `Create` and returns the user to be created. The email must not
+// have been used by the rows in `emails` or by the rows of the batch in `batchEmails`, which the row is added to.
+// In dry runs the password is not hashed, and the returned user only carries the validated fields.
+func (m *manager) importRow(ctx context.Context, row *ImportRow, emails, batchEmails map[string]int, dryRun bool) (*User, error) {
+	firstName, lastName, email, err := m.validateNewUser(ctx, row.FirstName, row.LastName, row.Password, row.Email)
+	if err != nil {
+		return nil, err
+	}
+	line, ok := emails[email]
+	if !ok {
+		line, ok = batchEmails[email]
+	}
+	if ok {
+		return nil, newCodedError(ErrTypeConflict, CodeEmailTaken, map[string]string{"email": email, "line": strconv.Itoa(line)},
+			"The email %s has been used by the row at line %d.", email, line)
+	}
+
+	user := &User{FirstName: firstName, LastName: lastName, Email: email}
+	if !dryRun {
+		if user, err = m.newPendingUser(ctx, firstName, lastName, row.Password, email); err != nil {
+			return nil, err
+		}
+	}
+	batchEmails[email] = row.Line
+	return user, nil
+}
+
+// importUser creates a single imported user along with its record and event, returning the error of its row
+func (m *manager) importUser(ctx context.Context, user *User, record *auditV1.Record, event *outboxV1.Event) Error {
+	err := m.repo.CreateUser(ctx, user, record, event)
+	if errors.Is(err, ErrDuplicateRecord) {
+		return wrapError(err, newCodedError(ErrTypeConflict, CodeEmailTaken, map[string]string{"email": user.Email}, "The email %s has been used by another user.", user.Email))
+	}
+	if err != nil {
+		return newInternalError(err, "Error creating user {Name: %s %s, Email: %s}", user.FirstName, user.LastName, user.Email)
+	}
+	return nil
+}
+
+// toError converts the errors of this package, returned as `error`, back to `Error`
+func toError(err error) Error {
+	if e, ok := ConvertError(err); ok {
+		return e
+	}
+	return newInternalError(err, "Error importing the row")
+}
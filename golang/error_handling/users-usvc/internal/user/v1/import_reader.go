@@ -0,0 +1,155 @@
+package v1
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MaxImportLineBytes - the maximum size of a line read by the JSON-lines import reader
+const MaxImportLineBytes = 64 * 1024
+
+// importColumns - the columns of imported rows, which are named in the header row of CSV files and are the
+// members of the objects in JSON-lines files
+var importColumns = []string{FieldFirstName, FieldLastName, FieldEmail, FieldPassword}
+
+// csvImportReader is the implementation of ImportReader interface which reads CSV
+type csvImportReader struct {
+	r *csv.Reader
+	// columns maps the import columns to their indexes in the records. It is nil until the header row is read.
+	columns map[string]int
+}
+
+// NewCSVImportReader creates an instance of ImportReader which reads CSV. The first row is the header row, which
+// names the columns `first_name`, `last_name`, `email` and `password` in any order.
+func NewCSVImportReader(r io.Reader) ImportReader {
+	cr := csv.NewReader(r)
+	// The number of fields is checked per row so that a short row only fails itself
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	return &csvImportReader{
+		r: cr,
+	}
+}
+
+// Next - the implementation of the `Next` method
+func (r *csvImportReader) Next() (*ImportRow, error) {
+	if r.columns == nil {
+		if err := r.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+
+	record, err := r.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &ImportRow{Line: parseErr.StartLine, Err: newCodedError(ErrTypeBadRequest, CodeMalformedImportRow, map[string]string{"line": strconv.Itoa(parseErr.StartLine)},
+			"The row at line %d is not valid CSV: %s", parseErr.StartLine, parseErr.Err.Error())}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	line, _ := r.r.FieldPos(0)
+	if len(record) != len(r.columns) {
+		return &ImportRow{Line: line, Err: newCodedError(ErrTypeBadRequest, CodeMalformedImportRow, map[string]string{"line": strconv.Itoa(line)},
+			"The row at line %d has %d fields but the header row has %d.", line, len(record), len(r.columns))}, nil
+	}
+	return &ImportRow{
+		Line:      line,
+		FirstName: record[r.columns[FieldFirstName]],
+		LastName:  record[r.columns[FieldLastName]],
+		Email:     record[r.columns[FieldEmail]],
+		Password:  record[r.columns[FieldPassword]],
+	}, nil
+}
+
+// readHeader reads the header row and maps the columns to their indexes
+func (r *csvImportReader) readHeader() error {
+	header, err := r.r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return err
+		}
+		return wrapError(err, newCodedError(ErrTypeBadRequest, CodeMalformedImport, nil, "The header row is not valid CSV."))
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := columns[name]; ok {
+			return newCodedError(ErrTypeBadRequest, CodeMalformedImport, map[string]string{"column": name}, "The column %s appears more than once in the header row.", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range importColumns {
+		if _, ok := columns[name]; !ok {
+			return newCodedError(ErrTypeBadRequest, CodeMalformedImport, map[string]string{"column": name}, "The header row misses the column %s.", name)
+		}
+	}
+	if len(columns) != len(importColumns) {
+		return newCodedError(ErrTypeBadRequest, CodeMalformedImport, nil, "The header row must only name the columns %s.", strings.Join(importColumns, ", "))
+	}
+	r.columns = columns
+	return nil
+}
+
+// jsonLinesImportReader is the implementation of ImportReader interface which reads JSON lines
+type jsonLinesImportReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewJSONLinesImportReader creates an instance of ImportReader which reads JSON lines, one object per line with the
+// members `first_name`, `last_name`, `email` and `password`. Blank lines are skipped.
+func NewJSONLinesImportReader(r io.Reader) ImportReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 4096), MaxImportLineBytes)
+	return &jsonLinesImportReader{
+		s: s,
+	}
+}
+
+// Next - the implementation of the `Next` method
+func (r *jsonLinesImportReader) Next() (*ImportRow, error) {
+	for r.s.Scan() {
+		r.line++
+		b := bytes.TrimSpace(r.s.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		v := &struct {
+			FirstName string `json:"first_name"`
+			LastName  string `json:"last_name"`
+			Email     string `json:"email"`
+			Password  string `json:"password"`
+		}{}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(v)
+		if err == nil && dec.More() {
+			err = errors.New("more than one JSON value in the line")
+		}
+		if err != nil {
+			return &ImportRow{Line: r.line, Err: newCodedError(ErrTypeBadRequest, CodeMalformedImportRow, map[string]string{"line": strconv.Itoa(r.line)},
+				"The row at line %d is not a valid JSON object: %s", r.line, err.Error())}, nil
+		}
+		return &ImportRow{Line: r.line, FirstName: v.FirstName, LastName: v.LastName, Email: v.Email, Password: v.Password}, nil
+	}
+
+	err := r.s.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		return nil, newCodedError(ErrTypeBadRequest, CodeMalformedImport, map[string]string{"line": strconv.Itoa(r.line + 1)},
+			"The line %d exceeds %d bytes.", r.line+1, MaxImportLineBytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
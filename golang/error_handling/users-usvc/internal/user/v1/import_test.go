@@ -0,0 +1,278 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+	outboxV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/outbox/v1"
+)
+
+// testImportCSV - a CSV file with a valid row, an invalid row, a row which repeats the email of the first one,
+// a short row and another valid row
+const testImportCSV = "email, first_name,last_name,password\n" +
+	"ann@example.com,Ann,Lee," + testPassword + "\n" +
+	"bob,Bob,Lee,weak\n" +
+	"ANN@example.com,Anna,Lee," + testPassword + "\n" +
+	"cy@example.com,Cy\n" +
+	"di@example.com,Di,Lee," + testPassword + "\n"
+
+// readRows reads every row of an ImportReader
+func readRows(t *testing.T, r ImportReader) []*ImportRow {
+	t.Helper()
+	var rows []*ImportRow
+	for {
+		row, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return rows
+		}
+		if err != nil {
+			t.Fatalf("Next() err: %v", err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestImportReaders(t *testing.T) {
+	jsonLines := `{"first_name":"Ann","last_name":"Lee","email":"ann@example.com","password":"secret"}` + "\n\n" +
+		`{"first_name":"Bob","role":"admin"}` + "\n" +
+		`{"first_name":"Cy"} {}` + "\n" +
+		"not json\n"
+	tests := []struct {
+		name string
+		r    ImportReader
+		// want are the lines of the rows, and wantFailed the lines of the malformed rows
+		want, wantFailed []int
+	}{
+		{"csv", NewCSVImportReader(strings.NewReader(testImportCSV + "\"eve@example.com,Eve\n")), []int{2, 3, 4, 5, 6, 7}, []int{5, 7}},
+		{"json lines", NewJSONLinesImportReader(strings.NewReader(jsonLines)), []int{1, 3, 4, 5}, []int{3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := readRows(t, tt.r)
+			var lines, failed []int
+			for _, row := range rows {
+				lines = append(lines, row.Line)
+				if row.Err != nil {
+					failed = append(failed, row.Line)
+					if row.Err.Code() != CodeMalformedImportRow {
+						t.Errorf("the row at line %d err: %v, want %s", row.Line, row.Err, CodeMalformedImportRow)
+					}
+				}
+			}
+			if !equalInts(lines, tt.want) || !equalInts(failed, tt.wantFailed) {
+				t.Errorf("Next() read lines %v with %v malformed, want %v with %v malformed", lines, failed, tt.want, tt.wantFailed)
+			}
+			if first := rows[0]; first.FirstName != "Ann" || first.LastName != "Lee" || first.Email != "ann@example.com" || first.Err != nil {
+				t.Errorf("the first row = %+v, want Ann", first)
+			}
+		})
+	}
+}
+
+// equalInts tells whether two slices have the same ints in the same order
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestImportReadersMalformedFiles(t *testing.T) {
+	tests := map[string]ImportReader{
+		"missing column":     NewCSVImportReader(strings.NewReader("email,first_name,last_name\nann@example.com,Ann,Lee\n")),
+		"repeated column":    NewCSVImportReader(strings.NewReader("email,email,first_name,last_name,password\n")),
+		"unknown column":     NewCSVImportReader(strings.NewReader("email,first_name,last_name,password,role\n")),
+		"invalid header":     NewCSVImportReader(strings.NewReader("\"email,first_name\n")),
+		"json line too long": NewJSONLinesImportReader(strings.NewReader(strings.Repeat("x", MaxImportLineBytes+1) + "\n")),
+	}
+	for name, r := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := r.Next(); errCode(err) != CodeMalformedImport {
+				t.Errorf("Next() err: %v, want %s", err, CodeMalformedImport)
+			}
+		})
+	}
+
+	if _, err := NewCSVImportReader(strings.NewReader("")).Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next() of an empty file err: %v, want io.EOF", err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			m := newTestManager(t, repo)
+			mustCreate(t, ctx, m, "di@example.com")
+
+			report, err := m.Import(ctx, NewCSVImportReader(strings.NewReader(testImportCSV)), &ImportOptions{BatchSize: 2})
+			if err != nil {
+				t.Fatalf("Import() err: %v", err)
+			}
+			if report.Succeeded != 1 || report.Failed != 4 || report.FailedByType[ErrTypeBadRequest] != 2 || report.FailedByType[ErrTypeConflict] != 2 {
+				t.Errorf("Import() = %d succeeded and %d failed by type %v, want 1 and 2 bad requests and 2 conflicts",
+					report.Succeeded, report.Failed, report.FailedByType)
+			}
+
+			wantCodes := []string{"", CodeInvalidFields, CodeEmailTaken, CodeMalformedImportRow, CodeEmailTaken}
+			if len(report.Results) != len(wantCodes) {
+				t.Fatalf("Import() returned %d results, want %d", len(report.Results), len(wantCodes))
+			}
+			for i, result := range report.Results {
+				if code := errCode(result.Err); code != wantCodes[i] {
+					t.Errorf("the result at line %d err: %v, want %q", result.Line, result.Err, wantCodes[i])
+				}
+			}
+
+			created := report.Results[0]
+			user, err := m.Get(ctx, created.ID)
+			if err != nil || user.Email != "ann@example.com" || user.Status != UserStatusPending {
+				t.Errorf("Get() of the imported user = %+v, %v, want ann pending", user, err)
+			}
+			if list, _ := m.List(ctx, &ListFilter{}, "", 0); len(list.Users) != 2 {
+				t.Errorf("List() after the import returned %d users, want 2", len(list.Users))
+			}
+		})
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, nil)
+
+	report, err := m.Import(ctx, NewCSVImportReader(strings.NewReader(testImportCSV)), &ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Import() err: %v", err)
+	}
+	// The rows are validated, including the emails repeated in the file, but nothing is created
+	if !report.DryRun || report.Succeeded != 2 || report.Failed != 3 {
+		t.Errorf("Import() = %+v, want a dry run with 2 valid and 3 failed rows", report)
+	}
+	for _, result := range report.Results {
+		if result.ID != "" {
+			t.Errorf("the result at line %d has the ID %s in a dry run", result.Line, result.ID)
+		}
+	}
+	if list, _ := m.List(ctx, &ListFilter{}, "", 0); len(list.Users) != 0 {
+		t.Errorf("List() after a dry run returned %d users, want none", len(list.Users))
+	}
+
+	if _, err := m.Import(ctx, NewCSVImportReader(strings.NewReader(testImportCSV)), &ImportOptions{BatchSize: MaxImportBatchSize + 1}); errType(err) != ErrTypeBadRequest {
+		t.Errorf("Import() with a batch size over the maximum err: %v, want %s", err, ErrTypeBadRequest)
+	}
+}
+
+// failingImportReader - an ImportReader which fails after returning the rows of another reader up to a line
+type failingImportReader struct {
+	ImportReader
+	failAfter int
+	line      int
+}
+
+// Next - the implementation of the `Next` method
+func (r *failingImportReader) Next() (*ImportRow, error) {
+	if r.line >= r.failAfter {
+		return nil, errors.New("connection reset")
+	}
+	row, err := r.ImportReader.Next()
+	if row != nil {
+		r.line = row.Line
+	}
+	return row, err
+}
+
+func TestImportReportsRowsBeforeReaderErrors(t *testing.T) {
+	ctx := context.Background()
+	var b strings.Builder
+	b.WriteString("email,first_name,last_name,password\n")
+	for _, name := range []string{"ann", "bob", "cy", "di", "eve"} {
+		b.WriteString(name + "@example.com,Ann,Lee," + testPassword + "\n")
+	}
+	m := newTestManager(t, nil)
+
+	rows := &failingImportReader{ImportReader: NewCSVImportReader(strings.NewReader(b.String())), failAfter: 4}
+	report, err := m.Import(ctx, rows, &ImportOptions{BatchSize: 2})
+	if e, ok := ConvertError(err); !ok || e.Code() != CodeMalformedImport || e.Metadata()["line"] != "4" {
+		t.Errorf("Import() err: %v, want %s after line 4", err, CodeMalformedImport)
+	}
+	// The first batch has been created; the rows read into the failed batch are not
+	if report == nil || report.Succeeded != 2 || len(report.Results) != 2 {
+		t.Fatalf("Import() = %+v, want the report of the first batch", report)
+	}
+	if list, _ := m.List(ctx, &ListFilter{}, "", 0); len(list.Users) != 2 {
+		t.Errorf("List() after the failed import returned %d users, want 2", len(list.Users))
+	}
+}
+
+// failingBatchRepository - a repository whose `CreateUsers` fails once
+type failingBatchRepository struct {
+	Repository
+	failed bool
+}
+
+// CreateUsers - the implementation of the `CreateUsers` method
+func (r *failingBatchRepository) CreateUsers(ctx context.Context, users []*User, records []*auditV1.Record, events ...*outboxV1.Event) error {
+	if !r.failed {
+		r.failed = true
+		return errors.New("connection reset")
+	}
+	return r.Repository.CreateUsers(ctx, users, records, events...)
+}
+
+func TestImportDoesNotKeepEmailsOfFailedBatches(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, &failingBatchRepository{Repository: NewMemoryRepository()})
+	csv := "email,first_name,last_name,password\n" +
+		"ann@example.com,Ann,Lee," + testPassword + "\n" +
+		"bob@example.com,Bob,Lee," + testPassword + "\n" +
+		"ann@example.com,Ann,Lee," + testPassword + "\n"
+
+	report, err := m.Import(ctx, NewCSVImportReader(strings.NewReader(csv)), &ImportOptions{BatchSize: 2})
+	if err != nil {
+		t.Fatalf("Import() err: %v", err)
+	}
+	// The first batch fails, so the row retrying its email in the next batch is created
+	wantTypes := []ErrType{ErrTypeInternalServerErr, ErrTypeInternalServerErr, ""}
+	for i, result := range report.Results {
+		if got := errType(result.Err); got != wantTypes[i] {
+			t.Errorf("the result at line %d err: %v, want %q", result.Line, result.Err, wantTypes[i])
+		}
+	}
+	if _, err := m.GetByEmail(ctx, "ann@example.com"); err != nil {
+		t.Errorf("GetByEmail() of the retried row err: %v", err)
+	}
+}
+
+func TestImportRecordsUsers(t *testing.T) {
+	for name, audited := range testAuditedRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := auditV1.WithActor(context.Background(), "admin-1")
+			report, err := newTestManager(t, audited.repo).Import(ctx, NewCSVImportReader(strings.NewReader(testImportCSV)), nil)
+			if err != nil {
+				t.Fatalf("Import() err: %v", err)
+			}
+
+			list, err := audited.auditLog.Query(context.Background(), &auditV1.Query{Action: AuditActionImport})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list.Records) != report.Succeeded {
+				t.Fatalf("the audit log has %d import records, want %d", len(list.Records), report.Succeeded)
+			}
+			for _, r := range list.Records {
+				if r.Actor != "admin-1" || r.Changes["email"] == nil {
+					t.Errorf("the import record = %+v, want the created user by admin-1", r)
+				}
+			}
+		})
+	}
+}
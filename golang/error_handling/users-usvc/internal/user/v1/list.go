@@ -0,0 +1,64 @@
+package v1
+
+import (
+	"context"
+	"encoding/base64"
+)
+
+// Page sizes used by `List`
+const (
+	// DefaultListLimit - the page size used when the given limit is not positive
+	DefaultListLimit = 20
+	// MaxListLimit - the maximum page size
+	MaxListLimit = 100
+)
+
+// UserList represents a page of users returned by `List`
+type UserList struct {
+	Users []*User
+	// NextCursor is empty if there are no more users
+	NextCursor string
+}
+
+// List - the implementation of the `List` method. It uses keyset pagination on user IDs and the cursor is the
+// encoded ID of the last user in the previous page.
+func (m *manager) List(ctx context.Context, filter *ListFilter, cursor string, limit int) (*UserList, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		return nil, newError(ErrTypeBadRequest, "The limit %d exceeds the maximum %d.", limit, MaxListLimit)
+	}
+
+	afterID, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, newError(ErrTypeBadRequest, "The cursor %s is invalid.", cursor)
+	}
+
+	// Fetch one more user to find out whether there is a next page
+	users, err := m.repo.ListUsers(ctx, filter, afterID, limit+1)
+	if err != nil {
+		return nil, newInternalError(err, "Error listing users")
+	}
+
+	list := &UserList{Users: users}
+	if len(users) > limit {
+		list.Users = users[:limit]
+		list.NextCursor = encodeCursor(list.Users[limit-1].ID)
+	}
+	return list, nil
+}
+
+// encodeCursor encodes the ID of the last user in a page to a cursor
+func encodeCursor(ID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(ID))
+}
+
+// decodeCursor decodes a cursor to the ID of the last user in the previous page
+func decodeCursor(cursor string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,64 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message - an email sent to a user
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer defines the interface for sending emails to users. Implement it to plug in an SMTP server or an email service.
+type Mailer interface {
+	// Send sends the message
+	Send(ctx context.Context, msg *Message) error
+}
+
+// writerMailer is the implementation of Mailer interface which writes messages to an `io.Writer`.
+// It is meant for local development.
+type writerMailer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterMailer creates an instance of Mailer which writes messages to the given writer
+func NewWriterMailer(w io.Writer) Mailer {
+	return &writerMailer{
+		w: w,
+	}
+}
+
+// NewStdoutMailer creates an instance of Mailer which prints messages to the standard output
+func NewStdoutMailer() Mailer {
+	return NewWriterMailer(os.Stdout)
+}
+
+// NewFileMailer creates an instance of Mailer which appends messages to the file at the given path
+func NewFileMailer(path string) (Mailer, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the mail file %s, err: %s", path, err.Error())
+	}
+	return NewWriterMailer(f), nil
+}
+
+// Send - the implementation of the `Send` method
+func (m *writerMailer) Send(ctx context.Context, msg *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.w, "To: %s\nSubject: %s\nDate: %s\n\n%s\n\n----\n",
+		msg.To, msg.Subject, time.Now().UTC().Format(time.RFC1123Z), msg.Body)
+	return err
+}
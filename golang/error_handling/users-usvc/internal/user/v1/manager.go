@@ -1,18 +1,190 @@
 package v1
 
-// Manager defines the interface for manipulating user info in the databse
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Manager defines the interface for manipulating user info in the databse.
+// Every method stops its work once the given context is canceled or its deadline passes.
+// Every method works on the users of the tenant in the given context, see `tenant.WithID`. Users of other tenants
+// are reported as not found, and an email can be used once per tenant.
 //
 type Manager interface {
-	Create(firstName, lastName, password, email string) (ID string, err error)
+	// Create creates a user and returns its ID. If an idempotency key is given, retries with the same key and input
+	// return the ID of the user created by the first request instead of creating another user.
+	Create(ctx context.Context, firstName, lastName, password, email, idempotencyKey string) (ID string, err error)
+	// Get returns the user with the given ID. Soft deleted users are treated as not found.
+	Get(ctx context.Context, ID string) (*User, error)
+	// GetByEmail returns the user with the given email. Soft deleted users are treated as not found.
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	// List returns a page of users matching the filter. Pass the `NextCursor` of a page to get the next one.
+	List(ctx context.Context, filter *ListFilter, cursor string, limit int) (*UserList, error)
+	// Update updates the fields listed in the mask (see `UpdateMask*`) with the values in `update`. `version` is
+	// the version of the user the update is based on; it fails with a conflict if the user has changed since.
+	Update(ctx context.Context, ID string, version int, update *UserUpdate, mask []string) (*User, error)
+	// Delete deletes the user with the given ID. Soft deleted users are kept in the database and can be listed
+	// with `ListFilter.IncludeDeleted`, while hard deleted users are removed permanently.
+	Delete(ctx context.Context, ID string, hard bool) error
+	// VerifyCredentials returns the user with the given email if the password matches and the user is active
+	VerifyCredentials(ctx context.Context, email, password string) (*User, error)
+	// ActivateUser verifies the email of a pending user with the token sent by email and activates the user.
+	// Each token can be used once. The user is looked up in the tenant the token was issued in, not the one in the
+	// context, as activation links carry no tenant.
+	ActivateUser(ctx context.Context, token string) (*User, error)
+	// SendActivationEmail sends another activation email to a pending user, e.g. when the first one has expired
+	SendActivationEmail(ctx context.Context, ID string) error
+	// SetStatus moves the user to the given status, e.g. to disable or re-enable the user
+	SetStatus(ctx context.Context, ID string, status UserStatus) (*User, error)
+	// RequestPasswordReset sends a password reset token to the user with the given email in the background. It
+	// succeeds even if no active user has the email or the email cannot be sent, so that callers cannot find out
+	// which emails have been registered.
+	RequestPasswordReset(ctx context.Context, email string) error
+	// ResetPassword sets the password of the user who the reset token was sent to. Each token can be used once.
+	// Like `ActivateUser`, it works in the tenant the token was issued in.
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	// ChangePassword sets the password of the user with the given ID if the old password matches
+	ChangePassword(ctx context.Context, ID, oldPassword, newPassword string) error
+	// Import creates users from the rows read from the reader, validated with the same rules as `Create`. A failing
+	// row does not stop the others, and the report tells the result of every row. Importing a file again is safe,
+	// as the rows which have been imported fail with `CodeEmailTaken`. If reading the rows fails, e.g. on a line
+	// which is too long, the import stops and the error is returned with the report of the batches created before,
+	// whose users are kept.
+	Import(ctx context.Context, rows ImportReader, opts *ImportOptions) (*ImportReport, error)
+	// Export writes the users matching the filter to the writer, ordered by ID
+	Export(ctx context.Context, filter *ListFilter, w ExportWriter) error
 }
 
 // manager is the implementation of Manager interface
 //
 type manager struct {
+	repo      Repository
+	hasher    PasswordHasher
+	validator Validator
+	// idempotencyTTL is how long idempotency keys are kept
+	idempotencyTTL time.Duration
+	// dummyHash is verified against when a user does not exist
+	dummyHash string
+	mailer    Mailer
+	tokens    *tokenSigner
+	// activationTTL is how long activation tokens are valid
+	activationTTL time.Duration
+	// activationURL is the page which activation links point to
+	activationURL string
+	// passwordResetTTL is how long password reset tokens are valid
+	passwordResetTTL time.Duration
+	// passwordResetURL is the page which password reset links point to
+	passwordResetURL string
+	// mails tracks the emails being sent in the background
+	mails sync.WaitGroup
+}
+
+// Option configures optional dependencies of a Manager
+type Option func(m *manager)
+
+// WithPasswordHasher sets the PasswordHasher used to hash and verify passwords.
+// A bcrypt hasher with the default cost is used if it is not set.
+func WithPasswordHasher(hasher PasswordHasher) Option {
+	return func(m *manager) {
+		m.hasher = hasher
+	}
+}
+
+// WithValidator sets the Validator used to validate user input.
+// A validator with the default config and no breached password list is used if it is not set.
+func WithValidator(validator Validator) Option {
+	return func(m *manager) {
+		m.validator = validator
+	}
+}
+
+// WithIdempotencyTTL sets how long idempotency keys passed to `Create` are kept. It is 24 hours if it is not set.
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(m *manager) {
+		m.idempotencyTTL = ttl
+	}
+}
+
+// WithMailer sets the Mailer used to send emails to users. Emails are printed to the standard output if it is not set,
+// which is only meant for local development.
+func WithMailer(mailer Mailer) Option {
+	return func(m *manager) {
+		m.mailer = mailer
+	}
+}
+
+// WithTokenSecret sets the secret used to sign the tokens sent to users. It should be at least 32 random bytes
+// and shared by all instances of the service. A random secret is generated if it is not set, so tokens are
+// invalidated when the service restarts.
+func WithTokenSecret(secret []byte) Option {
+	return func(m *manager) {
+		m.tokens = &tokenSigner{secret: secret}
+	}
+}
+
+// WithActivationTokenTTL sets how long activation tokens are valid. It is 48 hours if it is not set.
+func WithActivationTokenTTL(ttl time.Duration) Option {
+	return func(m *manager) {
+		m.activationTTL = ttl
+	}
+}
+
+// WithActivationURL sets the page which activation links point to, e.g. `https://example.com/activate`.
+// The token is passed in the `token` query parameter. Emails only contain the token if it is not set.
+func WithActivationURL(activationURL string) Option {
+	return func(m *manager) {
+		m.activationURL = activationURL
+	}
+}
+
+// WithPasswordResetTokenTTL sets how long password reset tokens are valid. It is 1 hour if it is not set.
+func WithPasswordResetTokenTTL(ttl time.Duration) Option {
+	return func(m *manager) {
+		m.passwordResetTTL = ttl
+	}
+}
 
+// WithPasswordResetURL sets the page which password reset links point to, e.g. `https://example.com/reset-password`.
+// The token is passed in the `token` query parameter. Emails only contain the token if it is not set.
+func WithPasswordResetURL(passwordResetURL string) Option {
+	return func(m *manager) {
+		m.passwordResetURL = passwordResetURL
+	}
 }
 
-// NewManager creates an instance of Manager
-func NewManager() Manager {
-	return &manager{}
+// NewManager creates an instance of Manager which stores users in the given repository
+func NewManager(repo Repository, opts ...Option) Manager {
+	m := &manager{
+		repo:             repo,
+		idempotencyTTL:   DefaultIdempotencyTTL,
+		activationTTL:    DefaultActivationTokenTTL,
+		passwordResetTTL: DefaultPasswordResetTokenTTL,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.hasher == nil {
+		// The default config is always valid
+		m.hasher, _ = NewPasswordHasher(DefaultPasswordConfig())
+	}
+	if m.validator == nil {
+		// The default config does not load any file so it never fails
+		m.validator, _ = NewValidator(DefaultValidatorConfig())
+	}
+	if m.mailer == nil {
+		m.mailer = NewStdoutMailer()
+	}
+	if m.tokens == nil {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			// crypto/rand does not fail on supported platforms, and tokens must never be signed with a predictable secret
+			panic(fmt.Sprintf("error generating the token secret, err: %s", err.Error()))
+		}
+		m.tokens = &tokenSigner{secret: secret}
+	}
+	m.dummyHash, _ = m.hasher.Hash("dummy-password")
+	return m
 }
@@ -0,0 +1,199 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPassword - a password used to create users in tests
+const testPassword = "Passw0rd!xyz"
+
+// newTestManager creates a manager backed by the given repository, or by a memory repository if it is nil.
+// Passwords are hashed with the minimum bcrypt cost to keep tests fast, and emails are discarded unless a mailer
+// is given in the options.
+func newTestManager(t *testing.T, repo Repository, opts ...Option) Manager {
+	t.Helper()
+	if repo == nil {
+		repo = NewMemoryRepository()
+	}
+	cfg := DefaultPasswordConfig()
+	cfg.BcryptCost = bcrypt.MinCost
+	hasher, err := NewPasswordHasher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewManager(repo, append([]Option{WithPasswordHasher(hasher), WithMailer(NewWriterMailer(io.Discard))}, opts...)...)
+}
+
+// mustCreate creates a user with the given email and returns its ID
+func mustCreate(t *testing.T, ctx context.Context, m Manager, email string) string {
+	t.Helper()
+	ID, err := m.Create(ctx, "Ann", "Lee", testPassword, email, "")
+	if err != nil {
+		t.Fatalf("Create(%s) err: %v", email, err)
+	}
+	return ID
+}
+
+// errType returns the type of the error, or an empty type if it is not an Error
+func errType(err error) ErrType {
+	if e, ok := ConvertError(err); ok {
+		return e.Type()
+	}
+	return ""
+}
+
+func TestManagerCreateAndGet(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			m := newTestManager(t, repo)
+
+			ID, err := m.Create(ctx, "Ann", "Lee", testPassword, "ann@example.com", "")
+			if err != nil {
+				t.Fatalf("Create() err: %v", err)
+			}
+			user, err := m.Get(ctx, ID)
+			if err != nil || user.FirstName != "Ann" || user.Email != "ann@example.com" {
+				t.Errorf("Get() = %+v, %v, want the created user", user, err)
+			}
+			if user.PasswordHash == testPassword {
+				t.Error("Get() returned the password in plain text")
+			}
+			if got, err := m.GetByEmail(ctx, "ann@example.com"); err != nil || got.ID != ID {
+				t.Errorf("GetByEmail() = %v, %v, want user %s", got, err, ID)
+			}
+			if _, err := m.Get(ctx, "missing"); errType(err) != ErrTypeNotFound {
+				t.Errorf("Get() of a missing user err: %v, want %s", err, ErrTypeNotFound)
+			}
+
+			tests := []struct {
+				name, password, email string
+				want                  ErrType
+			}{
+				{"taken email", testPassword, "ann@example.com", ErrTypeConflict},
+				{"empty password", "", "bob@example.com", ErrTypeBadRequest},
+				{"unprintable password", "Passw0rd!\tyz", "bob@example.com", ErrTypeBadRequest},
+				{"invalid email", testPassword, "bob", ErrTypeBadRequest},
+			}
+			for _, tt := range tests {
+				if _, err := m.Create(ctx, "Bob", "Lee", tt.password, tt.email, ""); errType(err) != tt.want {
+					t.Errorf("Create() with a %s err: %v, want %s", tt.name, err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestManagerList(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, nil)
+	for i := 0; i < 5; i++ {
+		mustCreate(t, ctx, m, fmt.Sprintf("user%d@example.com", i))
+	}
+
+	var emails []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages == 3 {
+			t.Fatal("List() did not stop after 3 pages")
+		}
+		list, err := m.List(ctx, &ListFilter{}, cursor, 2)
+		if err != nil {
+			t.Fatalf("List() err: %v", err)
+		}
+		for _, user := range list.Users {
+			emails = append(emails, user.Email)
+		}
+		if cursor = list.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if len(emails) != 5 {
+		t.Errorf("List() returned %d users in all pages, want 5: %v", len(emails), emails)
+	}
+
+	list, err := m.List(ctx, &ListFilter{Email: "user3@example.com"}, "", 0)
+	if err != nil || len(list.Users) != 1 || list.NextCursor != "" {
+		t.Errorf("List() filtered by email = %+v, %v, want 1 user", list, err)
+	}
+
+	if _, err := m.List(ctx, &ListFilter{}, "", MaxListLimit+1); errType(err) != ErrTypeBadRequest {
+		t.Errorf("List() over the maximum limit err: %v, want %s", err, ErrTypeBadRequest)
+	}
+	if _, err := m.List(ctx, &ListFilter{}, "not base64!", 0); errType(err) != ErrTypeBadRequest {
+		t.Errorf("List() with an invalid cursor err: %v, want %s", err, ErrTypeBadRequest)
+	}
+}
+
+func TestManagerUpdate(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, nil)
+	ID := mustCreate(t, ctx, m, "ann@example.com")
+	mustCreate(t, ctx, m, "bob@example.com")
+
+	user, err := m.Update(ctx, ID, 1, &UserUpdate{FirstName: "Anna", LastName: "ignored"}, []string{UpdateMaskFirstName})
+	if err != nil {
+		t.Fatalf("Update() err: %v", err)
+	}
+	if user.FirstName != "Anna" || user.LastName != "Lee" {
+		t.Errorf("Update() = %+v, want only the first name changed", user)
+	}
+
+	tests := []struct {
+		name   string
+		update *UserUpdate
+		mask   []string
+		want   ErrType
+	}{
+		{"empty mask", &UserUpdate{FirstName: "Anna"}, nil, ErrTypeBadRequest},
+		{"invalid name", &UserUpdate{FirstName: "4nna"}, []string{UpdateMaskFirstName}, ErrTypeBadRequest},
+		{"unknown field", &UserUpdate{}, []string{"password"}, ErrTypeBadRequest},
+		{"taken email", &UserUpdate{Email: "bob@example.com"}, []string{UpdateMaskEmail}, ErrTypeConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.Update(ctx, ID, 2, tt.update, tt.mask); errType(err) != tt.want {
+				t.Errorf("Update() err: %v, want %s", err, tt.want)
+			}
+		})
+	}
+	if _, err := m.Update(ctx, "missing", 1, &UserUpdate{FirstName: "Anna"}, []string{UpdateMaskFirstName}); errType(err) != ErrTypeNotFound {
+		t.Errorf("Update() of a missing user err: %v, want %s", err, ErrTypeNotFound)
+	}
+}
+
+func TestManagerDelete(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, nil)
+	ID := mustCreate(t, ctx, m, "ann@example.com")
+
+	if err := m.Delete(ctx, ID, false); err != nil {
+		t.Fatalf("Delete() err: %v", err)
+	}
+	if _, err := m.Get(ctx, ID); errType(err) != ErrTypeNotFound {
+		t.Errorf("Get() of a soft deleted user err: %v, want %s", err, ErrTypeNotFound)
+	}
+	if err := m.Delete(ctx, ID, false); errType(err) != ErrTypeNotFound {
+		t.Errorf("Delete() of a soft deleted user err: %v, want %s", err, ErrTypeNotFound)
+	}
+	list, err := m.List(ctx, &ListFilter{IncludeDeleted: true}, "", 0)
+	if err != nil || len(list.Users) != 1 || list.Users[0].DeletedAt == nil {
+		t.Errorf("List() including deleted users = %+v, %v, want the soft deleted user", list, err)
+	}
+	if list, err := m.List(ctx, &ListFilter{}, "", 0); err != nil || len(list.Users) != 0 {
+		t.Errorf("List() = %+v, %v, want no users", list, err)
+	}
+
+	// Soft deleted users can still be hard deleted
+	if err := m.Delete(ctx, ID, true); err != nil {
+		t.Fatalf("Delete() hard err: %v", err)
+	}
+	if list, err := m.List(ctx, &ListFilter{IncludeDeleted: true}, "", 0); err != nil || len(list.Users) != 0 {
+		t.Errorf("List() after a hard delete = %+v, %v, want no users", list, err)
+	}
+}
@@ -0,0 +1,83 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	outboxV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/outbox/v1"
+)
+
+func TestManagerWritesEvents(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			m := newTestManager(t, repo)
+			ID := mustCreate(t, ctx, m, "ann@example.com")
+			if _, err := m.Update(ctx, ID, 1, &UserUpdate{FirstName: "Anna"}, []string{UpdateMaskFirstName}); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Delete(ctx, ID, true); err != nil {
+				t.Fatal(err)
+			}
+			// Failed changes write no events
+			if _, err := m.Create(ctx, "Ann", "Lee", "weak", "bob@example.com", ""); err == nil {
+				t.Fatal("Create() with a weak password err: nil")
+			}
+			if _, err := m.Update(ctx, ID, 2, &UserUpdate{FirstName: "Ann"}, []string{UpdateMaskFirstName}); err == nil {
+				t.Fatal("Update() of a deleted user err: nil")
+			}
+
+			events, err := repo.ListPendingEvents(ctx, 10)
+			if err != nil {
+				t.Fatalf("ListPendingEvents() err: %v", err)
+			}
+			want := []string{EventTypeUserCreated, EventTypeUserUpdated, EventTypeUserDeleted}
+			if len(events) != len(want) {
+				t.Fatalf("ListPendingEvents() returned %d events, want %v", len(events), want)
+			}
+			for i, e := range events {
+				if e.Type != want[i] || e.AggregateID != ID {
+					t.Errorf("event %d = %s of %s, want %s of %s", i, e.Type, e.AggregateID, want[i], ID)
+				}
+			}
+
+			payload := &UserEvent{}
+			if err := json.Unmarshal(events[1].Payload, payload); err != nil || payload.FirstName != "Anna" || payload.Version != 2 {
+				t.Errorf("the payload of the update event = %s, want the updated user, err: %v", events[1].Payload, err)
+			}
+			if strings.Contains(string(events[0].Payload), "password") {
+				t.Errorf("the payload of the create event exposes the password hash: %s", events[0].Payload)
+			}
+		})
+	}
+}
+
+func TestRelayUserEvents(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			m := newTestManager(t, repo)
+			for _, email := range []string{"ann@example.com", "bob@example.com", "cid@example.com"} {
+				mustCreate(t, ctx, m, email)
+			}
+
+			var published []string
+			p := outboxV1.NewInProcessPublisher()
+			p.Subscribe(EventTypeUserCreated, func(ctx context.Context, e *outboxV1.Event) error {
+				published = append(published, e.ID)
+				return nil
+			})
+			relay := outboxV1.NewRelay(repo, p, outboxV1.WithBatchSize(2))
+			for _, want := range []int{2, 1, 0} {
+				if n, err := relay.RelayOnce(ctx); n != want || err != nil {
+					t.Fatalf("RelayOnce() = %d, %v, want %d", n, err, want)
+				}
+			}
+			if len(published) != 3 || published[0] >= published[1] || published[1] >= published[2] {
+				t.Errorf("the published events = %v, want the 3 events in order", published)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package v1
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported password hashing algorithms
+const (
+	// PasswordAlgorithmBcrypt - bcrypt
+	PasswordAlgorithmBcrypt = "bcrypt"
+	// PasswordAlgorithmArgon2id - argon2id
+	PasswordAlgorithmArgon2id = "argon2id"
+)
+
+// PasswordConfig defines the algorithm and the cost parameters used to hash passwords
+type PasswordConfig struct {
+	// Algorithm is either PasswordAlgorithmBcrypt or PasswordAlgorithmArgon2id
+	Algorithm string
+
+	// BcryptCost is the bcrypt cost factor
+	BcryptCost int
+
+	// Argon2Time is the number of argon2id passes over the memory
+	Argon2Time uint32
+	// Argon2Memory is the size of the argon2id memory in KiB
+	Argon2Memory uint32
+	// Argon2Threads is the number of argon2id threads
+	Argon2Threads uint8
+	// Argon2KeyLen is the length of the argon2id key in bytes
+	Argon2KeyLen uint32
+	// Argon2SaltLen is the length of the argon2id salt in bytes
+	Argon2SaltLen uint32
+}
+
+// DefaultPasswordConfig returns the config used when no PasswordHasher is given to `NewManager`
+func DefaultPasswordConfig() PasswordConfig {
+	return PasswordConfig{
+		Algorithm:     PasswordAlgorithmBcrypt,
+		BcryptCost:    bcrypt.DefaultCost,
+		Argon2Time:    3,
+		Argon2Memory:  64 * 1024,
+		Argon2Threads: 2,
+		Argon2KeyLen:  32,
+		Argon2SaltLen: 16,
+	}
+}
+
+// PasswordHasher defines the interface for hashing and verifying passwords
+type PasswordHasher interface {
+	// Hash hashes the password with the configured algorithm and cost
+	Hash(password string) (string, error)
+	// Verify checks whether the password matches the hash. The hash can be produced by any supported algorithm.
+	Verify(hash, password string) (bool, error)
+	// NeedsRehash checks whether the hash was produced with an algorithm or cost other than the configured ones
+	NeedsRehash(hash string) bool
+}
+
+// passwordHasher is the implementation of PasswordHasher interface
+type passwordHasher struct {
+	cfg PasswordConfig
+}
+
+// NewPasswordHasher creates an instance of PasswordHasher with the given config
+func NewPasswordHasher(cfg PasswordConfig) (PasswordHasher, error) {
+	switch cfg.Algorithm {
+	case PasswordAlgorithmBcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost %d", cfg.BcryptCost)
+		}
+	case PasswordAlgorithmArgon2id:
+		if cfg.Argon2Time == 0 || cfg.Argon2Memory == 0 || cfg.Argon2Threads == 0 || cfg.Argon2KeyLen == 0 || cfg.Argon2SaltLen == 0 {
+			return nil, fmt.Errorf("invalid argon2id parameters %+v", cfg)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password algorithm %q", cfg.Algorithm)
+	}
+	return &passwordHasher{
+		cfg: cfg,
+	}, nil
+}
+
+// Hash - the implementation of the `Hash` method
+func (h *passwordHasher) Hash(password string) (string, error) {
+	if h.cfg.Algorithm == PasswordAlgorithmArgon2id {
+		salt := make([]byte, h.cfg.Argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		p := argon2Params{
+			memory:  h.cfg.Argon2Memory,
+			time:    h.cfg.Argon2Time,
+			threads: h.cfg.Argon2Threads,
+			salt:    salt,
+		}
+		p.key = argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, h.cfg.Argon2KeyLen)
+		return p.encode(), nil
+	}
+
+	b, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Verify - the implementation of the `Verify` method
+func (h *passwordHasher) Verify(hash, password string) (bool, error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		p, err := decodeArgon2Params(hash)
+		if err != nil {
+			return false, err
+		}
+		key := argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.threads, uint32(len(p.key)))
+		return subtle.ConstantTimeCompare(key, p.key) == 1, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NeedsRehash - the implementation of the `NeedsRehash` method
+func (h *passwordHasher) NeedsRehash(hash string) bool {
+	if h.cfg.Algorithm == PasswordAlgorithmArgon2id {
+		p, err := decodeArgon2Params(hash)
+		if err != nil {
+			return true
+		}
+		return p.time != h.cfg.Argon2Time || p.memory != h.cfg.Argon2Memory || p.threads != h.cfg.Argon2Threads ||
+			uint32(len(p.key)) != h.cfg.Argon2KeyLen || uint32(len(p.salt)) != h.cfg.Argon2SaltLen
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cfg.BcryptCost
+}
+
+// argon2Params - parameters encoded in an argon2id hash
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// encode encodes the parameters in the PHC string format, e.g. `$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>`
+func (p *argon2Params) encode() string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(p.salt), base64.RawStdEncoding.EncodeToString(p.key))
+}
+
+// decodeArgon2Params decodes an argon2id hash produced by `encode`
+func decodeArgon2Params(hash string) (*argon2Params, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2id version %q", parts[2])
+	}
+
+	p := &argon2Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return nil, fmt.Errorf("invalid argon2id parameters %q", parts[3])
+	}
+
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id salt")
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id key")
+	}
+	return p, nil
+}
@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// ChangePassword - the implementation of the `ChangePassword` method
+func (m *manager) ChangePassword(ctx context.Context, ID, oldPassword, newPassword string) error {
+	user, err := m.Get(ctx, ID)
+	if err != nil {
+		return err
+	}
+
+	ok, err := m.hasher.Verify(user.PasswordHash, oldPassword)
+	if err != nil {
+		return newInternalError(err, "Error verifying the password of user %s", user.ID)
+	}
+	if !ok {
+		return newCodedError(ErrTypeUnauthorized, CodeIncorrectPassword, nil, "The current password is incorrect.")
+	}
+
+	violations := m.validator.ValidatePassword(newPassword)
+	if newPassword == oldPassword {
+		violations = append(violations, FieldViolation{Field: FieldPassword, Description: "The new password must differ from the current one."})
+	}
+	if len(violations) > 0 {
+		return newValidationError(violations)
+	}
+
+	before := *user
+	return m.setPassword(ctx, AuditActionChangePassword, &before, user, newPassword)
+}
+
+// setPassword hashes and stores the new password of the user. It invalidates the existing sessions of the user
+// by bumping the session version, and revokes the password reset tokens which have not been used. `action` and
+// the state of the user `before` the change are recorded in the audit log.
+func (m *manager) setPassword(ctx context.Context, action string, before, user *User, password string) error {
+	hash, err := m.hasher.Hash(password)
+	if err != nil {
+		return newInternalError(err, "Error hashing the password")
+	}
+
+	now := time.Now().UTC()
+	user.PasswordHash = hash
+	user.SessionVersion++
+	user.Version++
+	user.UpdatedAt = now
+	record, err := newAuditRecord(ctx, action, before, user)
+	if err != nil {
+		return err
+	}
+	event, err := newUserEvent(EventTypeUserUpdated, user)
+	if err != nil {
+		return newInternalError(err, "Error creating the event of user %s", user.ID)
+	}
+	err = m.repo.UpdateUser(ctx, user, record, event)
+	if errors.Is(err, ErrVersionMismatch) {
+		return newVersionMismatchError(err, user.ID)
+	}
+	if errors.Is(err, ErrRecordNotFound) {
+		return newCodedError(ErrTypeNotFound, CodeUserNotFound, map[string]string{"id": user.ID}, "The user %s does not exist.", user.ID)
+	}
+	if err != nil {
+		return newInternalError(err, "Error updating the password of user %s", user.ID)
+	}
+
+	if err := m.repo.RevokeTokens(ctx, user.ID, TokenPurposePasswordReset, now); err != nil {
+		// The password has been changed; the remaining tokens expire soon anyway
+		log.Printf("[user_v1] error revoking the password reset tokens of user %s, err: %s", user.ID, err.Error())
+	}
+	return nil
+}
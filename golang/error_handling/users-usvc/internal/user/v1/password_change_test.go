@@ -0,0 +1,47 @@
+package v1
+
+import (
+	"context"
+	"testing"
+)
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	m, mailer := newTestMailManager(t, nil)
+	ID := newActiveUser(t, ctx, m, "ann@example.com")
+	requestPasswordReset(t, ctx, m, "ann@example.com")
+	resetToken := mailer.lastToken(t, "ann@example.com")
+
+	tests := []struct {
+		name, oldPassword, newPassword string
+		wantCode                       string
+	}{
+		{"wrong current password", "Wrong-passw0rd", "N3w-passw0rd!", CodeIncorrectPassword},
+		{"weak new password", testPassword, "weak", CodeInvalidFields},
+		{"same password", testPassword, testPassword, CodeInvalidFields},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.ChangePassword(ctx, ID, tt.oldPassword, tt.newPassword); errCode(err) != tt.wantCode {
+				t.Errorf("ChangePassword() err: %v, want %s", err, tt.wantCode)
+			}
+		})
+	}
+
+	if err := m.ChangePassword(ctx, ID, testPassword, "N3w-passw0rd!"); err != nil {
+		t.Fatalf("ChangePassword() err: %v", err)
+	}
+	if _, err := m.VerifyCredentials(ctx, "ann@example.com", "N3w-passw0rd!"); err != nil {
+		t.Errorf("VerifyCredentials() with the new password err: %v", err)
+	}
+	if user, _ := m.Get(ctx, ID); user.SessionVersion != 1 {
+		t.Errorf("SessionVersion after a change = %d, want 1", user.SessionVersion)
+	}
+	// Reset tokens sent before the change cannot undo it
+	if err := m.ResetPassword(ctx, resetToken, "An0ther-passw0rd!"); errCode(err) != CodeInvalidToken {
+		t.Errorf("ResetPassword() with a token sent before the change err: %v, want %s", err, CodeInvalidToken)
+	}
+	if err := m.ChangePassword(ctx, "missing", testPassword, "N3w-passw0rd!"); errType(err) != ErrTypeNotFound {
+		t.Errorf("ChangePassword() of a missing user err: %v, want %s", err, ErrTypeNotFound)
+	}
+}
@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// RequestPasswordReset - the implementation of the `RequestPasswordReset` method
+func (m *manager) RequestPasswordReset(ctx context.Context, email string) error {
+	email = normalizeEmail(email)
+	user, err := m.repo.GetUserByEmail(ctx, email)
+	if errors.Is(err, ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return newInternalError(err, "Error getting user by email %s", email)
+	}
+	if user.DeletedAt != nil || user.Status == UserStatusDisabled {
+		log.Printf("[user_v1] ignored the password reset request of %s user %s", user.Status, user.ID)
+		return nil
+	}
+
+	// The email is sent in the background, so that the response takes as long as for emails with no user, and
+	// failures are only logged for the same reason
+	m.mails.Add(1)
+	go func() {
+		defer m.mails.Done()
+		m.sendPasswordResetEmail(context.WithoutCancel(ctx), user)
+	}()
+	return nil
+}
+
+// sendPasswordResetEmail issues a password reset token for the user and mails it. Errors are logged.
+func (m *manager) sendPasswordResetEmail(ctx context.Context, user *User) {
+	token, err := m.issueToken(ctx, user.ID, TokenPurposePasswordReset, m.passwordResetTTL)
+	if err != nil {
+		log.Printf("[user_v1] error issuing the password reset token of user %s, err: %s", user.ID, err.Error())
+		return
+	}
+	err = m.mailer.Send(ctx, &Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nWe received a request to reset your password. Reset it with the link below. "+
+			"It expires in %s.\n\n%s\n\nIf you did not request it, you can ignore this email.",
+			user.FirstName, m.passwordResetTTL, tokenLink(m.passwordResetURL, token)),
+	})
+	if err != nil {
+		log.Printf("[user_v1] error sending the password reset email to user %s, err: %s", user.ID, err.Error())
+	}
+}
+
+// ResetPassword - the implementation of the `ResetPassword` method
+func (m *manager) ResetPassword(ctx context.Context, token, newPassword string) error {
+	c, err := m.verifyToken(token, TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	ctx = usvcTenant.WithID(ctx, c.TenantID)
+	if violations := m.validator.ValidatePassword(newPassword); len(violations) > 0 {
+		return newValidationError(violations)
+	}
+
+	user, err := m.Get(ctx, c.UserID)
+	if err != nil {
+		return err
+	}
+	if user.Status == UserStatusDisabled {
+		return newCodedError(ErrTypeForbidden, CodeUserNotActive, map[string]string{"status": string(user.Status)},
+			"The user is %s and cannot reset the password.", user.Status)
+	}
+
+	if err := m.consumeToken(ctx, c); err != nil {
+		return err
+	}
+	before := *user
+	if user.Status == UserStatusPending {
+		// The token was sent to the email of the user, which verifies the email as well
+		user.Status = UserStatusActive
+	}
+	return m.setPassword(ctx, AuditActionResetPassword, &before, user, newPassword)
+}
@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// newActiveUser creates an active user with the given email and returns its ID
+func newActiveUser(t *testing.T, ctx context.Context, m Manager, email string) string {
+	t.Helper()
+	ID := mustCreate(t, ctx, m, email)
+	if _, err := m.SetStatus(ctx, ID, UserStatusActive); err != nil {
+		t.Fatal(err)
+	}
+	return ID
+}
+
+// requestPasswordReset requests a password reset for the given email and waits until the email has been sent
+func requestPasswordReset(t *testing.T, ctx context.Context, m Manager, email string) {
+	t.Helper()
+	if err := m.RequestPasswordReset(ctx, email); err != nil {
+		t.Fatalf("RequestPasswordReset() err: %v", err)
+	}
+	m.(*manager).mails.Wait()
+}
+
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			m, mailer := newTestMailManager(t, repo)
+			ID := newActiveUser(t, ctx, m, "ann@example.com")
+
+			requestPasswordReset(t, ctx, m, " Ann@example.com")
+			stale := mailer.lastToken(t, "ann@example.com")
+			requestPasswordReset(t, ctx, m, "ann@example.com")
+			token := mailer.lastToken(t, "ann@example.com")
+
+			// Weak passwords are rejected without using up the token
+			if err := m.ResetPassword(ctx, token, "weak"); errType(err) != ErrTypeBadRequest {
+				t.Errorf("ResetPassword() with a weak password err: %v, want %s", err, ErrTypeBadRequest)
+			}
+			const newPassword = "N3w-passw0rd!"
+			if err := m.ResetPassword(ctx, token, newPassword); err != nil {
+				t.Fatalf("ResetPassword() err: %v", err)
+			}
+
+			if _, err := m.VerifyCredentials(ctx, "ann@example.com", newPassword); err != nil {
+				t.Errorf("VerifyCredentials() with the new password err: %v", err)
+			}
+			if _, err := m.VerifyCredentials(ctx, "ann@example.com", testPassword); errCode(err) != CodeInvalidCredentials {
+				t.Errorf("VerifyCredentials() with the old password err: %v, want %s", err, CodeInvalidCredentials)
+			}
+			if user, _ := m.Get(ctx, ID); user.SessionVersion != 1 {
+				t.Errorf("SessionVersion after a reset = %d, want 1 so that existing sessions end", user.SessionVersion)
+			}
+
+			// The token is used up, and the other tokens are revoked
+			for name, token := range map[string]string{"used": token, "stale": stale} {
+				if err := m.ResetPassword(ctx, token, "An0ther-passw0rd!"); errCode(err) != CodeInvalidToken {
+					t.Errorf("ResetPassword() with a %s token err: %v, want %s", name, err, CodeInvalidToken)
+				}
+			}
+		})
+	}
+}
+
+func TestResetPasswordOfPendingUser(t *testing.T) {
+	ctx := context.Background()
+	m, mailer := newTestMailManager(t, nil)
+	ID := mustCreate(t, ctx, m, "ann@example.com")
+
+	requestPasswordReset(t, ctx, m, "ann@example.com")
+	if err := m.ResetPassword(ctx, mailer.lastToken(t, "ann@example.com"), "N3w-passw0rd!"); err != nil {
+		t.Fatalf("ResetPassword() err: %v", err)
+	}
+	// The token was sent to the email, which verifies the email
+	if user, _ := m.Get(ctx, ID); user.Status != UserStatusActive {
+		t.Errorf("the status after a reset = %s, want %s", user.Status, UserStatusActive)
+	}
+}
+
+func TestRequestPasswordResetSendsNothing(t *testing.T) {
+	ctx := context.Background()
+	m, mailer := newTestMailManager(t, nil)
+	ID := newActiveUser(t, ctx, m, "ann@example.com")
+	if _, err := m.SetStatus(ctx, ID, UserStatusDisabled); err != nil {
+		t.Fatal(err)
+	}
+	sent := len(mailer.messages)
+
+	// Callers cannot find out which emails have been registered
+	for _, email := range []string{"ann@example.com", "bob@example.com"} {
+		if err := m.RequestPasswordReset(ctx, email); err != nil {
+			t.Errorf("RequestPasswordReset(%s) err: %v, want nil", email, err)
+		}
+	}
+	m.(*manager).mails.Wait()
+	if len(mailer.messages) != sent {
+		t.Errorf("RequestPasswordReset() sent %d emails to disabled or unknown users", len(mailer.messages)-sent)
+	}
+}
+
+// failingMailer - a Mailer which fails to send any message
+type failingMailer struct{}
+
+// Send - the implementation of the `Send` method
+func (failingMailer) Send(ctx context.Context, msg *Message) error {
+	return errors.New("connection refused")
+}
+
+func TestRequestPasswordResetIgnoresMailerFailures(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, nil, WithMailer(failingMailer{}))
+	newActiveUser(t, ctx, m, "ann@example.com")
+
+	// Failures are only logged, or callers could tell registered emails by the errors
+	requestPasswordReset(t, ctx, m, "ann@example.com")
+}
@@ -0,0 +1,163 @@
+package v1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPasswordConfigs returns a fast config of every supported algorithm
+func testPasswordConfigs() map[string]PasswordConfig {
+	bcryptCfg := DefaultPasswordConfig()
+	bcryptCfg.BcryptCost = bcrypt.MinCost
+
+	argon2Cfg := DefaultPasswordConfig()
+	argon2Cfg.Algorithm = PasswordAlgorithmArgon2id
+	argon2Cfg.Argon2Time = 1
+	argon2Cfg.Argon2Memory = 1024
+	return map[string]PasswordConfig{
+		PasswordAlgorithmBcrypt:   bcryptCfg,
+		PasswordAlgorithmArgon2id: argon2Cfg,
+	}
+}
+
+func TestPasswordHasher(t *testing.T) {
+	for name, cfg := range testPasswordConfigs() {
+		t.Run(name, func(t *testing.T) {
+			h, err := NewPasswordHasher(cfg)
+			if err != nil {
+				t.Fatalf("NewPasswordHasher() err: %v", err)
+			}
+			hash, err := h.Hash(testPassword)
+			if err != nil {
+				t.Fatalf("Hash() err: %v", err)
+			}
+			if strings.Contains(hash, testPassword) {
+				t.Fatalf("Hash() = %s, which contains the password", hash)
+			}
+			if other, _ := h.Hash(testPassword); other == hash {
+				t.Error("Hash() returned the same hash twice, want a random salt")
+			}
+
+			if ok, err := h.Verify(hash, testPassword); !ok || err != nil {
+				t.Errorf("Verify() of the password = %v, %v, want true", ok, err)
+			}
+			if ok, err := h.Verify(hash, testPassword+"x"); ok || err != nil {
+				t.Errorf("Verify() of another password = %v, %v, want false", ok, err)
+			}
+			if h.NeedsRehash(hash) {
+				t.Error("NeedsRehash() of a hash with the configured parameters = true")
+			}
+		})
+	}
+}
+
+func TestPasswordHasherMigration(t *testing.T) {
+	cfgs := testPasswordConfigs()
+	bcryptHasher, _ := NewPasswordHasher(cfgs[PasswordAlgorithmBcrypt])
+	argon2Hasher, _ := NewPasswordHasher(cfgs[PasswordAlgorithmArgon2id])
+
+	bcryptHash, _ := bcryptHasher.Hash(testPassword)
+	// Hashes of other algorithms are still verified so that users can sign in and get rehashed
+	if ok, err := argon2Hasher.Verify(bcryptHash, testPassword); !ok || err != nil {
+		t.Errorf("Verify() of a bcrypt hash with argon2id configured = %v, %v, want true", ok, err)
+	}
+	if !argon2Hasher.NeedsRehash(bcryptHash) {
+		t.Error("NeedsRehash() of a bcrypt hash with argon2id configured = false")
+	}
+
+	stronger := cfgs[PasswordAlgorithmBcrypt]
+	stronger.BcryptCost++
+	strongerHasher, _ := NewPasswordHasher(stronger)
+	if !strongerHasher.NeedsRehash(bcryptHash) {
+		t.Error("NeedsRehash() of a hash with a lower cost = false")
+	}
+}
+
+func TestNewPasswordHasherInvalidConfig(t *testing.T) {
+	tests := map[string]func(cfg *PasswordConfig){
+		"unknown algorithm": func(cfg *PasswordConfig) { cfg.Algorithm = "md5" },
+		"low bcrypt cost":   func(cfg *PasswordConfig) { cfg.BcryptCost = bcrypt.MinCost - 1 },
+		"zero argon2 time": func(cfg *PasswordConfig) {
+			cfg.Algorithm = PasswordAlgorithmArgon2id
+			cfg.Argon2Time = 0
+		},
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultPasswordConfig()
+			modify(&cfg)
+			if _, err := NewPasswordHasher(cfg); err == nil {
+				t.Error("NewPasswordHasher() err: nil, want an error")
+			}
+		})
+	}
+}
+
+func TestManagerVerifyCredentials(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, nil)
+	ID := mustCreate(t, ctx, m, "ann@example.com")
+	if _, err := m.SetStatus(ctx, ID, UserStatusActive); err != nil {
+		t.Fatal(err)
+	}
+	deletedID := mustCreate(t, ctx, m, "bob@example.com")
+	if err := m.Delete(ctx, deletedID, false); err != nil {
+		t.Fatal(err)
+	}
+	mustCreate(t, ctx, m, "cy@example.com")
+
+	user, err := m.VerifyCredentials(ctx, "ann@example.com", testPassword)
+	if err != nil || user.ID != ID {
+		t.Fatalf("VerifyCredentials() = %v, %v, want user %s", user, err, ID)
+	}
+
+	tests := []struct {
+		name, email, password string
+		wantType              ErrType
+		wantCode              string
+	}{
+		{"wrong password", "ann@example.com", "Wrong-passw0rd", ErrTypeUnauthorized, CodeInvalidCredentials},
+		// Unknown emails fail like wrong passwords so that callers cannot find out which emails have been registered
+		{"unknown email", "dan@example.com", testPassword, ErrTypeUnauthorized, CodeInvalidCredentials},
+		{"deleted user", "bob@example.com", testPassword, ErrTypeUnauthorized, CodeInvalidCredentials},
+		{"pending user", "cy@example.com", testPassword, ErrTypeForbidden, CodeUserNotActive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.VerifyCredentials(ctx, tt.email, tt.password)
+			if e, ok := ConvertError(err); !ok || e.Type() != tt.wantType || e.Code() != tt.wantCode {
+				t.Errorf("VerifyCredentials() err: %v, want %s/%s", err, tt.wantType, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestManagerVerifyCredentialsRehash(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	bcryptManager := newTestManager(t, repo)
+	ID := mustCreate(t, ctx, bcryptManager, "ann@example.com")
+	if _, err := bcryptManager.SetStatus(ctx, ID, UserStatusActive); err != nil {
+		t.Fatal(err)
+	}
+
+	// The hashes are migrated to the configured algorithm when users sign in
+	argon2Hasher, err := NewPasswordHasher(testPasswordConfigs()[PasswordAlgorithmArgon2id])
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(repo, WithPasswordHasher(argon2Hasher))
+	if _, err := m.VerifyCredentials(ctx, "ann@example.com", testPassword); err != nil {
+		t.Fatalf("VerifyCredentials() err: %v", err)
+	}
+	user, err := repo.GetUser(ctx, ID)
+	if err != nil || !strings.HasPrefix(user.PasswordHash, "$argon2id$") {
+		t.Fatalf("the hash after signing in = %+v, %v, want an argon2id hash", user, err)
+	}
+	if _, err := m.VerifyCredentials(ctx, "ann@example.com", testPassword); err != nil {
+		t.Errorf("VerifyCredentials() with the rehashed password err: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package v1
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+	outboxV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/outbox/v1"
+)
+
+// User represents a user stored in a repository
+type User struct {
+	ID string
+	// TenantID is the tenant the user belongs to. Repositories store users in the tenant of the context, see
+	// `tenant.WithID`; the email is unique within the tenant.
+	TenantID     string
+	FirstName    string
+	LastName     string
+	Email        string
+	PasswordHash string
+	// Status is the state of the user, see `UserStatus`
+	Status UserStatus
+	// SessionVersion is incremented whenever the password changes. Sessions issued for an older version are invalid.
+	SessionVersion int
+	// Version is incremented whenever the user is updated. It is exposed as the ETag of the user, so that updates
+	// based on a stale copy are rejected instead of overwriting changes made in the meantime.
+	Version   int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// DeletedAt is set when the user is soft deleted
+	DeletedAt *time.Time
+}
+
+// ListFilter defines the conditions used to filter users when listing them. Empty fields match any user.
+type ListFilter struct {
+	FirstName string
+	LastName  string
+	Email     string
+	Status    UserStatus
+	// IncludeDeleted includes soft deleted users in the result
+	IncludeDeleted bool
+}
+
+// IdempotencyRecord represents an idempotency key passed to `Create` and the result of the request
+type IdempotencyRecord struct {
+	Key string
+	// RequestHash is the fingerprint of the request input, used to detect a key reused with different input
+	RequestHash string
+	// UserID is the ID of the created user. It is empty while the request is in progress.
+	UserID    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// TokenRecord represents a single-use token issued to a user, e.g. an activation token. The token given to the user
+// carries the record ID along with its purpose, the user ID and the expiry, signed with the token secret.
+type TokenRecord struct {
+	ID        string
+	UserID    string
+	Purpose   string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// UsedAt is set when the token is consumed
+	UsedAt *time.Time
+}
+
+// Repository defines the interface for persisting users. It is injected into the Manager through `NewManager`
+// so that the same manager logic can run against a SQL database in production and an in-memory store in tests.
+// Implementations should stop and return the context error once the given context is done.
+//
+// Changes of users carry their records in the audit log and their events for the outbox, which are stored along with
+// the changes: either all or none of them are stored. A nil record means that the change is not audited.
+//
+// Every method except the outbox ones is scoped to the tenant in the context, see `tenant.FromContext`. Records of
+// other tenants are reported as ErrRecordNotFound, as if they did not exist, and emails and idempotency keys are
+// unique per tenant. The outbox is not scoped, as the relay publishes the events of every tenant.
+type Repository interface {
+	// CreateUser stores the given user. It returns ErrDuplicateRecord if the ID or the email has been used.
+	CreateUser(ctx context.Context, user *User, record *auditV1.Record, events ...*outboxV1.Event) error
+	// CreateUsers stores the given users in one transaction, e.g. a batch of imported users, along with the records
+	// and the events of them. If any ID or email has been used, it returns ErrDuplicateRecord and none of the users
+	// is stored.
+	CreateUsers(ctx context.Context, users []*User, records []*auditV1.Record, events ...*outboxV1.Event) error
+	// GetUser returns the user with the given ID, including soft deleted users. It returns ErrRecordNotFound if no user matches.
+	GetUser(ctx context.Context, ID string) (*User, error)
+	// GetUserByEmail returns the user with the given email, including soft deleted users. It returns ErrRecordNotFound if no user matches.
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	// ListUsers returns at most `limit` users matching the filter whose IDs are greater than `afterID`, ordered by ID.
+	ListUsers(ctx context.Context, filter *ListFilter, afterID string, limit int) ([]*User, error)
+	// UpdateUser replaces the stored user with the given one. Callers increment the version of the user they read,
+	// and the user is only replaced if the stored version is still the one before, otherwise ErrVersionMismatch is
+	// returned. It returns ErrRecordNotFound if the user does not exist and ErrDuplicateRecord if the new email
+	// has been used.
+	UpdateUser(ctx context.Context, user *User, record *auditV1.Record, events ...*outboxV1.Event) error
+	// UpdatePasswordHash replaces the password hash of the user with the given ID and version, leaving the version
+	// and the other fields as they are. It is meant for rehashing the same password, which is not a change of the
+	// user. It returns ErrRecordNotFound if no user has the ID and the version, e.g. as the user changed since.
+	UpdatePasswordHash(ctx context.Context, ID string, version int, hash string) error
+	// DeleteUser permanently removes the user with the given ID. It returns ErrRecordNotFound if the user does not exist.
+	DeleteUser(ctx context.Context, ID string, record *auditV1.Record, events ...*outboxV1.Event) error
+
+	// CreateIdempotencyRecord stores the given record. It returns ErrDuplicateRecord if an unexpired record with the
+	// same key exists; an expired one is replaced.
+	CreateIdempotencyRecord(ctx context.Context, record *IdempotencyRecord) error
+	// GetIdempotencyRecord returns the unexpired record with the given key. It returns ErrRecordNotFound if no record matches.
+	GetIdempotencyRecord(ctx context.Context, key string) (*IdempotencyRecord, error)
+	// UpdateIdempotencyRecord replaces the stored record with the given one. It returns ErrRecordNotFound if the record does not exist.
+	UpdateIdempotencyRecord(ctx context.Context, record *IdempotencyRecord) error
+	// DeleteIdempotencyRecord removes the record with the given key. Removing a missing record is not an error.
+	DeleteIdempotencyRecord(ctx context.Context, key string) error
+
+	// CreateToken stores the given token record. It returns ErrDuplicateRecord if the ID has been used.
+	CreateToken(ctx context.Context, token *TokenRecord) error
+	// ConsumeToken marks the token with the given ID as used. It returns ErrRecordNotFound if the token does not exist
+	// or has been used, so that each token can be consumed once even by concurrent requests.
+	ConsumeToken(ctx context.Context, ID string, usedAt time.Time) error
+	// RevokeTokens marks all unused tokens of the user with the given purpose as used
+	RevokeTokens(ctx context.Context, userID, purpose string, revokedAt time.Time) error
+
+	// Store reads the outbox for the relay which publishes the events of users
+	outboxV1.Store
+}
+
+// Errors returned by Repository implementations
+var (
+	// ErrRecordNotFound - the requested record does not exist
+	ErrRecordNotFound = errors.New("record not found")
+	// ErrDuplicateRecord - the record violates a unique constraint
+	ErrDuplicateRecord = errors.New("duplicate record")
+	// ErrVersionMismatch - the record has been changed since it was read
+	ErrVersionMismatch = errors.New("version mismatch")
+)
+
+// newID generates a random user ID
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
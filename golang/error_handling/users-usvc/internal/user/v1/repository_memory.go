@@ -0,0 +1,483 @@
+package v1
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+	outboxV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/outbox/v1"
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// tenantKey - a key which is unique within a tenant, e.g. an email
+type tenantKey struct {
+	tenant string
+	key    string
+}
+
+// memoryRepository is the implementation of Repository interface which keeps users in memory. It is meant for tests.
+type memoryRepository struct {
+	mu sync.RWMutex
+	// users - ID -> user. IDs are unique across tenants, so the tenant of a user is checked on lookup.
+	users   map[string]*User
+	byEmail map[tenantKey]string // tenant, email -> ID
+	// idempotencyRecords - tenant, key -> record
+	idempotencyRecords map[tenantKey]*IdempotencyRecord
+	tokens             map[tenantKey]*TokenRecord // tenant, ID -> token
+	// auditLog stores the records of changes. Changes are not recorded if it is nil.
+	auditLog auditV1.Store
+	// events - the outbox, ordered by ID
+	events []*outboxV1.Event
+}
+
+// MemoryOption configures optional dependencies of the memory repository
+type MemoryOption func(r *memoryRepository)
+
+// WithMemoryAuditLog sets the store which records the changes of users, e.g. a file store in local development.
+// Records are appended before the changes are applied, so a change is not applied if its record cannot be stored.
+func WithMemoryAuditLog(store auditV1.Store) MemoryOption {
+	return func(r *memoryRepository) {
+		r.auditLog = store
+	}
+}
+
+// NewMemoryRepository creates an instance of Repository which keeps users in memory
+func NewMemoryRepository(opts ...MemoryOption) Repository {
+	r := &memoryRepository{
+		users:              map[string]*User{},
+		byEmail:            map[tenantKey]string{},
+		idempotencyRecords: map[tenantKey]*IdempotencyRecord{},
+		tokens:             map[tenantKey]*TokenRecord{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// CreateUser - the implementation of the `CreateUser` method
+func (r *memoryRepository) CreateUser(ctx context.Context, user *User, record *auditV1.Record, events ...*outboxV1.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant := usvcTenant.FromContext(ctx)
+	if _, ok := r.users[user.ID]; ok {
+		return ErrDuplicateRecord
+	}
+	if _, ok := r.byEmail[tenantKey{tenant, user.Email}]; ok {
+		return ErrDuplicateRecord
+	}
+	if err := r.appendRecord(ctx, record); err != nil {
+		return err
+	}
+
+	r.storeUser(tenant, user)
+	r.appendEvents(events)
+	return nil
+}
+
+// CreateUsers - the implementation of the `CreateUsers` method
+func (r *memoryRepository) CreateUsers(ctx context.Context, users []*User, records []*auditV1.Record, events ...*outboxV1.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Check all the users first so that none is stored if one is a duplicate
+	tenant := usvcTenant.FromContext(ctx)
+	IDs, emails := map[string]bool{}, map[string]bool{}
+	for _, user := range users {
+		if _, ok := r.users[user.ID]; ok || IDs[user.ID] {
+			return ErrDuplicateRecord
+		}
+		if _, ok := r.byEmail[tenantKey{tenant, user.Email}]; ok || emails[user.Email] {
+			return ErrDuplicateRecord
+		}
+		IDs[user.ID], emails[user.Email] = true, true
+	}
+	for _, record := range records {
+		if err := r.appendRecord(ctx, record); err != nil {
+			return err
+		}
+	}
+
+	for _, user := range users {
+		r.storeUser(tenant, user)
+	}
+	r.appendEvents(events)
+	return nil
+}
+
+// GetUser - the implementation of the `GetUser` method
+func (r *memoryRepository) GetUser(ctx context.Context, ID string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.user(usvcTenant.FromContext(ctx), ID)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return copyUser(u), nil
+}
+
+// GetUserByEmail - the implementation of the `GetUserByEmail` method
+func (r *memoryRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ID, ok := r.byEmail[tenantKey{usvcTenant.FromContext(ctx), email}]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return copyUser(r.users[ID]), nil
+}
+
+// ListUsers - the implementation of the `ListUsers` method
+func (r *memoryRepository) ListUsers(ctx context.Context, filter *ListFilter, afterID string, limit int) ([]*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenant := usvcTenant.FromContext(ctx)
+	IDs := make([]string, 0, len(r.users))
+	for ID, u := range r.users {
+		if u.TenantID == tenant && ID > afterID {
+			IDs = append(IDs, ID)
+		}
+	}
+	sort.Strings(IDs)
+
+	users := []*User{}
+	for _, ID := range IDs {
+		if len(users) >= limit {
+			break
+		}
+		if u := r.users[ID]; matchFilter(u, filter) {
+			users = append(users, copyUser(u))
+		}
+	}
+	return users, nil
+}
+
+// UpdateUser - the implementation of the `UpdateUser` method
+func (r *memoryRepository) UpdateUser(ctx context.Context, user *User, record *auditV1.Record, events ...*outboxV1.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant := usvcTenant.FromContext(ctx)
+	old, ok := r.user(tenant, user.ID)
+	if !ok {
+		return ErrRecordNotFound
+	}
+	if old.Version != user.Version-1 {
+		return ErrVersionMismatch
+	}
+	if ID, ok := r.byEmail[tenantKey{tenant, user.Email}]; ok && ID != user.ID {
+		return ErrDuplicateRecord
+	}
+	if err := r.appendRecord(ctx, record); err != nil {
+		return err
+	}
+
+	delete(r.byEmail, tenantKey{tenant, old.Email})
+	r.storeUser(tenant, user)
+	r.appendEvents(events)
+	return nil
+}
+
+// UpdatePasswordHash - the implementation of the `UpdatePasswordHash` method
+func (r *memoryRepository) UpdatePasswordHash(ctx context.Context, ID string, version int, hash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.user(usvcTenant.FromContext(ctx), ID)
+	if !ok || u.Version != version {
+		return ErrRecordNotFound
+	}
+	u.PasswordHash = hash
+	return nil
+}
+
+// DeleteUser - the implementation of the `DeleteUser` method
+func (r *memoryRepository) DeleteUser(ctx context.Context, ID string, record *auditV1.Record, events ...*outboxV1.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant := usvcTenant.FromContext(ctx)
+	u, ok := r.user(tenant, ID)
+	if !ok {
+		return ErrRecordNotFound
+	}
+	if err := r.appendRecord(ctx, record); err != nil {
+		return err
+	}
+	delete(r.byEmail, tenantKey{tenant, u.Email})
+	delete(r.users, ID)
+	r.appendEvents(events)
+	return nil
+}
+
+// appendRecord appends the record of a change to the audit log. It is called with the lock held, after the change
+// has been checked and before it is applied.
+func (r *memoryRepository) appendRecord(ctx context.Context, record *auditV1.Record) error {
+	if r.auditLog == nil || record == nil {
+		return nil
+	}
+	return r.auditLog.Append(ctx, record)
+}
+
+// CreateIdempotencyRecord - the implementation of the `CreateIdempotencyRecord` method
+func (r *memoryRepository) CreateIdempotencyRecord(ctx context.Context, record *IdempotencyRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := tenantKey{usvcTenant.FromContext(ctx), record.Key}
+	if old, ok := r.idempotencyRecords[key]; ok && old.ExpiresAt.After(time.Now()) {
+		return ErrDuplicateRecord
+	}
+	rec := *record
+	r.idempotencyRecords[key] = &rec
+	return nil
+}
+
+// GetIdempotencyRecord - the implementation of the `GetIdempotencyRecord` method
+func (r *memoryRepository) GetIdempotencyRecord(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, ok := r.idempotencyRecords[tenantKey{usvcTenant.FromContext(ctx), key}]
+	if !ok || !record.ExpiresAt.After(time.Now()) {
+		return nil, ErrRecordNotFound
+	}
+	rec := *record
+	return &rec, nil
+}
+
+// UpdateIdempotencyRecord - the implementation of the `UpdateIdempotencyRecord` method
+func (r *memoryRepository) UpdateIdempotencyRecord(ctx context.Context, record *IdempotencyRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := tenantKey{usvcTenant.FromContext(ctx), record.Key}
+	if _, ok := r.idempotencyRecords[key]; !ok {
+		return ErrRecordNotFound
+	}
+	rec := *record
+	r.idempotencyRecords[key] = &rec
+	return nil
+}
+
+// DeleteIdempotencyRecord - the implementation of the `DeleteIdempotencyRecord` method
+func (r *memoryRepository) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.idempotencyRecords, tenantKey{usvcTenant.FromContext(ctx), key})
+	return nil
+}
+
+// CreateToken - the implementation of the `CreateToken` method
+func (r *memoryRepository) CreateToken(ctx context.Context, token *TokenRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Token IDs are unique across tenants, like the primary key of the SQL table
+	for key := range r.tokens {
+		if key.key == token.ID {
+			return ErrDuplicateRecord
+		}
+	}
+	t := *token
+	r.tokens[tenantKey{usvcTenant.FromContext(ctx), token.ID}] = &t
+	return nil
+}
+
+// ConsumeToken - the implementation of the `ConsumeToken` method
+func (r *memoryRepository) ConsumeToken(ctx context.Context, ID string, usedAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[tenantKey{usvcTenant.FromContext(ctx), ID}]
+	if !ok || token.UsedAt != nil {
+		return ErrRecordNotFound
+	}
+	token.UsedAt = &usedAt
+	return nil
+}
+
+// RevokeTokens - the implementation of the `RevokeTokens` method
+func (r *memoryRepository) RevokeTokens(ctx context.Context, userID, purpose string, revokedAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant := usvcTenant.FromContext(ctx)
+	for key, token := range r.tokens {
+		if key.tenant == tenant && token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			t := revokedAt
+			token.UsedAt = &t
+		}
+	}
+	return nil
+}
+
+// ListPendingEvents - the implementation of the `ListPendingEvents` method
+func (r *memoryRepository) ListPendingEvents(ctx context.Context, limit int) ([]*outboxV1.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	events := []*outboxV1.Event{}
+	for _, e := range r.events {
+		if len(events) >= limit {
+			break
+		}
+		if e.PublishedAt == nil {
+			c := *e
+			events = append(events, &c)
+		}
+	}
+	return events, nil
+}
+
+// MarkEventsPublished - the implementation of the `MarkEventsPublished` method
+func (r *memoryRepository) MarkEventsPublished(ctx context.Context, IDs []string, publishedAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	published := make(map[string]bool, len(IDs))
+	for _, ID := range IDs {
+		published[ID] = true
+	}
+	for _, e := range r.events {
+		if published[e.ID] && e.PublishedAt == nil {
+			t := publishedAt
+			e.PublishedAt = &t
+		}
+	}
+	return nil
+}
+
+// DeletePublishedEvents - the implementation of the `DeletePublishedEvents` method
+func (r *memoryRepository) DeletePublishedEvents(ctx context.Context, before time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.events[:0]
+	for _, e := range r.events {
+		if e.PublishedAt == nil || !e.PublishedAt.Before(before) {
+			kept = append(kept, e)
+		}
+	}
+	r.events = kept
+	return nil
+}
+
+// user returns the stored user with the ID if it belongs to the tenant. The caller must hold the lock.
+func (r *memoryRepository) user(tenant, ID string) (*User, bool) {
+	u, ok := r.users[ID]
+	if !ok || u.TenantID != tenant {
+		return nil, false
+	}
+	return u, true
+}
+
+// storeUser stores a copy of the user in the tenant. The caller must hold the write lock.
+func (r *memoryRepository) storeUser(tenant string, user *User) {
+	u := copyUser(user)
+	u.TenantID = tenant
+	r.users[user.ID] = u
+	r.byEmail[tenantKey{tenant, user.Email}] = user.ID
+}
+
+// appendEvents appends copies of the events to the outbox. The caller must hold the write lock.
+func (r *memoryRepository) appendEvents(events []*outboxV1.Event) {
+	for _, e := range events {
+		c := *e
+		r.events = append(r.events, &c)
+	}
+}
+
+// matchFilter checks whether the user matches the given filter
+func matchFilter(u *User, filter *ListFilter) bool {
+	if filter == nil {
+		return u.DeletedAt == nil
+	}
+	if !filter.IncludeDeleted && u.DeletedAt != nil {
+		return false
+	}
+	if filter.FirstName != "" && u.FirstName != filter.FirstName {
+		return false
+	}
+	if filter.LastName != "" && u.LastName != filter.LastName {
+		return false
+	}
+	if filter.Email != "" && u.Email != filter.Email {
+		return false
+	}
+	if filter.Status != "" && u.Status != filter.Status {
+		return false
+	}
+	return true
+}
+
+// copyUser returns a deep copy of the given user so that callers cannot modify the stored one
+func copyUser(u *User) *User {
+	c := *u
+	if u.DeletedAt != nil {
+		t := *u.DeletedAt
+		c.DeletedAt = &t
+	}
+	return &c
+}
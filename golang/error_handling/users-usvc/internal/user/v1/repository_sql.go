@@ -0,0 +1,495 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	auditV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/audit/v1"
+	outboxV1 "github.com/azhuox/blogs/golang/error_handling/users-usvc/internal/outbox/v1"
+	usvcSQLSchema "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/sqlschema"
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// usersTable - the definition of the users table
+const usersTable = `(
+		id         VARCHAR(64)  NOT NULL PRIMARY KEY,
+		tenant_id  VARCHAR(64)  NOT NULL DEFAULT 'default',
+		first_name VARCHAR(255) NOT NULL,
+		last_name  VARCHAR(255) NOT NULL,
+		email      VARCHAR(255) NOT NULL,
+		password_hash VARCHAR(255) NOT NULL,
+		status     VARCHAR(16)  NOT NULL DEFAULT 'active',
+		session_version INT     NOT NULL DEFAULT 0,
+		version    INT          NOT NULL DEFAULT 1,
+		created_at DATETIME     NOT NULL,
+		updated_at DATETIME     NOT NULL,
+		deleted_at DATETIME     NULL,
+		UNIQUE (tenant_id, email)
+	)`
+
+// idempotencyKeysTable - the definition of the idempotency_keys table
+const idempotencyKeysTable = `(
+		tenant_id       VARCHAR(64)  NOT NULL DEFAULT 'default',
+		idempotency_key VARCHAR(255) NOT NULL,
+		request_hash    VARCHAR(64)  NOT NULL,
+		user_id         VARCHAR(64)  NOT NULL,
+		created_at      DATETIME     NOT NULL,
+		expires_at      DATETIME     NOT NULL,
+		PRIMARY KEY (tenant_id, idempotency_key)
+	)`
+
+// sqlSchema - statements for creating the tables used by the SQL repository.
+// They only use the SQL subset shared by SQLite and MySQL.
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS users ` + usersTable,
+	`CREATE TABLE IF NOT EXISTS idempotency_keys ` + idempotencyKeysTable,
+	`CREATE TABLE IF NOT EXISTS user_tokens (
+		id         VARCHAR(64) NOT NULL PRIMARY KEY,
+		tenant_id  VARCHAR(64) NOT NULL DEFAULT 'default',
+		user_id    VARCHAR(64) NOT NULL,
+		purpose    VARCHAR(32) NOT NULL,
+		created_at DATETIME    NOT NULL,
+		expires_at DATETIME    NOT NULL,
+		used_at    DATETIME    NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS outbox_events (
+		id           VARCHAR(64) NOT NULL PRIMARY KEY,
+		type         VARCHAR(64) NOT NULL,
+		aggregate_id VARCHAR(64) NOT NULL,
+		payload      TEXT        NOT NULL,
+		created_at   DATETIME    NOT NULL,
+		published_at DATETIME    NULL
+	)`,
+}
+
+// userColumns - columns selected by queries, in the order expected by `scanUser`
+const userColumns = `id, tenant_id, first_name, last_name, email, password_hash, status, session_version, version, created_at, updated_at, deleted_at`
+
+// MigrateSQLSchema creates the tables used by the SQL repository if they do not exist, including the table of the
+// audit log which changes are recorded in, and migrates the tables created before users were scoped to tenants.
+// Their rows are moved to the default tenant.
+func MigrateSQLSchema(ctx context.Context, db *sql.DB) error {
+	for _, stmt := range sqlSchema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("error migrating the user schema, err: %s", err.Error())
+		}
+	}
+	if err := migrateTenantSQLSchema(ctx, db); err != nil {
+		return fmt.Errorf("error migrating the user schema, err: %s", err.Error())
+	}
+	return auditV1.MigrateSQLSchema(ctx, db)
+}
+
+// migrateTenantSQLSchema adds the tenant to the tables created before users were scoped to tenants. The tables whose
+// unique constraints include the tenant are rebuilt, as SQLite cannot alter constraints.
+func migrateTenantSQLSchema(ctx context.Context, db *sql.DB) error {
+	rebuilds := []struct {
+		table, definition, columns string
+	}{
+		{"users", usersTable, `id, first_name, last_name, email, password_hash, status, session_version, version, created_at, updated_at, deleted_at`},
+		{"idempotency_keys", idempotencyKeysTable, `idempotency_key, request_hash, user_id, created_at, expires_at`},
+	}
+	for _, rebuild := range rebuilds {
+		ok, err := usvcSQLSchema.HasColumn(ctx, db, rebuild.table, "tenant_id")
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		if err := usvcSQLSchema.RebuildTable(ctx, db, rebuild.table, rebuild.definition, rebuild.columns); err != nil {
+			return err
+		}
+	}
+	return usvcSQLSchema.AddColumn(ctx, db, "user_tokens", "tenant_id", `VARCHAR(64) NOT NULL DEFAULT 'default'`)
+}
+
+// sqlRepository is the implementation of Repository interface backed by `database/sql`.
+// It works with both SQLite (local development) and MySQL (production); MySQL DSNs need `parseTime=true`.
+// Changes are recorded in the audit log of the same database, which `auditV1.NewSQLStore` queries.
+// Every query on users, idempotency keys and tokens is conditioned on the tenant in the context.
+type sqlRepository struct {
+	db *sql.DB
+}
+
+// NewSQLRepository creates an instance of Repository which stores users in the given database
+func NewSQLRepository(db *sql.DB) Repository {
+	return &sqlRepository{
+		db: db,
+	}
+}
+
+// CreateUser - the implementation of the `CreateUser` method
+func (r *sqlRepository) CreateUser(ctx context.Context, user *User, record *auditV1.Record, events ...*outboxV1.Event) error {
+	return r.withChanges(ctx, []*auditV1.Record{record}, events, func(tx *sql.Tx) error {
+		return insertUser(ctx, tx, usvcTenant.FromContext(ctx), user)
+	})
+}
+
+// CreateUsers - the implementation of the `CreateUsers` method
+func (r *sqlRepository) CreateUsers(ctx context.Context, users []*User, records []*auditV1.Record, events ...*outboxV1.Event) error {
+	tenantID := usvcTenant.FromContext(ctx)
+	return r.withChanges(ctx, records, events, func(tx *sql.Tx) error {
+		for _, user := range users {
+			if err := insertUser(ctx, tx, tenantID, user); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// insertUser inserts the user into the tenant in the transaction
+func insertUser(ctx context.Context, tx *sql.Tx, tenantID string, user *User) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		user.ID, tenantID, user.FirstName, user.LastName, user.Email, user.PasswordHash, user.Status, user.SessionVersion, user.Version, user.CreatedAt, user.UpdatedAt, nullTime(user.DeletedAt),
+	)
+	if err != nil {
+		if isDuplicateKeyErr(err) {
+			// Keep the driver error so that callers can still inspect it
+			return fmt.Errorf("%w: %w", ErrDuplicateRecord, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// GetUser - the implementation of the `GetUser` method
+func (r *sqlRepository) GetUser(ctx context.Context, ID string) (*User, error) {
+	return r.getUser(ctx, `SELECT `+userColumns+` FROM users WHERE tenant_id = ? AND id = ?`, usvcTenant.FromContext(ctx), ID)
+}
+
+// GetUserByEmail - the implementation of the `GetUserByEmail` method
+func (r *sqlRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	return r.getUser(ctx, `SELECT `+userColumns+` FROM users WHERE tenant_id = ? AND email = ?`, usvcTenant.FromContext(ctx), email)
+}
+
+// ListUsers - the implementation of the `ListUsers` method
+func (r *sqlRepository) ListUsers(ctx context.Context, filter *ListFilter, afterID string, limit int) ([]*User, error) {
+	if filter == nil {
+		filter = &ListFilter{}
+	}
+
+	conds := []string{`tenant_id = ?`, `id > ?`}
+	args := []interface{}{usvcTenant.FromContext(ctx), afterID}
+	if !filter.IncludeDeleted {
+		conds = append(conds, `deleted_at IS NULL`)
+	}
+	if filter.FirstName != "" {
+		conds = append(conds, `first_name = ?`)
+		args = append(args, filter.FirstName)
+	}
+	if filter.LastName != "" {
+		conds = append(conds, `last_name = ?`)
+		args = append(args, filter.LastName)
+	}
+	if filter.Email != "" {
+		conds = append(conds, `email = ?`)
+		args = append(args, filter.Email)
+	}
+	if filter.Status != "" {
+		conds = append(conds, `status = ?`)
+		args = append(args, filter.Status)
+	}
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+userColumns+` FROM users WHERE `+strings.Join(conds, ` AND `)+` ORDER BY id LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// UpdateUser - the implementation of the `UpdateUser` method
+func (r *sqlRepository) UpdateUser(ctx context.Context, user *User, record *auditV1.Record, events ...*outboxV1.Event) error {
+	tenantID := usvcTenant.FromContext(ctx)
+	return r.withChanges(ctx, []*auditV1.Record{record}, events, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE users SET first_name = ?, last_name = ?, email = ?, password_hash = ?, status = ?, session_version = ?, version = ?, created_at = ?, updated_at = ?, deleted_at = ? WHERE tenant_id = ? AND id = ? AND version = ?`,
+			user.FirstName, user.LastName, user.Email, user.PasswordHash, user.Status, user.SessionVersion, user.Version, user.CreatedAt, user.UpdatedAt, nullTime(user.DeletedAt), tenantID, user.ID, user.Version-1,
+		)
+		if err != nil {
+			if isDuplicateKeyErr(err) {
+				// Keep the driver error so that callers can still inspect it
+				return fmt.Errorf("%w: %w", ErrDuplicateRecord, err)
+			}
+			return err
+		}
+		if err := checkAffected(res); !errors.Is(err, ErrRecordNotFound) {
+			return err
+		}
+		// Nothing is updated either because the user does not exist or because its version has changed
+		var exists int
+		err = tx.QueryRowContext(ctx, `SELECT 1 FROM users WHERE tenant_id = ? AND id = ?`, tenantID, user.ID).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return ErrVersionMismatch
+	})
+}
+
+// UpdatePasswordHash - the implementation of the `UpdatePasswordHash` method
+func (r *sqlRepository) UpdatePasswordHash(ctx context.Context, ID string, version int, hash string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE users SET password_hash = ? WHERE tenant_id = ? AND id = ? AND version = ?`, hash, usvcTenant.FromContext(ctx), ID, version)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// DeleteUser - the implementation of the `DeleteUser` method
+func (r *sqlRepository) DeleteUser(ctx context.Context, ID string, record *auditV1.Record, events ...*outboxV1.Event) error {
+	return r.withChanges(ctx, []*auditV1.Record{record}, events, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE tenant_id = ? AND id = ?`, usvcTenant.FromContext(ctx), ID)
+		if err != nil {
+			return err
+		}
+		return checkAffected(res)
+	})
+}
+
+// withChanges runs `write`, appends the records to the audit log and writes the events to the outbox in one
+// transaction, so either all or none of them are stored. Nil records are skipped.
+func (r *sqlRepository) withChanges(ctx context.Context, records []*auditV1.Record, events []*outboxV1.Event, write func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := write(tx); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record == nil {
+			continue
+		}
+		if err := auditV1.AppendTx(ctx, tx, record); err != nil {
+			return err
+		}
+	}
+	for _, e := range events {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO outbox_events (id, type, aggregate_id, payload, created_at, published_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			e.ID, e.Type, e.AggregateID, string(e.Payload), e.CreatedAt, nullTime(e.PublishedAt),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListPendingEvents - the implementation of the `ListPendingEvents` method
+func (r *sqlRepository) ListPendingEvents(ctx context.Context, limit int) ([]*outboxV1.Event, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, type, aggregate_id, payload, created_at FROM outbox_events WHERE published_at IS NULL ORDER BY id LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*outboxV1.Event{}
+	for rows.Next() {
+		e := &outboxV1.Event{}
+		var payload string
+		if err := rows.Scan(&e.ID, &e.Type, &e.AggregateID, &payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Payload = []byte(payload)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// MarkEventsPublished - the implementation of the `MarkEventsPublished` method
+func (r *sqlRepository) MarkEventsPublished(ctx context.Context, IDs []string, publishedAt time.Time) error {
+	if len(IDs) == 0 {
+		return nil
+	}
+	args := []interface{}{publishedAt}
+	for _, ID := range IDs {
+		args = append(args, ID)
+	}
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE outbox_events SET published_at = ? WHERE published_at IS NULL AND id IN (?`+strings.Repeat(`, ?`, len(IDs)-1)+`)`,
+		args...,
+	)
+	return err
+}
+
+// DeletePublishedEvents - the implementation of the `DeletePublishedEvents` method
+func (r *sqlRepository) DeletePublishedEvents(ctx context.Context, before time.Time) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM outbox_events WHERE published_at IS NOT NULL AND published_at < ?`, before)
+	return err
+}
+
+// CreateIdempotencyRecord - the implementation of the `CreateIdempotencyRecord` method
+func (r *sqlRepository) CreateIdempotencyRecord(ctx context.Context, record *IdempotencyRecord) error {
+	// Release the key if it has expired, then rely on the primary key to reject live duplicates
+	tenantID := usvcTenant.FromContext(ctx)
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE tenant_id = ? AND idempotency_key = ? AND expires_at <= ?`, tenantID, record.Key, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (tenant_id, idempotency_key, request_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		tenantID, record.Key, record.RequestHash, record.UserID, record.CreatedAt, record.ExpiresAt,
+	)
+	if err != nil {
+		if isDuplicateKeyErr(err) {
+			return fmt.Errorf("%w: %w", ErrDuplicateRecord, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// GetIdempotencyRecord - the implementation of the `GetIdempotencyRecord` method
+func (r *sqlRepository) GetIdempotencyRecord(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	record := &IdempotencyRecord{}
+	err := r.db.QueryRowContext(ctx,
+		`SELECT idempotency_key, request_hash, user_id, created_at, expires_at FROM idempotency_keys WHERE tenant_id = ? AND idempotency_key = ? AND expires_at > ?`,
+		usvcTenant.FromContext(ctx), key, time.Now().UTC(),
+	).Scan(&record.Key, &record.RequestHash, &record.UserID, &record.CreatedAt, &record.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// UpdateIdempotencyRecord - the implementation of the `UpdateIdempotencyRecord` method
+func (r *sqlRepository) UpdateIdempotencyRecord(ctx context.Context, record *IdempotencyRecord) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE idempotency_keys SET request_hash = ?, user_id = ?, created_at = ?, expires_at = ? WHERE tenant_id = ? AND idempotency_key = ?`,
+		record.RequestHash, record.UserID, record.CreatedAt, record.ExpiresAt, usvcTenant.FromContext(ctx), record.Key,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// DeleteIdempotencyRecord - the implementation of the `DeleteIdempotencyRecord` method
+func (r *sqlRepository) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE tenant_id = ? AND idempotency_key = ?`, usvcTenant.FromContext(ctx), key)
+	return err
+}
+
+// CreateToken - the implementation of the `CreateToken` method
+func (r *sqlRepository) CreateToken(ctx context.Context, token *TokenRecord) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO user_tokens (id, tenant_id, user_id, purpose, created_at, expires_at, used_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		token.ID, usvcTenant.FromContext(ctx), token.UserID, token.Purpose, token.CreatedAt, token.ExpiresAt, nullTime(token.UsedAt),
+	)
+	if err != nil {
+		if isDuplicateKeyErr(err) {
+			return fmt.Errorf("%w: %w", ErrDuplicateRecord, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// ConsumeToken - the implementation of the `ConsumeToken` method
+func (r *sqlRepository) ConsumeToken(ctx context.Context, ID string, usedAt time.Time) error {
+	// The condition on used_at makes concurrent consumers race on a single row update, so only one of them succeeds
+	res, err := r.db.ExecContext(ctx, `UPDATE user_tokens SET used_at = ? WHERE tenant_id = ? AND id = ? AND used_at IS NULL`, usedAt, usvcTenant.FromContext(ctx), ID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// RevokeTokens - the implementation of the `RevokeTokens` method
+func (r *sqlRepository) RevokeTokens(ctx context.Context, userID, purpose string, revokedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE user_tokens SET used_at = ? WHERE tenant_id = ? AND user_id = ? AND purpose = ? AND used_at IS NULL`,
+		revokedAt, usvcTenant.FromContext(ctx), userID, purpose,
+	)
+	return err
+}
+
+// getUser runs a query which selects a single user
+func (r *sqlRepository) getUser(ctx context.Context, query string, args ...interface{}) (*User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// scanner is implemented by both `*sql.Row` and `*sql.Rows`
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser reads a user from a row selected with `userColumns`
+func scanUser(s scanner) (*User, error) {
+	user := &User{}
+	var deletedAt sql.NullTime
+	err := s.Scan(&user.ID, &user.TenantID, &user.FirstName, &user.LastName, &user.Email, &user.PasswordHash, &user.Status, &user.SessionVersion, &user.Version, &user.CreatedAt, &user.UpdatedAt, &deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	return user, nil
+}
+
+// nullTime converts an optional time to a value that can be stored in a nullable column
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+// checkAffected returns ErrRecordNotFound if a statement did not affect any row
+func checkAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// isDuplicateKeyErr checks whether the given error is a unique constraint violation reported by SQLite or MySQL
+func isDuplicateKeyErr(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed") || // SQLite
+		strings.Contains(msg, "Error 1062") // MySQL: ER_DUP_ENTRY
+}
@@ -0,0 +1,165 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// testRepositories returns every Repository implementation, each backed by an empty store
+func testRepositories(t *testing.T) map[string]Repository {
+	t.Helper()
+	return map[string]Repository{
+		"memory": NewMemoryRepository(),
+		"sql":    NewSQLRepository(newTestDB(t)),
+	}
+}
+
+// newTestDB opens an empty SQLite database in memory with the schema of the SQL repository
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening the database, err: %s", err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+	// Every connection to `:memory:` opens a database of its own
+	db.SetMaxOpenConns(1)
+	if err := MigrateSQLSchema(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newTestUser returns a user with the given ID and email
+func newTestUser(ID, email string) *User {
+	now := time.Now().UTC().Truncate(time.Second)
+	return &User{
+		ID:           ID,
+		FirstName:    "Ann",
+		LastName:     "Lee",
+		Email:        email,
+		PasswordHash: "hash",
+		Version:      1,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+func TestRepositoryCreateAndGetUser(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			user := newTestUser("u1", "ann@example.com")
+			if err := repo.CreateUser(ctx, user, nil); err != nil {
+				t.Fatalf("CreateUser() err: %v", err)
+			}
+
+			got, err := repo.GetUser(ctx, "u1")
+			if err != nil {
+				t.Fatalf("GetUser() err: %v", err)
+			}
+			if got.Email != user.Email || got.FirstName != user.FirstName || got.PasswordHash != user.PasswordHash || !got.CreatedAt.Equal(user.CreatedAt) {
+				t.Errorf("GetUser() = %+v, want %+v", got, user)
+			}
+
+			got, err = repo.GetUserByEmail(ctx, "ann@example.com")
+			if err != nil || got.ID != "u1" {
+				t.Errorf("GetUserByEmail() = %v, %v, want u1", got, err)
+			}
+
+			if _, err := repo.GetUser(ctx, "u2"); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("GetUser() of a missing user err: %v, want ErrRecordNotFound", err)
+			}
+			if _, err := repo.GetUserByEmail(ctx, "bob@example.com"); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("GetUserByEmail() of a missing user err: %v, want ErrRecordNotFound", err)
+			}
+		})
+	}
+}
+
+func TestRepositoryCreateDuplicateUser(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := repo.CreateUser(ctx, newTestUser("u1", "ann@example.com"), nil); err != nil {
+				t.Fatalf("CreateUser() err: %v", err)
+			}
+
+			tests := map[string]*User{
+				"same ID":    newTestUser("u1", "bob@example.com"),
+				"same email": newTestUser("u2", "ann@example.com"),
+			}
+			for name, user := range tests {
+				if err := repo.CreateUser(ctx, user, nil); !errors.Is(err, ErrDuplicateRecord) {
+					t.Errorf("CreateUser() with the %s err: %v, want ErrDuplicateRecord", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRepositoryUpdateUser(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, user := range []*User{newTestUser("u1", "ann@example.com"), newTestUser("u2", "bob@example.com")} {
+				if err := repo.CreateUser(ctx, user, nil); err != nil {
+					t.Fatalf("CreateUser() err: %v", err)
+				}
+			}
+
+			user := newTestUser("u1", "anna@example.com")
+			user.Version = 2
+			if err := repo.UpdateUser(ctx, user, nil); err != nil {
+				t.Fatalf("UpdateUser() err: %v", err)
+			}
+			if got, err := repo.GetUserByEmail(ctx, "anna@example.com"); err != nil || got.ID != "u1" || got.Version != 2 {
+				t.Errorf("GetUserByEmail() after the update = %+v, %v", got, err)
+			}
+			if _, err := repo.GetUserByEmail(ctx, "ann@example.com"); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("GetUserByEmail() of the old email err: %v, want ErrRecordNotFound", err)
+			}
+
+			taken := newTestUser("u1", "bob@example.com")
+			taken.Version = 3
+			if err := repo.UpdateUser(ctx, taken, nil); !errors.Is(err, ErrDuplicateRecord) {
+				t.Errorf("UpdateUser() to a taken email err: %v, want ErrDuplicateRecord", err)
+			}
+			missing := newTestUser("u3", "cy@example.com")
+			missing.Version = 2
+			if err := repo.UpdateUser(ctx, missing, nil); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("UpdateUser() of a missing user err: %v, want ErrRecordNotFound", err)
+			}
+		})
+	}
+}
+
+func TestRepositoryDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := repo.CreateUser(ctx, newTestUser("u1", "ann@example.com"), nil); err != nil {
+				t.Fatalf("CreateUser() err: %v", err)
+			}
+
+			if err := repo.DeleteUser(ctx, "u1", nil); err != nil {
+				t.Fatalf("DeleteUser() err: %v", err)
+			}
+			if _, err := repo.GetUser(ctx, "u1"); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("GetUser() of a deleted user err: %v, want ErrRecordNotFound", err)
+			}
+			if err := repo.DeleteUser(ctx, "u1", nil); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("DeleteUser() of a deleted user err: %v, want ErrRecordNotFound", err)
+			}
+			// The email is free again
+			if err := repo.CreateUser(ctx, newTestUser("u2", "ann@example.com"), nil); err != nil {
+				t.Errorf("CreateUser() with the email of a deleted user err: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// UserStatus - the state of a user
+type UserStatus string
+
+// User states
+const (
+	// UserStatusPending - the user has been created but has not verified the email yet
+	UserStatusPending UserStatus = "pending"
+	// UserStatusActive - the user has verified the email and can sign in
+	UserStatusActive UserStatus = "active"
+	// UserStatusDisabled - the user has been disabled and cannot sign in
+	UserStatusDisabled UserStatus = "disabled"
+)
+
+// statusTransitions - the state machine of users: status -> statuses it can move to.
+// Pending users are activated through `ActivateUser`, or by an administrator through `SetStatus`.
+var statusTransitions = map[UserStatus][]UserStatus{
+	UserStatusPending:  {UserStatusActive, UserStatusDisabled},
+	UserStatusActive:   {UserStatusDisabled},
+	UserStatusDisabled: {UserStatusActive},
+}
+
+// canTransition checks whether a user can move from one status to another
+func canTransition(from, to UserStatus) bool {
+	for _, s := range statusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// SetStatus - the implementation of the `SetStatus` method
+func (m *manager) SetStatus(ctx context.Context, ID string, status UserStatus) (*User, error) {
+	if _, ok := statusTransitions[status]; !ok {
+		return nil, newValidationError([]FieldViolation{{Field: "status", Description: "The status must be one of pending, active or disabled."}})
+	}
+
+	user, err := m.Get(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status == status {
+		return user, nil
+	}
+	return m.transition(ctx, AuditActionSetStatus, user, status)
+}
+
+// transition moves the user to the given status and stores it. `action` is recorded in the audit log.
+func (m *manager) transition(ctx context.Context, action string, user *User, status UserStatus) (*User, error) {
+	if !canTransition(user.Status, status) {
+		return nil, newCodedError(ErrTypeConflict, CodeInvalidStatusTransition, map[string]string{"id": user.ID, "status": string(user.Status)},
+			"The user %s is %s and cannot be %s.", user.ID, user.Status, status)
+	}
+
+	before := *user
+	user.Status = status
+	user.Version++
+	user.UpdatedAt = time.Now().UTC()
+	record, err := newAuditRecord(ctx, action, &before, user)
+	if err != nil {
+		return nil, err
+	}
+	event, err := newUserEvent(EventTypeUserUpdated, user)
+	if err != nil {
+		return nil, newInternalError(err, "Error creating the event of user %s", user.ID)
+	}
+	err = m.repo.UpdateUser(ctx, user, record, event)
+	if errors.Is(err, ErrVersionMismatch) {
+		return nil, newVersionMismatchError(err, user.ID)
+	}
+	if errors.Is(err, ErrRecordNotFound) {
+		return nil, newCodedError(ErrTypeNotFound, CodeUserNotFound, map[string]string{"id": user.ID}, "The user %s does not exist.", user.ID)
+	}
+	if err != nil {
+		return nil, newInternalError(err, "Error updating the status of user %s", user.ID)
+	}
+	return user, nil
+}
@@ -0,0 +1,144 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+func TestManagerTenantIsolation(t *testing.T) {
+	ctx := context.Background()
+	acme, beta := usvcTenant.WithID(ctx, "acme"), usvcTenant.WithID(ctx, "beta")
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			m := newTestManager(t, repo)
+
+			// The same email and idempotency key are used in both tenants
+			acmeID, err := m.Create(acme, "Ann", "Lee", testPassword, "ann@example.com", "key-1")
+			if err != nil {
+				t.Fatalf("Create() in acme err: %v", err)
+			}
+			betaID, err := m.Create(beta, "Ann", "Lee", testPassword, "ann@example.com", "key-1")
+			if err != nil || betaID == acmeID {
+				t.Fatalf("Create() in beta = %s, %v, want another user", betaID, err)
+			}
+			if _, err := m.Create(acme, "Ann", "Lee", testPassword, "ann@example.com", ""); errCode(err) != CodeEmailTaken {
+				t.Errorf("Create() with an email taken in the tenant err: %v, want %s", err, CodeEmailTaken)
+			}
+			if user, err := m.Get(acme, acmeID); err != nil || user.TenantID != "acme" {
+				t.Errorf("Get() in acme = %+v, %v, want the user of acme", user, err)
+			}
+
+			// Other tenants cannot find out that the user exists
+			calls := map[string]func(ctx context.Context) error{
+				"Get": func(ctx context.Context) error {
+					_, err := m.Get(ctx, acmeID)
+					return err
+				},
+				"Update": func(ctx context.Context) error {
+					_, err := m.Update(ctx, acmeID, 1, &UserUpdate{FirstName: "Anna"}, []string{UpdateMaskFirstName})
+					return err
+				},
+				"SetStatus": func(ctx context.Context) error {
+					_, err := m.SetStatus(ctx, acmeID, UserStatusActive)
+					return err
+				},
+				"Delete": func(ctx context.Context) error {
+					return m.Delete(ctx, acmeID, true)
+				},
+			}
+			for method, call := range calls {
+				for tenant, ctx := range map[string]context.Context{"beta": beta, "default": ctx} {
+					if err := call(ctx); errType(err) != ErrTypeNotFound {
+						t.Errorf("%s() of a user of acme in %s err: %v, want %s", method, tenant, err, ErrTypeNotFound)
+					}
+				}
+			}
+
+			list, err := m.List(beta, &ListFilter{}, "", 0)
+			if err != nil || len(list.Users) != 1 || list.Users[0].ID != betaID {
+				t.Errorf("List() in beta = %+v, %v, want the user of beta only", list, err)
+			}
+			var out strings.Builder
+			if err := m.Export(acme, &ListFilter{}, NewCSVExportWriter(&out)); err != nil || strings.Contains(out.String(), betaID) || !strings.Contains(out.String(), acmeID) {
+				t.Errorf("Export() in acme = %q, %v, want the user of acme only", out.String(), err)
+			}
+
+			if err := m.Delete(acme, acmeID, true); err != nil {
+				t.Fatalf("Delete() in acme err: %v", err)
+			}
+			if _, err := m.Get(beta, betaID); err != nil {
+				t.Errorf("Get() in beta after the user of acme was deleted err: %v", err)
+			}
+		})
+	}
+}
+
+func TestActivationTokensCarryTenant(t *testing.T) {
+	ctx := context.Background()
+	m, mailer := newTestMailManager(t, nil)
+	ID := mustCreate(t, usvcTenant.WithID(ctx, "acme"), m, "ann@example.com")
+
+	// The link in the email carries no tenant, so the token names it
+	user, err := m.ActivateUser(ctx, mailer.lastToken(t, "ann@example.com"))
+	if err != nil || user.ID != ID || user.TenantID != "acme" {
+		t.Errorf("ActivateUser() = %+v, %v, want user %s of acme", user, err, ID)
+	}
+}
+
+func TestMigrateSQLSchemaFromUntenantedSchema(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// The schema before users were scoped to tenants
+	for _, stmt := range []string{
+		`CREATE TABLE users (id VARCHAR(64) NOT NULL PRIMARY KEY, first_name VARCHAR(255) NOT NULL, last_name VARCHAR(255) NOT NULL,
+			email VARCHAR(255) NOT NULL UNIQUE, password_hash VARCHAR(255) NOT NULL, status VARCHAR(16) NOT NULL DEFAULT 'active',
+			session_version INT NOT NULL DEFAULT 0, version INT NOT NULL DEFAULT 1, created_at DATETIME NOT NULL, updated_at DATETIME NOT NULL,
+			deleted_at DATETIME NULL)`,
+		`CREATE TABLE idempotency_keys (idempotency_key VARCHAR(255) NOT NULL PRIMARY KEY, request_hash VARCHAR(64) NOT NULL,
+			user_id VARCHAR(64) NOT NULL, created_at DATETIME NOT NULL, expires_at DATETIME NOT NULL)`,
+		`CREATE TABLE user_tokens (id VARCHAR(64) NOT NULL PRIMARY KEY, user_id VARCHAR(64) NOT NULL, purpose VARCHAR(32) NOT NULL,
+			created_at DATETIME NOT NULL, expires_at DATETIME NOT NULL, used_at DATETIME NULL)`,
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now().UTC()
+	if _, err := db.ExecContext(ctx, `INSERT INTO users (id, first_name, last_name, email, password_hash, created_at, updated_at) VALUES ('u1', 'Ann', 'Lee', 'ann@example.com', 'hash', ?, ?)`, now, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// Migrating again changes nothing
+	for i := 0; i < 2; i++ {
+		if err := MigrateSQLSchema(ctx, db); err != nil {
+			t.Fatalf("MigrateSQLSchema() err: %v", err)
+		}
+	}
+
+	repo := NewSQLRepository(db)
+	user, err := repo.GetUser(ctx, "u1")
+	if err != nil || user.Email != "ann@example.com" || user.TenantID != usvcTenant.Default {
+		t.Fatalf("GetUser() of the migrated user = %+v, %v, want it in the default tenant", user, err)
+	}
+	// The email is now unique per tenant
+	other := newTestUser("u2", "ann@example.com")
+	if err := repo.CreateUser(usvcTenant.WithID(ctx, "acme"), other, nil); err != nil {
+		t.Errorf("CreateUser() with the email in another tenant err: %v", err)
+	}
+	other.ID = "u3"
+	if err := repo.CreateUser(ctx, other, nil); !errors.Is(err, ErrDuplicateRecord) {
+		t.Errorf("CreateUser() with the email in the default tenant err: %v, want ErrDuplicateRecord", err)
+	}
+}
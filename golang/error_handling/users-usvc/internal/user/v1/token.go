@@ -0,0 +1,162 @@
+package v1
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	usvcTenant "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/tenant"
+)
+
+// Token purposes
+const (
+	// TokenPurposeActivation - tokens sent to users to verify their emails and activate them
+	TokenPurposeActivation = "activation"
+	// TokenPurposePasswordReset - tokens sent to users who forgot their passwords
+	TokenPurposePasswordReset = "password_reset"
+)
+
+// Default token TTLs
+const (
+	// DefaultActivationTokenTTL - how long activation tokens are valid by default
+	DefaultActivationTokenTTL = 48 * time.Hour
+	// DefaultPasswordResetTokenTTL - how long password reset tokens are valid by default
+	DefaultPasswordResetTokenTTL = time.Hour
+)
+
+// tokenClaims - the signed content of a token
+type tokenClaims struct {
+	Purpose string
+	// TenantID is the tenant of the user. The links in emails carry no tenant, so tokens are used in this tenant.
+	TenantID  string
+	UserID    string
+	ID        string
+	ExpiresAt time.Time
+}
+
+// Errors returned when verifying tokens
+var (
+	errInvalidToken = errors.New("invalid token")
+	errTokenExpired = errors.New("token expired")
+)
+
+// tokenSigner signs and verifies tokens with HMAC-SHA256. A token looks like `<base64 payload>.<base64 signature>`
+// where the payload is `<purpose>.<tenant ID>.<user ID>.<token ID>.<expiry in unix seconds>`. Tenant IDs contain
+// no dots, see `tenant.Validate`.
+type tokenSigner struct {
+	secret []byte
+}
+
+// sign returns the token carrying the given claims
+func (s *tokenSigner) sign(c *tokenClaims) string {
+	payload := strings.Join([]string{c.Purpose, c.TenantID, c.UserID, c.ID, strconv.FormatInt(c.ExpiresAt.Unix(), 10)}, ".")
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(s.mac([]byte(payload)))
+}
+
+// verify checks the signature, the purpose and the expiry of the token and returns its claims
+func (s *tokenSigner) verify(token, purpose string, now time.Time) (*tokenClaims, error) {
+	encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil || !hmac.Equal(sig, s.mac(payload)) {
+		return nil, errInvalidToken
+	}
+
+	parts := strings.Split(string(payload), ".")
+	if len(parts) != 5 || parts[0] != purpose {
+		return nil, errInvalidToken
+	}
+	expiresAt, err := strconv.ParseInt(parts[4], 10, 64)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	c := &tokenClaims{
+		Purpose:   parts[0],
+		TenantID:  parts[1],
+		UserID:    parts[2],
+		ID:        parts[3],
+		ExpiresAt: time.Unix(expiresAt, 0).UTC(),
+	}
+	if !now.Before(c.ExpiresAt) {
+		return nil, errTokenExpired
+	}
+	return c, nil
+}
+
+// mac returns the HMAC-SHA256 of the payload
+func (s *tokenSigner) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// issueToken stores a single-use token for the user of the tenant in the context and returns the signed token
+func (m *manager) issueToken(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	ID, err := newID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	record := &TokenRecord{
+		ID:        ID,
+		UserID:    userID,
+		Purpose:   purpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := m.repo.CreateToken(ctx, record); err != nil {
+		return "", err
+	}
+	return m.tokens.sign(&tokenClaims{
+		Purpose:   purpose,
+		TenantID:  usvcTenant.FromContext(ctx),
+		UserID:    userID,
+		ID:        ID,
+		ExpiresAt: record.ExpiresAt,
+	}), nil
+}
+
+// verifyToken verifies the signature, the purpose and the expiry of the token and returns its claims
+func (m *manager) verifyToken(token, purpose string) (*tokenClaims, error) {
+	c, err := m.tokens.verify(token, purpose, time.Now())
+	if errors.Is(err, errTokenExpired) {
+		return nil, newCodedError(ErrTypeBadRequest, CodeTokenExpired, nil, "The %s token has expired.", purpose)
+	}
+	if err != nil {
+		return nil, newCodedError(ErrTypeBadRequest, CodeInvalidToken, nil, "The %s token is invalid.", purpose)
+	}
+	return c, nil
+}
+
+// consumeToken marks a verified token as used, so that it cannot be used again
+func (m *manager) consumeToken(ctx context.Context, c *tokenClaims) error {
+	err := m.repo.ConsumeToken(ctx, c.ID, time.Now().UTC())
+	if errors.Is(err, ErrRecordNotFound) {
+		return newCodedError(ErrTypeBadRequest, CodeInvalidToken, nil, "The %s token is invalid or has been used.", c.Purpose)
+	}
+	if err != nil {
+		return newInternalError(err, "Error consuming the %s token of user %s", c.Purpose, c.UserID)
+	}
+	return nil
+}
+
+// tokenLink returns the link to the page which accepts the token, or the token itself if the page is not configured
+func tokenLink(pageURL, token string) string {
+	if pageURL == "" {
+		return token
+	}
+	return pageURL + "?token=" + url.QueryEscape(token)
+}
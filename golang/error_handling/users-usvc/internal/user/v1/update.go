@@ -0,0 +1,95 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Fields that can be listed in the mask passed to `Update`
+const (
+	// UpdateMaskFirstName - update the first name
+	UpdateMaskFirstName = "first_name"
+	// UpdateMaskLastName - update the last name
+	UpdateMaskLastName = "last_name"
+	// UpdateMaskEmail - update the email
+	UpdateMaskEmail = "email"
+)
+
+// UserUpdate carries the new values used by `Update`. Only the fields listed in the mask are applied.
+type UserUpdate struct {
+	FirstName string
+	LastName  string
+	Email     string
+}
+
+// Update - the implementation of the `Update` method
+func (m *manager) Update(ctx context.Context, ID string, version int, update *UserUpdate, mask []string) (*User, error) {
+	if len(mask) == 0 {
+		return nil, newError(ErrTypeBadRequest, "The update mask is empty.")
+	}
+
+	user, err := m.Get(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Version != version {
+		return nil, newVersionMismatchError(nil, ID)
+	}
+
+	before := *user
+	var violations, vs []FieldViolation
+	for _, field := range mask {
+		switch field {
+		case UpdateMaskFirstName:
+			user.FirstName, vs = m.validator.ValidateName(FieldFirstName, update.FirstName)
+		case UpdateMaskLastName:
+			user.LastName, vs = m.validator.ValidateName(FieldLastName, update.LastName)
+		case UpdateMaskEmail:
+			user.Email, vs = m.validator.ValidateEmail(update.Email)
+		default:
+			vs = []FieldViolation{{Field: field, Description: "The field cannot be updated."}}
+		}
+		violations = append(violations, vs...)
+	}
+	if len(violations) > 0 {
+		return nil, newValidationError(violations)
+	}
+	user.Version++
+	user.UpdatedAt = time.Now().UTC()
+
+	record, err := newAuditRecord(ctx, AuditActionUpdate, &before, user)
+	if err != nil {
+		return nil, err
+	}
+	event, err := newUserEvent(EventTypeUserUpdated, user)
+	if err != nil {
+		return nil, newInternalError(err, "Error creating the event of user %s", ID)
+	}
+	err = m.repo.UpdateUser(ctx, user, record, event)
+	if errors.Is(err, ErrDuplicateRecord) {
+		return nil, wrapError(err, newCodedError(ErrTypeConflict, CodeEmailTaken, map[string]string{"email": user.Email}, "The email %s has been used by another user.", user.Email))
+	}
+	if errors.Is(err, ErrVersionMismatch) {
+		return nil, newVersionMismatchError(err, ID)
+	}
+	if errors.Is(err, ErrRecordNotFound) {
+		return nil, newCodedError(ErrTypeNotFound, CodeUserNotFound, map[string]string{"id": ID}, "The user %s does not exist.", ID)
+	}
+	if err != nil {
+		return nil, newInternalError(err, "Error updating user %s", ID)
+	}
+
+	return user, nil
+}
+
+// newVersionMismatchError returns the conflict error of a change based on a stale version of the user, optionally
+// caused by ErrVersionMismatch returned by the repository
+func newVersionMismatchError(cause error, ID string) Error {
+	err := newCodedError(ErrTypeConflict, CodeVersionMismatch, map[string]string{"id": ID},
+		"The user %s has been changed by another request. Get the user and retry with its current version.", ID)
+	if cause != nil {
+		return wrapError(cause, err)
+	}
+	return err
+}
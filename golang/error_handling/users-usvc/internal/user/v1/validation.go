@@ -0,0 +1,222 @@
+package v1
+
+import (
+	"bufio"
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Names of the fields reported in FieldViolation
+const (
+	FieldFirstName = "first_name"
+	FieldLastName  = "last_name"
+	FieldEmail     = "email"
+	FieldPassword  = "password"
+)
+
+// maxEmailLength - the maximum length of an email address (RFC 5321)
+const maxEmailLength = 254
+
+// ValidatorConfig defines the rules used to validate user input
+type ValidatorConfig struct {
+	// MaxNameLength is the maximum number of characters in a first or last name
+	MaxNameLength int
+
+	// MinPasswordLength is the minimum number of characters in a password
+	MinPasswordLength int
+	// MaxPasswordLength is the maximum number of bytes in a password. bcrypt ignores anything beyond 72 bytes.
+	MaxPasswordLength int
+	// MinPasswordCharClasses is the minimum number of character classes (lower case letters, upper case letters,
+	// digits and symbols) a password must contain
+	MinPasswordCharClasses int
+	// BreachedPasswordsFile is the path to a file listing breached passwords, one per line. It is optional.
+	BreachedPasswordsFile string
+}
+
+// DefaultValidatorConfig returns the config used when no Validator is given to `NewManager`
+func DefaultValidatorConfig() ValidatorConfig {
+	return ValidatorConfig{
+		MaxNameLength:          100,
+		MinPasswordLength:      8,
+		MaxPasswordLength:      72,
+		MinPasswordCharClasses: 3,
+	}
+}
+
+// Validator defines the interface for validating and normalizing user input.
+// Each method returns every violation it finds instead of stopping at the first one.
+type Validator interface {
+	// ValidateEmail validates the email and returns its normalized form
+	ValidateEmail(email string) (string, []FieldViolation)
+	// ValidateName validates a first or last name and returns its normalized form
+	ValidateName(field, name string) (string, []FieldViolation)
+	// ValidatePassword validates the password against the password policy
+	ValidatePassword(password string) []FieldViolation
+}
+
+// validator is the implementation of Validator interface
+type validator struct {
+	cfg      ValidatorConfig
+	breached map[string]struct{}
+}
+
+// NewValidator creates an instance of Validator with the given config. Zero fields of the config are filled from
+// `DefaultValidatorConfig` so that callers only need to set the rules they want to change.
+func NewValidator(cfg ValidatorConfig) (Validator, error) {
+	def := DefaultValidatorConfig()
+	if cfg.MaxNameLength == 0 {
+		cfg.MaxNameLength = def.MaxNameLength
+	}
+	if cfg.MinPasswordLength == 0 {
+		cfg.MinPasswordLength = def.MinPasswordLength
+	}
+	if cfg.MaxPasswordLength == 0 {
+		cfg.MaxPasswordLength = def.MaxPasswordLength
+	}
+	if cfg.MinPasswordCharClasses == 0 {
+		cfg.MinPasswordCharClasses = def.MinPasswordCharClasses
+	}
+	if cfg.MaxNameLength < 0 || cfg.MinPasswordLength < 0 || cfg.MaxPasswordLength < cfg.MinPasswordLength ||
+		cfg.MinPasswordCharClasses < 0 || cfg.MinPasswordCharClasses > 4 {
+		return nil, fmt.Errorf("invalid validator config %+v", cfg)
+	}
+
+	v := &validator{
+		cfg:      cfg,
+		breached: map[string]struct{}{},
+	}
+	if cfg.BreachedPasswordsFile != "" {
+		if err := v.loadBreachedPasswords(cfg.BreachedPasswordsFile); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// loadBreachedPasswords loads the breached password list. Passwords are compared case-insensitively.
+func (v *validator) loadBreachedPasswords(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening breached password list %s, err: %s", path, err.Error())
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			v.breached[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading breached password list %s, err: %s", path, err.Error())
+	}
+	return nil
+}
+
+// ValidateEmail - the implementation of the `ValidateEmail` method. Emails are trimmed and lower-cased
+// so that the same address cannot be registered twice with different cases.
+func (v *validator) ValidateEmail(email string) (string, []FieldViolation) {
+	email = normalizeEmail(email)
+
+	if email == "" {
+		return email, []FieldViolation{{Field: FieldEmail, Description: "The email is required."}}
+	}
+	if len(email) > maxEmailLength {
+		return email, []FieldViolation{{Field: FieldEmail, Description: fmt.Sprintf("The email must not exceed %d characters.", maxEmailLength)}}
+	}
+
+	// Reject display names and other forms that `mail.ParseAddress` accepts but are not plain addresses
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return email, []FieldViolation{{Field: FieldEmail, Description: "The email is not a valid address."}}
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return email, []FieldViolation{{Field: FieldEmail, Description: "The email domain is not valid."}}
+	}
+
+	return email, nil
+}
+
+// normalizeEmail returns the normalized form of an email, which is used to store and look up users
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ValidateName - the implementation of the `ValidateName` method. Names are trimmed and normalized to Unicode NFC.
+// They may contain letters, combining marks, spaces, apostrophes, hyphens and periods.
+func (v *validator) ValidateName(field, name string) (string, []FieldViolation) {
+	name = norm.NFC.String(strings.TrimSpace(name))
+
+	var violations []FieldViolation
+	if name == "" {
+		return name, []FieldViolation{{Field: field, Description: "The name is required."}}
+	}
+	if utf8.RuneCountInString(name) > v.cfg.MaxNameLength {
+		violations = append(violations, FieldViolation{Field: field, Description: fmt.Sprintf("The name must not exceed %d characters.", v.cfg.MaxNameLength)})
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsMark(r) && !strings.ContainsRune(" '-.’", r) {
+			violations = append(violations, FieldViolation{Field: field, Description: fmt.Sprintf("The name contains an invalid character %q.", r)})
+			break
+		}
+	}
+	if !unicode.IsLetter([]rune(name)[0]) {
+		violations = append(violations, FieldViolation{Field: field, Description: "The name must start with a letter."})
+	}
+	return name, violations
+}
+
+// ValidatePassword - the implementation of the `ValidatePassword` method
+func (v *validator) ValidatePassword(password string) []FieldViolation {
+	var violations []FieldViolation
+	add := func(format string, a ...interface{}) {
+		violations = append(violations, FieldViolation{Field: FieldPassword, Description: fmt.Sprintf(format, a...)})
+	}
+
+	if utf8.RuneCountInString(password) < v.cfg.MinPasswordLength {
+		add("The password must contain at least %d characters.", v.cfg.MinPasswordLength)
+	}
+	if len(password) > v.cfg.MaxPasswordLength {
+		add("The password must not exceed %d bytes.", v.cfg.MaxPasswordLength)
+	}
+
+	var lower, upper, digit, symbol, invalid bool
+	for _, r := range password {
+		switch {
+		case !unicode.IsPrint(r):
+			invalid = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if invalid {
+		add("The password contains some invalid characters.")
+	}
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit, symbol} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < v.cfg.MinPasswordCharClasses {
+		add("The password must contain at least %d of the following: lower case letters, upper case letters, digits and symbols.", v.cfg.MinPasswordCharClasses)
+	}
+
+	if _, ok := v.breached[strings.ToLower(password)]; ok {
+		add("The password has appeared in a data breach, please choose another one.")
+	}
+	return violations
+}
@@ -0,0 +1,141 @@
+package v1
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateEmail(t *testing.T) {
+	v, _ := NewValidator(DefaultValidatorConfig())
+	tests := []struct {
+		email   string
+		want    string
+		wantErr bool
+	}{
+		{"ann@example.com", "ann@example.com", false},
+		{"  Ann.Lee@Example.COM ", "ann.lee@example.com", false},
+		{"", "", true},
+		{"ann", "ann", true},
+		{"Ann <ann@example.com>", "", true},
+		{"ann@localhost", "", true},
+		{"ann@example.", "", true},
+		{strings.Repeat("a", 250) + "@example.com", "", true},
+	}
+	for _, tt := range tests {
+		got, violations := v.ValidateEmail(tt.email)
+		if (len(violations) > 0) != tt.wantErr {
+			t.Errorf("ValidateEmail(%q) violations: %v, want violations: %v", tt.email, violations, tt.wantErr)
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ValidateEmail(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+		for _, violation := range violations {
+			if violation.Field != FieldEmail {
+				t.Errorf("ValidateEmail(%q) violation of field %s, want %s", tt.email, violation.Field, FieldEmail)
+			}
+		}
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	v, _ := NewValidator(DefaultValidatorConfig())
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"Ann", "Ann", false},
+		{" Mary-Jane O'Neil ", "Mary-Jane O'Neil", false},
+		{"Zoë", "Zoë", false},
+		// The decomposed form is normalized to NFC
+		{"Zoe\u0308", "Zo\u00eb", false},
+		{"", "", true},
+		{"   ", "", true},
+		{"-Ann", "", true},
+		{"Ann2", "", true},
+		{"<script>", "", true},
+		{strings.Repeat("a", 101), "", true},
+	}
+	for _, tt := range tests {
+		got, violations := v.ValidateName(FieldFirstName, tt.name)
+		if (len(violations) > 0) != tt.wantErr {
+			t.Errorf("ValidateName(%q) violations: %v, want violations: %v", tt.name, violations, tt.wantErr)
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ValidateName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	breached := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(breached, []byte("Password123!\n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultValidatorConfig()
+	cfg.BreachedPasswordsFile = breached
+	v, err := NewValidator(cfg)
+	if err != nil {
+		t.Fatalf("NewValidator() err: %v", err)
+	}
+
+	tests := []struct {
+		password       string
+		wantViolations int
+	}{
+		{testPassword, 0},
+		{"Sh0rt!", 1},
+		{"alllowercase", 1},
+		{strings.Repeat("Aa1!", 19), 1},
+		{"Passw0rd!\x00xyz", 1},
+		// Breached passwords are matched case-insensitively
+		{"PASSWORD123!", 1},
+		{"short", 2},
+	}
+	for _, tt := range tests {
+		violations := v.ValidatePassword(tt.password)
+		if len(violations) != tt.wantViolations {
+			t.Errorf("ValidatePassword(%q) = %v, want %d violations", tt.password, violations, tt.wantViolations)
+		}
+	}
+
+	cfg.BreachedPasswordsFile = filepath.Join(t.TempDir(), "missing.txt")
+	if _, err := NewValidator(cfg); err == nil {
+		t.Error("NewValidator() with a missing breached password list err: nil, want an error")
+	}
+}
+
+func TestNewValidatorConfig(t *testing.T) {
+	// Only the breached password list is set, the other rules are the default ones
+	v, err := NewValidator(ValidatorConfig{BreachedPasswordsFile: filepath.Join(t.TempDir(), "missing.txt")})
+	if err == nil {
+		t.Fatal("NewValidator() with a missing breached password list err: nil, want an error")
+	}
+	if v, err = NewValidator(ValidatorConfig{MinPasswordLength: 10}); err != nil {
+		t.Fatalf("NewValidator() with a partial config err: %v", err)
+	}
+	if _, violations := v.ValidateName(FieldFirstName, "Ann"); len(violations) != 0 {
+		t.Errorf("ValidateName() with a partial config = %v, want no violations", violations)
+	}
+	if violations := v.ValidatePassword(testPassword); len(violations) != 0 {
+		t.Errorf("ValidatePassword() with a partial config = %v, want no violations", violations)
+	}
+	if violations := v.ValidatePassword("Passw0rd!"); len(violations) != 1 {
+		t.Errorf("ValidatePassword() of a password shorter than the configured minimum = %v, want 1 violation", violations)
+	}
+
+	tests := map[string]ValidatorConfig{
+		"negative name length":      {MaxNameLength: -1},
+		"maximum below the minimum": {MinPasswordLength: 16, MaxPasswordLength: 12},
+		"too many char classes":     {MinPasswordCharClasses: 5},
+	}
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewValidator(cfg); err == nil {
+				t.Error("NewValidator() err: nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestManagerUpdateVersionMismatch(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			m := newTestManager(t, repo)
+			ID := mustCreate(t, ctx, m, "ann@example.com")
+			if _, err := m.Update(ctx, ID, 1, &UserUpdate{FirstName: "Anna"}, []string{UpdateMaskFirstName}); err != nil {
+				t.Fatalf("Update() err: %v", err)
+			}
+
+			// The update is based on a copy read before the first one, so it must not overwrite it
+			_, err := m.Update(ctx, ID, 1, &UserUpdate{LastName: "Kim"}, []string{UpdateMaskLastName})
+			if e, ok := ConvertError(err); !ok || e.Type() != ErrTypeConflict || e.Code() != CodeVersionMismatch {
+				t.Errorf("Update() with a stale version err: %v, want %s/%s", err, ErrTypeConflict, CodeVersionMismatch)
+			}
+			if user, _ := m.Get(ctx, ID); user.FirstName != "Anna" || user.LastName != "Lee" || user.Version != 2 {
+				t.Errorf("Get() after the rejected update = %+v, want the first update only at version 2", user)
+			}
+		})
+	}
+}
+
+func TestRepositoryUpdateUserVersionMismatch(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := repo.CreateUser(ctx, newTestUser("u1", "ann@example.com"), nil); err != nil {
+				t.Fatalf("CreateUser() err: %v", err)
+			}
+
+			// Two writers read version 1 and both try to store version 2
+			first, second := newTestUser("u1", "ann@example.com"), newTestUser("u1", "ann@example.com")
+			first.FirstName, second.FirstName = "Anna", "Annie"
+			first.Version, second.Version = 2, 2
+			if err := repo.UpdateUser(ctx, first, nil); err != nil {
+				t.Fatalf("UpdateUser() err: %v", err)
+			}
+			if err := repo.UpdateUser(ctx, second, nil); !errors.Is(err, ErrVersionMismatch) {
+				t.Errorf("UpdateUser() with a stale version err: %v, want ErrVersionMismatch", err)
+			}
+			if got, err := repo.GetUser(ctx, "u1"); err != nil || got.FirstName != "Anna" {
+				t.Errorf("GetUser() after the rejected update = %+v, %v, want the first update", got, err)
+			}
+		})
+	}
+}
+
+func TestRepositoryUpdatePasswordHash(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := repo.CreateUser(ctx, newTestUser("u1", "ann@example.com"), nil); err != nil {
+				t.Fatalf("CreateUser() err: %v", err)
+			}
+
+			if err := repo.UpdatePasswordHash(ctx, "u1", 1, "new-hash"); err != nil {
+				t.Fatalf("UpdatePasswordHash() err: %v", err)
+			}
+			if got, err := repo.GetUser(ctx, "u1"); err != nil || got.PasswordHash != "new-hash" || got.Version != 1 {
+				t.Errorf("GetUser() after UpdatePasswordHash() = %+v, %v, want the new hash at version 1", got, err)
+			}
+
+			if err := repo.UpdatePasswordHash(ctx, "u1", 2, "stale-hash"); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("UpdatePasswordHash() with another version err: %v, want ErrRecordNotFound", err)
+			}
+			if err := repo.UpdatePasswordHash(ctx, "u2", 1, "new-hash"); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("UpdatePasswordHash() of a missing user err: %v, want ErrRecordNotFound", err)
+			}
+		})
+	}
+}
+
+func TestVerifyCredentialsRehashKeepsVersion(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ID := newActiveUser(t, ctx, newTestManager(t, repo), "ann@example.com")
+			before, err := repo.GetUser(ctx, ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The algorithm has been changed since the password was hashed
+			hasher, err := NewPasswordHasher(testPasswordConfigs()[PasswordAlgorithmArgon2id])
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := newTestManager(t, repo, WithPasswordHasher(hasher))
+			if _, err := m.VerifyCredentials(ctx, "ann@example.com", testPassword); err != nil {
+				t.Fatalf("VerifyCredentials() err: %v", err)
+			}
+
+			after, err := repo.GetUser(ctx, ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after.PasswordHash == before.PasswordHash || hasher.NeedsRehash(after.PasswordHash) {
+				t.Errorf("the password hash after VerifyCredentials() = %s, want it rehashed", after.PasswordHash)
+			}
+			if after.Version != before.Version || !after.UpdatedAt.Equal(before.UpdatedAt) {
+				t.Errorf("VerifyCredentials() changed the version or the update time to %d, %v, want %d, %v",
+					after.Version, after.UpdatedAt, before.Version, before.UpdatedAt)
+			}
+			if _, err := m.VerifyCredentials(ctx, "ann@example.com", testPassword); err != nil {
+				t.Errorf("VerifyCredentials() with the rehashed password err: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+// Package errors provides the errors shared by micro-services. It supports both error handling styles described in
+// the error handling blog through one `Error` interface:
+//
+//   - Error type as a property: `Type()` returns an ErrType which callers can switch on.
+//   - Error types as structs: errors of the common types are `*BadRequestErr`, `*ConflictErr`, etc.,
+//     so type switches and `errors.As` on these structs keep working.
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*************************************************************************/
+// Error Types
+
+// ErrType - error type
+type ErrType string
+
+// Error types
+const (
+	// ErrTypeBadRequest - bad request
+	ErrTypeBadRequest ErrType = "bad_request"
+	// ErrTypeConflict - resource conflicts
+	ErrTypeConflict ErrType = "conflict"
+	// ErrTypeMethodNotAllowed - the resource does not support the method or the operation
+	ErrTypeMethodNotAllowed ErrType = "method_not_allowed"
+	// ErrTypeNotFound - resource not found
+	ErrTypeNotFound ErrType = "not_found"
+	// ErrTypeUnauthorized - the credentials are missing or invalid
+	ErrTypeUnauthorized ErrType = "unauthorized"
+	// ErrTypeForbidden - the caller is not allowed to perform the operation
+	ErrTypeForbidden ErrType = "forbidden"
+	// ErrTypePayloadTooLarge - the request body exceeds the maximum size
+	ErrTypePayloadTooLarge ErrType = "payload_too_large"
+	// ErrTypeUnsupportedMediaType - the request body is in a format which the endpoint does not accept
+	ErrTypeUnsupportedMediaType ErrType = "unsupported_media_type"
+	// ErrTypeRateLimited - the caller has sent too many requests
+	ErrTypeRateLimited ErrType = "rate_limited"
+	// ErrTypeInternalServerErr - internal server error
+	ErrTypeInternalServerErr ErrType = "internal_server_error"
+	// ErrTypeUnavailable - the service or one of its dependencies is temporarily unavailable
+	ErrTypeUnavailable ErrType = "unavailable"
+	// ErrTypeTimeout - the deadline of the request passed before the operation completed
+	ErrTypeTimeout ErrType = "timeout"
+	// ErrTypeCanceled - the request was canceled by the caller, e.g. the client disconnected
+	ErrTypeCanceled ErrType = "canceled"
+	// ErrTypeUnknown - Unknown error
+	ErrTypeUnknown ErrType = "unknown"
+)
+
+// Sentinel errors, one per error type. Use them with `errors.Is` to match errors by type, e.g.
+// `errors.Is(err, ErrConflict)`, while `errors.As` can still reach the root cause.
+var (
+	ErrBadRequest           error = newSentinel(ErrTypeBadRequest)
+	ErrConflict             error = newSentinel(ErrTypeConflict)
+	ErrMethodNotAllowed     error = newSentinel(ErrTypeMethodNotAllowed)
+	ErrNotFound             error = newSentinel(ErrTypeNotFound)
+	ErrUnauthorized         error = newSentinel(ErrTypeUnauthorized)
+	ErrForbidden            error = newSentinel(ErrTypeForbidden)
+	ErrPayloadTooLarge      error = newSentinel(ErrTypePayloadTooLarge)
+	ErrUnsupportedMediaType error = newSentinel(ErrTypeUnsupportedMediaType)
+	ErrRateLimited          error = newSentinel(ErrTypeRateLimited)
+	ErrInternalServerErr    error = newSentinel(ErrTypeInternalServerErr)
+	ErrUnavailable          error = newSentinel(ErrTypeUnavailable)
+	ErrTimeout              error = newSentinel(ErrTypeTimeout)
+	ErrCanceled             error = newSentinel(ErrTypeCanceled)
+	ErrUnknown              error = newSentinel(ErrTypeUnknown)
+)
+
+// newSentinel creates the sentinel error of the given error type
+func newSentinel(errType ErrType) *baseErr {
+	return &baseErr{
+		msg:      string(errType),
+		errType:  errType,
+		sentinel: true,
+	}
+}
+
+/************************************************************************/
+// Error definition
+
+// Error interface defines the errors shared by micro-services
+type Error interface {
+	error
+	// Type returns the error category
+	Type() ErrType
+	// Code returns a machine-readable code which is more specific than the error type, e.g. `email_taken`
+	Code() string
+	// Details returns the field violations which cause the error, if any
+	Details() []FieldViolation
+	// Retryable tells whether the same request may succeed if it is retried later
+	Retryable() bool
+	// Metadata returns additional key-value information about the error, if any
+	Metadata() map[string]string
+}
+
+// FieldViolation describes why a field of the input is invalid
+type FieldViolation struct {
+	Field       string `json:"name"`
+	Description string `json:"reason"`
+}
+
+// CodeInvalidFields - some fields of the input are invalid, see `Details()`
+const CodeInvalidFields = "invalid_fields"
+
+// baseErr - base implementation of Error interface. The error type structs embed it.
+type baseErr struct {
+	msg        string
+	errType    ErrType
+	code       string
+	violations []FieldViolation
+	retryable  bool
+	metadata   map[string]string
+	cause      error
+	origin     *origin
+	// sentinel is set for the sentinel errors, which match any error with the same type in `errors.Is`
+	sentinel bool
+}
+
+// Error returns error message, followed by the message of the cause if there is one
+func (e *baseErr) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.cause != nil {
+		return fmt.Sprintf("%s, err: %s", e.msg, e.cause.Error())
+	}
+	return e.msg
+}
+
+// Message returns error message without the message of the cause
+func (e *baseErr) Message() string {
+	if e != nil {
+		return e.msg
+	}
+	return ""
+}
+
+// Type returns error type
+func (e *baseErr) Type() ErrType {
+	if e != nil {
+		return e.errType
+	}
+	return ErrTypeUnknown
+}
+
+// Code returns error code. It falls back to the error type if no specific code is set.
+func (e *baseErr) Code() string {
+	if e == nil {
+		return string(ErrTypeUnknown)
+	}
+	if e.code != "" {
+		return e.code
+	}
+	return string(e.errType)
+}
+
+// Details returns field violations
+func (e *baseErr) Details() []FieldViolation {
+	if e != nil {
+		return e.violations
+	}
+	return nil
+}
+
+// Retryable returns whether the request can be retried
+func (e *baseErr) Retryable() bool {
+	if e != nil {
+		return e.retryable
+	}
+	return false
+}
+
+// Metadata returns error metadata
+func (e *baseErr) Metadata() map[string]string {
+	if e != nil {
+		return e.metadata
+	}
+	return nil
+}
+
+// Unwrap returns the cause of the error so that `errors.Is` and `errors.As` can inspect it
+func (e *baseErr) Unwrap() error {
+	if e != nil {
+		return e.cause
+	}
+	return nil
+}
+
+// Is reports whether the error matches the target in `errors.Is`. An error matches a sentinel error with the same type.
+func (e *baseErr) Is(target error) bool {
+	t, ok := target.(*baseErr)
+	if !ok || e == nil || t == nil {
+		return false
+	}
+	return e == t || (t.sentinel && t.errType == e.errType)
+}
+
+// base returns the base implementation. It is promoted to the error type structs.
+func (e *baseErr) base() *baseErr {
+	return e
+}
+
+// New returns an error with given error type. Whether it is retryable depends on the error type.
+// Struct and map arguments of the message are redacted, see `SetRedactFunc`.
+func New(errType ErrType, format string, a ...interface{}) Error {
+	return typed(&baseErr{
+		msg:       fmt.Sprintf(format, redactArgs(a)...),
+		errType:   errType,
+		retryable: errType.info().retryable,
+		origin:    captureOrigin(),
+	})
+}
+
+// NewCoded returns an error with given error type, code and optional metadata
+func NewCoded(errType ErrType, code string, metadata map[string]string, format string, a ...interface{}) Error {
+	return typed(&baseErr{
+		msg:       fmt.Sprintf(format, redactArgs(a)...),
+		errType:   errType,
+		code:      code,
+		retryable: errType.info().retryable,
+		metadata:  metadata,
+		origin:    captureOrigin(),
+	})
+}
+
+// NewValidation returns a bad request error which carries the given field violations
+func NewValidation(violations []FieldViolation) Error {
+	descs := make([]string, 0, len(violations))
+	for _, v := range violations {
+		descs = append(descs, fmt.Sprintf("%s: %s", v.Field, v.Description))
+	}
+	return typed(&baseErr{
+		msg:        fmt.Sprintf("The request contains invalid fields. %s", strings.Join(descs, " ")),
+		errType:    ErrTypeBadRequest,
+		code:       CodeInvalidFields,
+		violations: violations,
+		origin:     captureOrigin(),
+	})
+}
+
+// Wrap returns a copy of an error created by this package which records the downstream error causing it. The given
+// error is left unchanged, so shared errors such as the sentinel errors can be wrapped safely. Errors of other
+// implementations are returned as they are.
+func Wrap(cause error, err Error) Error {
+	b, ok := err.(interface{ base() *baseErr })
+	if !ok {
+		return err
+	}
+	e := *b.base()
+	e.cause = cause
+	e.sentinel = false
+	return typed(&e)
+}
+
+// WrapInternal returns an internal server error caused by a downstream error. If the downstream error is caused by
+// a context which is done, the error type is ErrTypeTimeout or ErrTypeCanceled instead.
+func WrapInternal(cause error, format string, a ...interface{}) Error {
+	errType := ErrTypeInternalServerErr
+	switch {
+	case errors.Is(cause, context.DeadlineExceeded):
+		errType = ErrTypeTimeout
+	case errors.Is(cause, context.Canceled):
+		errType = ErrTypeCanceled
+	}
+	return Wrap(cause, New(errType, format, a...))
+}
+
+// Convert - try converting an `error` interface to an `Error` interface.
+// It finds the first `Error` in the chain of wrapped errors, so it still works after callers wrap the error.
+func Convert(err error) (Error, bool) {
+	var e Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+
+	return nil, false
+}
+
+// message returns the message of an error without the message of its cause
+func message(err Error) string {
+	if m, ok := err.(interface{ Message() string }); ok {
+		return m.Message()
+	}
+	return err.Error()
+}
@@ -0,0 +1,137 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNewTypedErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrType
+	}{
+		{NewBadRequestErr("bad"), ErrTypeBadRequest},
+		{NewConflictErr("conflict"), ErrTypeConflict},
+		{NewNotFoundErr("not found"), ErrTypeNotFound},
+		{NewUnauthorizedErr("unauthorized"), ErrTypeUnauthorized},
+		{NewInternelServerErr("internal"), ErrTypeInternalServerErr},
+	}
+	for _, tt := range tests {
+		// Both error handling styles work on the same error
+		var got ErrType
+		switch tt.err.(type) {
+		case *BadRequestErr:
+			got = ErrTypeBadRequest
+		case *ConflictErr:
+			got = ErrTypeConflict
+		case *NotFoundErr:
+			got = ErrTypeNotFound
+		case *UnauthorizedErr:
+			got = ErrTypeUnauthorized
+		case *InternelServerErr:
+			got = ErrTypeInternalServerErr
+		}
+		if got != tt.want {
+			t.Errorf("error %q is %T, want the struct of %s", tt.err, tt.err, tt.want)
+		}
+		if e, ok := Convert(tt.err); !ok || e.Type() != tt.want {
+			t.Errorf("Convert(%q).Type() = %v, want %s", tt.err, e, tt.want)
+		}
+	}
+
+	// Types without a struct are returned as Error
+	if e := New(ErrTypeUnknown, "unknown"); e.Type() != ErrTypeUnknown {
+		t.Errorf("New(%s).Type() = %s", ErrTypeUnknown, e.Type())
+	}
+}
+
+func TestErrorCodeAndRetryable(t *testing.T) {
+	e := New(ErrTypeConflict, "The email %s has been used.", "ann@example.com")
+	if e.Error() != "The email ann@example.com has been used." {
+		t.Errorf("Error() = %q", e.Error())
+	}
+	if e.Code() != string(ErrTypeConflict) {
+		t.Errorf("Code() without a specific code = %q, want the error type", e.Code())
+	}
+	if e.Retryable() {
+		t.Error("Retryable() of a conflict = true")
+	}
+
+	coded := NewCoded(ErrTypeInternalServerErr, "db_down", map[string]string{"db": "users"}, "The database is down.")
+	if coded.Code() != "db_down" || coded.Metadata()["db"] != "users" || !coded.Retryable() {
+		t.Errorf("NewCoded() = code %s, metadata %v, retryable %v", coded.Code(), coded.Metadata(), coded.Retryable())
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	violations := []FieldViolation{
+		{Field: "email", Description: "The email is required."},
+		{Field: "password", Description: "The password is too short."},
+	}
+	e := NewValidation(violations)
+	if _, ok := e.(*BadRequestErr); !ok {
+		t.Errorf("NewValidation() is %T, want *BadRequestErr", e)
+	}
+	if e.Code() != CodeInvalidFields || len(e.Details()) != 2 {
+		t.Errorf("NewValidation() = code %s, details %v", e.Code(), e.Details())
+	}
+	want := "The request contains invalid fields. email: The email is required. password: The password is too short."
+	if e.Error() != want {
+		t.Errorf("Error() = %q, want %q", e.Error(), want)
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	cause := errors.New("duplicate key")
+	err := Wrap(cause, NewCoded(ErrTypeConflict, "email_taken", nil, "The email has been used."))
+
+	if !errors.Is(err, ErrConflict) {
+		t.Error("errors.Is(err, ErrConflict) = false")
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Error("errors.Is(err, ErrNotFound) = true")
+	}
+	if _, ok := err.(*ConflictErr); !ok {
+		t.Errorf("Wrap() of a conflict is %T, want *ConflictErr", err)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("errors.Is(err, cause) = false")
+	}
+	if !errors.Is(fmt.Errorf("wrapped: %w", err), ErrConflict) {
+		t.Error("errors.Is(wrapped, ErrConflict) = false")
+	}
+	// Errors of the same type only match each other through the sentinels
+	if errors.Is(err, New(ErrTypeConflict, "other")) {
+		t.Error("errors.Is(err, another conflict) = true")
+	}
+	if err.Error() != "The email has been used., err: duplicate key" {
+		t.Errorf("Error() = %q, want the message followed by the cause", err.Error())
+	}
+}
+
+func TestNilError(t *testing.T) {
+	var e *baseErr
+	if e.Error() != "" || e.Type() != ErrTypeUnknown || e.Code() != string(ErrTypeUnknown) || e.Details() != nil ||
+		e.Retryable() || e.Metadata() != nil || e.Unwrap() != nil {
+		t.Error("the methods of a nil error do not return zero values")
+	}
+}
+
+func TestWrapCopiesTheError(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := New(ErrTypeInternalServerErr, "Error getting user u1")
+
+	wrapped := Wrap(cause, err)
+	if !errors.Is(wrapped, cause) || errors.Is(err, cause) {
+		t.Errorf("Wrap() = %v and left %v, want only the copy to carry the cause", wrapped, err)
+	}
+	// Wrapping a sentinel error neither changes it nor makes the copy a sentinel
+	wrapped = Wrap(cause, ErrNotFound.(Error))
+	if errors.Is(ErrNotFound, cause) || ErrNotFound.Error() != string(ErrTypeNotFound) {
+		t.Errorf("Wrap() of ErrNotFound changed it to %v", ErrNotFound)
+	}
+	if !errors.Is(wrapped, ErrNotFound) || errors.Is(New(ErrTypeNotFound, "other"), wrapped) {
+		t.Errorf("Wrap() of ErrNotFound = %v, want a not found error which is not a sentinel", wrapped)
+	}
+}
@@ -0,0 +1,74 @@
+package errors
+
+import (
+	"encoding/json"
+)
+
+// ProblemTypeBaseURI is the prefix of the `type` member of problem details. The error code is appended to it.
+// It is a relative URI reference by default so that it resolves against the host of each service.
+var ProblemTypeBaseURI = "/problems/"
+
+// ProblemContentType is the media type which should be set as `Content-Type` when writing problem details
+const ProblemContentType = "application/problem+json"
+
+// ProblemDetails represents an error in the problem details format defined in RFC 7807.
+// The members after `Instance` are extension members.
+type ProblemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	Code          string            `json:"code"`
+	ErrType       ErrType           `json:"error_type"`
+	InvalidParams []FieldViolation  `json:"invalid-params,omitempty"`
+	Retryable     bool              `json:"retryable"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+// NewProblemDetails converts an error to problem details. Errors which are not `Error` are reported as unknown
+// errors without exposing their messages, as they may contain internal details.
+func NewProblemDetails(err error) *ProblemDetails {
+	e, ok := Convert(err)
+	if !ok {
+		e = New(ErrTypeUnknown, unknownErrorMessage)
+	}
+
+	detail := publicMessage(e)
+	return &ProblemDetails{
+		Type:          ProblemTypeBaseURI + e.Code(),
+		Title:         e.Type().info().title,
+		Status:        e.Type().HTTPStatusCode(),
+		Detail:        detail,
+		Code:          e.Code(),
+		ErrType:       e.Type(),
+		InvalidParams: e.Details(),
+		Retryable:     e.Retryable(),
+		Metadata:      e.Metadata(),
+	}
+}
+
+// Messages returned to clients in place of the messages of internal and unknown errors
+const (
+	internalErrorMessage = "Internal server error, please retry later."
+	unknownErrorMessage  = "Unknown error, please retry later."
+)
+
+// publicMessage returns the message of the error which can be returned to clients, leaving out the cause, which is
+// meant for logs. Messages of internal and unknown errors carry downstream errors and user input which should not
+// reach clients, so they are replaced with generic ones.
+func publicMessage(e Error) string {
+	switch e.Type() {
+	case ErrTypeInternalServerErr:
+		return internalErrorMessage
+	case ErrTypeUnknown:
+		return unknownErrorMessage
+	}
+	return message(e)
+}
+
+// MarshalJSON serializes the error as problem details
+func (e *baseErr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(NewProblemDetails(e))
+}
@@ -0,0 +1,164 @@
+package errors
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Redacted - the placeholder which replaces sensitive values. Other packages which redact values use it too.
+const Redacted = "[REDACTED]"
+
+// RedactFunc reports whether a struct field or a map key holds sensitive data which must not be logged
+type RedactFunc func(name string) bool
+
+// DefaultRedactFunc treats names containing `password`, `secret` or `token` (case-insensitively) as sensitive
+func DefaultRedactFunc(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "password") || strings.Contains(name, "secret") || strings.Contains(name, "token")
+}
+
+// redactFunc - the RedactFunc in use
+var redactFunc atomic.Value
+
+func init() {
+	redactFunc.Store(RedactFunc(DefaultRedactFunc))
+}
+
+// SetRedactFunc sets the hook which decides the sensitive fields redacted by `Redact`, by the `%+v` output of errors
+// and by the arguments of error messages. Struct fields tagged with `redact:"true"` are always redacted.
+func SetRedactFunc(f RedactFunc) {
+	if f == nil {
+		f = DefaultRedactFunc
+	}
+	redactFunc.Store(f)
+}
+
+// IsSensitive checks whether a struct field or a map key holds sensitive data according to the RedactFunc in use.
+// Other packages which store or log values, e.g. the audit log, use it to redact the same fields.
+func IsSensitive(name string) bool {
+	return redactFunc.Load().(RedactFunc)(name)
+}
+
+// Redact formats a value like `%+v` with its sensitive fields replaced. Use it to log request structs, e.g.
+//
+//	log.Printf("error creating the user %s, err: %+v", errors.Redact(req), err)
+func Redact(v interface{}) string {
+	b := &strings.Builder{}
+	writeRedacted(b, reflect.ValueOf(v))
+	return b.String()
+}
+
+// redactArgs replaces the struct and map arguments of an error message with their redacted forms
+func redactArgs(a []interface{}) []interface{} {
+	var out []interface{}
+	for i, arg := range a {
+		v := reflect.ValueOf(arg)
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct && v.Kind() != reflect.Map {
+			continue
+		}
+		if _, ok := arg.(error); ok {
+			continue
+		}
+		if _, ok := arg.(fmt.Stringer); ok {
+			continue
+		}
+		if out == nil {
+			out = append([]interface{}{}, a...)
+		}
+		out[i] = Redact(arg)
+	}
+	if out == nil {
+		return a
+	}
+	return out
+}
+
+// writeRedacted writes the redacted form of a value
+func writeRedacted(b *strings.Builder, v reflect.Value) {
+	if !v.IsValid() {
+		b.WriteString("<nil>")
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("<nil>")
+			return
+		}
+		if v.Kind() == reflect.Ptr {
+			b.WriteString("&")
+		}
+		writeRedacted(b, v.Elem())
+	case reflect.Struct:
+		if s, ok := stringer(v); ok {
+			fmt.Fprintf(b, "%s", s)
+			return
+		}
+		t := v.Type()
+		b.WriteString("{")
+		for i := 0; i < v.NumField(); i++ {
+			f := t.Field(i)
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			b.WriteString(f.Name + ":")
+			if !f.IsExported() {
+				b.WriteString("?")
+			} else if f.Tag.Get("redact") == "true" || IsSensitive(f.Name) {
+				b.WriteString(Redacted)
+			} else {
+				writeRedacted(b, v.Field(i))
+			}
+		}
+		b.WriteString("}")
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		b.WriteString("map[")
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(b, "%v:", k)
+			if IsSensitive(fmt.Sprint(k)) {
+				b.WriteString(Redacted)
+			} else {
+				writeRedacted(b, v.MapIndex(k))
+			}
+		}
+		b.WriteString("]")
+	case reflect.Slice, reflect.Array:
+		b.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			writeRedacted(b, v.Index(i))
+		}
+		b.WriteString("]")
+	case reflect.String:
+		fmt.Fprintf(b, "%q", v.String())
+	default:
+		if v.CanInterface() {
+			fmt.Fprintf(b, "%v", v.Interface())
+		} else {
+			b.WriteString("?")
+		}
+	}
+}
+
+// stringer returns the value as a `fmt.Stringer` if it implements the interface, e.g. `time.Time`
+func stringer(v reflect.Value) (fmt.Stringer, bool) {
+	if !v.CanInterface() {
+		return nil, false
+	}
+	s, ok := v.Interface().(fmt.Stringer)
+	return s, ok
+}
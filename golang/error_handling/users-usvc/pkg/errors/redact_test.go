@@ -0,0 +1,69 @@
+package errors
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// signUpRequest - a request carrying sensitive fields
+type signUpRequest struct {
+	Email        string
+	Password     string
+	RefreshToken string
+	Code         string `redact:"true"`
+	At           time.Time
+	Profile      *signUpProfile
+}
+
+// signUpProfile - a nested struct carrying sensitive fields
+type signUpProfile struct {
+	Name      string
+	APISecret string
+}
+
+func TestRedact(t *testing.T) {
+	req := &signUpRequest{
+		Email:        "ann@example.com",
+		Password:     "hunter2",
+		RefreshToken: "rt-123",
+		Code:         "424242",
+		At:           time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Profile:      &signUpProfile{Name: "Ann", APISecret: "s3cr3t"},
+	}
+	got := Redact(req)
+	for _, secret := range []string{"hunter2", "rt-123", "424242", "s3cr3t"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("Redact() = %s, which contains %q", got, secret)
+		}
+	}
+	for _, want := range []string{`Email:"ann@example.com"`, "Password:" + Redacted, "2024-01-02 03:04:05", `Name:"Ann"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Redact() = %s, want it to contain %q", got, want)
+		}
+	}
+
+	if got := Redact(map[string]string{"token": "t", "id": "1"}); got != `map[id:"1" token:`+Redacted+`]` {
+		t.Errorf("Redact() of a map = %s", got)
+	}
+}
+
+func TestRedactMessageArgs(t *testing.T) {
+	e := New(ErrTypeBadRequest, "Invalid request %v for %s", &signUpRequest{Email: "ann@example.com", Password: "hunter2"}, "ann")
+	if strings.Contains(e.Error(), "hunter2") || !strings.Contains(e.Error(), "for ann") {
+		t.Errorf("Error() = %q, want the struct argument redacted and the others kept", e.Error())
+	}
+}
+
+func TestSetRedactFunc(t *testing.T) {
+	SetRedactFunc(func(name string) bool { return name == "Email" })
+	defer SetRedactFunc(nil)
+
+	got := Redact(signUpRequest{Email: "ann@example.com", Password: "hunter2"})
+	if strings.Contains(got, "ann@example.com") || !strings.Contains(got, "hunter2") {
+		t.Errorf("Redact() with a custom RedactFunc = %s", got)
+	}
+	if !IsSensitive("Email") || IsSensitive("Password") {
+		t.Error("IsSensitive() does not use the custom RedactFunc")
+	}
+}
@@ -0,0 +1,153 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxStackDepth - the maximum number of frames captured in a stack trace
+const maxStackDepth = 32
+
+// thisPackage - the prefix of the functions in this package, which are skipped when capturing the origin
+var thisPackage = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndex(name, "/")
+	return name[:slash+strings.Index(name[slash:], ".")+1]
+}()
+
+var (
+	// captureStack tells whether errors capture the call stack
+	captureStack atomic.Bool
+	// helpers - names of the functions which are marked by `Helper`
+	helpers sync.Map
+)
+
+// SetCaptureStack enables or disables capturing the call stack when an error is created.
+// The origin of errors (the operation and the line which create them) is always captured.
+// Capturing the stack is relatively expensive, so it is disabled by default.
+func SetCaptureStack(enabled bool) {
+	captureStack.Store(enabled)
+}
+
+// Helper marks the calling function as an error helper, like `testing.T.Helper`. Helpers which wrap the constructors
+// of this package are skipped when capturing the origin of errors, so the origin is the caller of the helper.
+func Helper() {
+	pc, _, _, ok := runtime.Caller(1)
+	if !ok {
+		return
+	}
+	helpers.Store(runtime.FuncForPC(pc).Name(), struct{}{})
+}
+
+// Frame - a frame in the call stack
+type Frame struct {
+	// Function is the fully qualified function name, e.g. `github.com/x/v1.(*manager).Create`
+	Function string
+	File     string
+	Line     int
+}
+
+// String formats the frame as `function (file:line)`
+func (f Frame) String() string {
+	return fmt.Sprintf("%s (%s:%d)", f.Function, f.File, f.Line)
+}
+
+// origin - where an error was created
+type origin struct {
+	// op is the operation which created the error, i.e. the first frame outside this package and helpers
+	op Frame
+	// stack is the call stack starting from op. It is empty unless stack capturing is enabled.
+	stack []Frame
+}
+
+// captureOrigin captures the origin of an error which is being created
+func captureOrigin() *origin {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	o := &origin{}
+	found := false
+	withStack := captureStack.Load()
+	for {
+		frame, more := frames.Next()
+		if !found {
+			_, isHelper := helpers.Load(frame.Function)
+			if !isHelper && !strings.HasPrefix(frame.Function, thisPackage) {
+				found = true
+				o.op = Frame{Function: frame.Function, File: frame.File, Line: frame.Line}
+			}
+		}
+		if found {
+			if !withStack {
+				break
+			}
+			o.stack = append(o.stack, Frame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return o
+}
+
+// Operation returns the operation which created the error, e.g. `github.com/x/v1.(*manager).Create`
+func (e *baseErr) Operation() string {
+	if e != nil && e.origin != nil {
+		return e.origin.op.Function
+	}
+	return ""
+}
+
+// StackTrace returns the call stack captured when the error was created. It is empty unless `SetCaptureStack(true)`.
+func (e *baseErr) StackTrace() []Frame {
+	if e != nil && e.origin != nil {
+		return e.origin.stack
+	}
+	return nil
+}
+
+// Format implements `fmt.Formatter`. `%s` and `%v` print the error message, while `%+v` also prints the error code,
+// the origin, the stack trace if it is captured, the metadata and the cause. Sensitive metadata is redacted.
+// `%#v` prints the same as `%v` so that internal fields are never dumped.
+func (e *baseErr) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			e.writeDetailed(s)
+			return
+		}
+		io.WriteString(s, e.Error())
+	case 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	default:
+		fmt.Fprintf(s, "%%!%c(%s)", verb, e.Error())
+	}
+}
+
+// writeDetailed writes the detailed form of the error used by `%+v`
+func (e *baseErr) writeDetailed(w io.Writer) {
+	if e == nil {
+		return
+	}
+	fmt.Fprintf(w, "%s [%s]", e.msg, e.Code())
+	if e.origin != nil {
+		fmt.Fprintf(w, "\n    op: %s", e.origin.op)
+		for _, f := range e.origin.stack {
+			fmt.Fprintf(w, "\n        %s", f)
+		}
+	}
+	if len(e.metadata) > 0 {
+		fmt.Fprintf(w, "\n    metadata: %s", Redact(e.metadata))
+	}
+	if e.cause != nil {
+		fmt.Fprintf(w, "\ncaused by: %+v", e.cause)
+	}
+}
@@ -0,0 +1,76 @@
+package errors_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// The origin of errors is the first frame outside the errors package, so these tests live outside it
+
+// newTestError creates an error through a helper, like the error helpers of other packages
+func newTestError(format string, a ...interface{}) usvcErrors.Error {
+	usvcErrors.Helper()
+	return usvcErrors.New(usvcErrors.ErrTypeConflict, format, a...)
+}
+
+// operation returns the operation which created the error
+func operation(err usvcErrors.Error) string {
+	return err.(interface{ Operation() string }).Operation()
+}
+
+// stackTrace returns the stack trace captured when the error was created
+func stackTrace(err usvcErrors.Error) []usvcErrors.Frame {
+	return err.(interface{ StackTrace() []usvcErrors.Frame }).StackTrace()
+}
+
+func TestErrorOrigin(t *testing.T) {
+	const want = "errors_test.TestErrorOrigin"
+	if op := operation(usvcErrors.New(usvcErrors.ErrTypeNotFound, "not found")); !strings.HasSuffix(op, want) {
+		t.Errorf("Operation() = %s, want %s", op, want)
+	}
+	// Helpers are skipped so the origin is the caller of the helper
+	if op := operation(newTestError("conflict")); !strings.HasSuffix(op, want) {
+		t.Errorf("Operation() of an error created by a helper = %s, want %s", op, want)
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	if stack := stackTrace(usvcErrors.New(usvcErrors.ErrTypeNotFound, "not found")); len(stack) != 0 {
+		t.Errorf("StackTrace() with stack capturing disabled = %v, want none", stack)
+	}
+
+	usvcErrors.SetCaptureStack(true)
+	defer usvcErrors.SetCaptureStack(false)
+	stack := stackTrace(newTestError("conflict"))
+	if len(stack) < 2 {
+		t.Fatalf("StackTrace() = %v, want the frames of the test and its callers", stack)
+	}
+	if !strings.HasSuffix(stack[0].Function, "errors_test.TestStackTrace") || stack[0].Line == 0 {
+		t.Errorf("StackTrace()[0] = %s, want the frame of the test", stack[0])
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	err := usvcErrors.Wrap(errors.New("connection refused"), usvcErrors.NewCoded(usvcErrors.ErrTypeUnavailable, "db_down",
+		map[string]string{"db": "users", "db_password": "hunter2"}, "The database is down."))
+
+	for _, verb := range []string{"%v", "%s", "%#v"} {
+		if got := fmt.Sprintf(verb, err); got != err.Error() {
+			t.Errorf("Sprintf(%s) = %q, want %q", verb, got, err.Error())
+		}
+	}
+
+	detailed := fmt.Sprintf("%+v", err)
+	for _, want := range []string{"The database is down. [db_down]", "op: ", "TestFormatError", "db:\"users\"", "caused by: connection refused"} {
+		if !strings.Contains(detailed, want) {
+			t.Errorf("Sprintf(%%+v) = %q, want it to contain %q", detailed, want)
+		}
+	}
+	if strings.Contains(detailed, "hunter2") {
+		t.Errorf("Sprintf(%%+v) = %q, which contains a sensitive value", detailed)
+	}
+}
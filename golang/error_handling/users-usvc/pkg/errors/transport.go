@@ -0,0 +1,163 @@
+package errors
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StatusClientClosedRequest - the non-standard HTTP status code used when the client closes the request
+// before the response is written
+const StatusClientClosedRequest = 499
+
+// errTypeInfo - how an error type is represented on the transports
+type errTypeInfo struct {
+	// title is a short, human-readable summary used in problem details
+	title string
+	// httpStatus is the HTTP status code
+	httpStatus int
+	// grpcCode is the gRPC status code
+	grpcCode codes.Code
+	// retryable tells whether errors of this type are retryable by default
+	retryable bool
+	// httpOnly marks the types which only HTTP tells apart from a more general type. They share the gRPC code of
+	// that type, which is not mapped back to them.
+	httpOnly bool
+}
+
+// errTypeInfos - the catalogue of error types. A new error type only needs to be added here (and to the constants).
+// The mapping from HTTP status codes and gRPC codes back to error types is derived from this table,
+// so each status code and gRPC code should appear once, except the gRPC codes of `httpOnly` types.
+var errTypeInfos = map[ErrType]errTypeInfo{
+	ErrTypeBadRequest:           {title: "Bad request", httpStatus: http.StatusBadRequest, grpcCode: codes.InvalidArgument},
+	ErrTypeUnauthorized:         {title: "Unauthorized", httpStatus: http.StatusUnauthorized, grpcCode: codes.Unauthenticated},
+	ErrTypeForbidden:            {title: "Forbidden", httpStatus: http.StatusForbidden, grpcCode: codes.PermissionDenied},
+	ErrTypeNotFound:             {title: "Resource not found", httpStatus: http.StatusNotFound, grpcCode: codes.NotFound},
+	ErrTypeConflict:             {title: "Resource conflict", httpStatus: http.StatusConflict, grpcCode: codes.AlreadyExists},
+	ErrTypeMethodNotAllowed:     {title: "Method not allowed", httpStatus: http.StatusMethodNotAllowed, grpcCode: codes.Unimplemented},
+	ErrTypePayloadTooLarge:      {title: "Payload too large", httpStatus: http.StatusRequestEntityTooLarge, grpcCode: codes.InvalidArgument, httpOnly: true},
+	ErrTypeUnsupportedMediaType: {title: "Unsupported media type", httpStatus: http.StatusUnsupportedMediaType, grpcCode: codes.InvalidArgument, httpOnly: true},
+	ErrTypeRateLimited:          {title: "Too many requests", httpStatus: http.StatusTooManyRequests, grpcCode: codes.ResourceExhausted, retryable: true},
+	ErrTypeInternalServerErr:    {title: "Internal server error", httpStatus: http.StatusInternalServerError, grpcCode: codes.Internal, retryable: true},
+	ErrTypeUnavailable:          {title: "Service unavailable", httpStatus: http.StatusServiceUnavailable, grpcCode: codes.Unavailable, retryable: true},
+	ErrTypeTimeout:              {title: "Request timeout", httpStatus: http.StatusGatewayTimeout, grpcCode: codes.DeadlineExceeded, retryable: true},
+	ErrTypeCanceled:             {title: "Request canceled", httpStatus: StatusClientClosedRequest, grpcCode: codes.Canceled},
+	ErrTypeUnknown:              {title: "Unknown error", httpStatus: http.StatusInternalServerError, grpcCode: codes.Unknown},
+}
+
+// Reverse mappings derived from errTypeInfos
+var (
+	httpStatusErrTypes = map[int]ErrType{}
+	grpcCodeErrTypes   = map[codes.Code]ErrType{}
+)
+
+func init() {
+	for errType, info := range errTypeInfos {
+		if errType == ErrTypeUnknown {
+			// Unknown errors share the status code with internal server errors
+			continue
+		}
+		httpStatusErrTypes[info.httpStatus] = errType
+		if !info.httpOnly {
+			grpcCodeErrTypes[info.grpcCode] = errType
+		}
+	}
+}
+
+// info returns how the error type is represented on the transports. Unsupported types are treated as unknown.
+func (e ErrType) info() errTypeInfo {
+	if info, ok := errTypeInfos[e]; ok {
+		return info
+	}
+	return errTypeInfos[ErrTypeUnknown]
+}
+
+// Title - return the short, human-readable summary of the error type
+func (e ErrType) Title() string {
+	return e.info().title
+}
+
+// ErrTypes returns the supported error types, sorted by their HTTP status codes
+func ErrTypes() []ErrType {
+	errTypes := make([]ErrType, 0, len(errTypeInfos))
+	for errType := range errTypeInfos {
+		errTypes = append(errTypes, errType)
+	}
+	sort.Slice(errTypes, func(i, j int) bool {
+		si, sj := errTypes[i].HTTPStatusCode(), errTypes[j].HTTPStatusCode()
+		return si < sj || (si == sj && errTypes[i] < errTypes[j])
+	})
+	return errTypes
+}
+
+// HTTPStatusCode - return http status code
+func (e ErrType) HTTPStatusCode() int {
+	return e.info().httpStatus
+}
+
+// GRPCCode - return gRPC status code
+func (e ErrType) GRPCCode() codes.Code {
+	return e.info().grpcCode
+}
+
+// ErrTypeFromHTTPStatus returns the error type of an HTTP status code.
+// Unmapped 4xx codes are treated as bad requests and other codes as unknown errors.
+func ErrTypeFromHTTPStatus(code int) ErrType {
+	if errType, ok := httpStatusErrTypes[code]; ok {
+		return errType
+	}
+	if code >= 400 && code < 500 {
+		return ErrTypeBadRequest
+	}
+	return ErrTypeUnknown
+}
+
+// ErrTypeFromGRPCCode returns the error type of a gRPC status code
+func ErrTypeFromGRPCCode(code codes.Code) ErrType {
+	if errType, ok := grpcCodeErrTypes[code]; ok {
+		return errType
+	}
+	return ErrTypeUnknown
+}
+
+// GRPCStatus converts the error to a gRPC status. `status.FromError` uses it, so gRPC servers can return errors
+// of this package as is. The message is the one of `NewProblemDetails`, so internal details do not reach clients.
+func (e *baseErr) GRPCStatus() *status.Status {
+	return status.New(e.Type().GRPCCode(), publicMessage(e))
+}
+
+// FromHTTPResponse rebuilds an Error from the status code and the body of a response returned by a remote service.
+// The body is expected to be problem details, either as is or in the `error` member of the response envelope;
+// otherwise the error type is derived from the status code.
+func FromHTTPResponse(statusCode int, body []byte) Error {
+	envelope := &struct {
+		Error *ProblemDetails `json:"error"`
+	}{}
+	p := &ProblemDetails{}
+	if err := json.Unmarshal(body, envelope); err == nil && envelope.Error != nil {
+		p = envelope.Error
+	} else if err := json.Unmarshal(body, p); err != nil {
+		p.ErrType = ""
+	}
+	if p.ErrType == "" {
+		errType := ErrTypeFromHTTPStatus(statusCode)
+		return New(errType, "Remote service responded with %d %s.", statusCode, http.StatusText(statusCode))
+	}
+
+	return typed(&baseErr{
+		msg:        p.Detail,
+		errType:    p.ErrType,
+		code:       p.Code,
+		violations: p.InvalidParams,
+		retryable:  p.Retryable,
+		metadata:   p.Metadata,
+	})
+}
+
+// FromGRPCStatus rebuilds an Error from a gRPC status returned by a remote service
+func FromGRPCStatus(st *status.Status) Error {
+	return New(ErrTypeFromGRPCCode(st.Code()), "%s", st.Message())
+}
@@ -0,0 +1,131 @@
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrTypeMappings(t *testing.T) {
+	for errType := range errTypeInfos {
+		if errType == ErrTypeUnknown {
+			continue
+		}
+		if got := ErrTypeFromHTTPStatus(errType.HTTPStatusCode()); got != errType {
+			t.Errorf("ErrTypeFromHTTPStatus(%d) = %s, want %s", errType.HTTPStatusCode(), got, errType)
+		}
+		if errTypeInfos[errType].httpOnly {
+			continue
+		}
+		if got := ErrTypeFromGRPCCode(errType.GRPCCode()); got != errType {
+			t.Errorf("ErrTypeFromGRPCCode(%s) = %s, want %s", errType.GRPCCode(), got, errType)
+		}
+	}
+
+	tests := []struct {
+		status int
+		want   ErrType
+	}{
+		{http.StatusTooManyRequests, ErrTypeRateLimited},
+		{http.StatusRequestEntityTooLarge, ErrTypePayloadTooLarge},
+		{http.StatusTeapot, ErrTypeBadRequest},
+		{http.StatusBadGateway, ErrTypeUnknown},
+	}
+	for _, tt := range tests {
+		if got := ErrTypeFromHTTPStatus(tt.status); got != tt.want {
+			t.Errorf("ErrTypeFromHTTPStatus(%d) = %s, want %s", tt.status, got, tt.want)
+		}
+	}
+	if got := ErrTypeFromGRPCCode(codes.InvalidArgument); got != ErrTypeBadRequest {
+		t.Errorf("ErrTypeFromGRPCCode(InvalidArgument) = %s, want %s", got, ErrTypeBadRequest)
+	}
+	if got := ErrType("made_up").HTTPStatusCode(); got != http.StatusInternalServerError {
+		t.Errorf("HTTPStatusCode() of an unsupported type = %d, want 500", got)
+	}
+}
+
+func TestNewProblemDetails(t *testing.T) {
+	err := NewCoded(ErrTypeConflict, "email_taken", map[string]string{"email": "ann@example.com"}, "The email has been used.")
+	p := NewProblemDetails(Wrap(errors.New("duplicate key"), err))
+	if p.Type != ProblemTypeBaseURI+"email_taken" || p.Status != http.StatusConflict || p.Title != "Resource conflict" {
+		t.Errorf("NewProblemDetails() = %+v", p)
+	}
+	// The cause is meant for logs only
+	if p.Detail != "The email has been used." || p.Metadata["email"] != "ann@example.com" {
+		t.Errorf("NewProblemDetails().Detail = %q, metadata %v", p.Detail, p.Metadata)
+	}
+
+	b, _ := json.Marshal(NewValidation([]FieldViolation{{Field: "email", Description: "The email is required."}}))
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(b, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["code"] != CodeInvalidFields || body["invalid-params"] == nil || body["status"] != float64(http.StatusBadRequest) {
+		t.Errorf("MarshalJSON() = %s", b)
+	}
+}
+
+func TestInternalMessagesAreHidden(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{New(ErrTypeInternalServerErr, "Error querying users WHERE email = %s", "ann@example.com"), internalErrorMessage},
+		{New(ErrTypeUnknown, "Error from the payment gateway"), unknownErrorMessage},
+		{errors.New("dial tcp 10.0.0.1:3306: connection refused"), unknownErrorMessage},
+	}
+	for _, tt := range tests {
+		if got := NewProblemDetails(tt.err).Detail; got != tt.want {
+			t.Errorf("NewProblemDetails(%q).Detail = %q, want %q", tt.err, got, tt.want)
+		}
+		if e, ok := tt.err.(interface{ GRPCStatus() *status.Status }); ok {
+			if got := e.GRPCStatus().Message(); got != tt.want {
+				t.Errorf("GRPCStatus(%q).Message() = %q, want %q", tt.err, got, tt.want)
+			}
+		}
+	}
+
+	// `status.FromError` finds the status of typed errors too
+	st, ok := status.FromError(NewInternelServerErr("Error connecting to 10.0.0.1"))
+	if !ok || st.Code() != codes.Internal || st.Message() != internalErrorMessage {
+		t.Errorf("status.FromError() = %v, %v", st, ok)
+	}
+	st, _ = status.FromError(NewNotFoundErr("The user u1 does not exist."))
+	if st.Code() != codes.NotFound || st.Message() != "The user u1 does not exist." {
+		t.Errorf("status.FromError() of a not found error = %v", st)
+	}
+}
+
+func TestFromHTTPResponse(t *testing.T) {
+	problem := `{"type":"/problems/email_taken","code":"email_taken","error_type":"conflict","detail":"taken","metadata":{"email":"a@b.c"}}`
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantType ErrType
+		wantCode string
+	}{
+		{"problem details", http.StatusConflict, problem, ErrTypeConflict, "email_taken"},
+		{"plain text", http.StatusServiceUnavailable, "upstream down", ErrTypeUnavailable, string(ErrTypeUnavailable)},
+		{"JSON without problem details", http.StatusNotFound, `{"message":"no route"}`, ErrTypeNotFound, string(ErrTypeNotFound)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := FromHTTPResponse(tt.status, []byte(tt.body))
+			if e.Type() != tt.wantType || e.Code() != tt.wantCode {
+				t.Errorf("FromHTTPResponse() = %s/%s, want %s/%s", e.Type(), e.Code(), tt.wantType, tt.wantCode)
+			}
+		})
+	}
+
+	if _, ok := FromHTTPResponse(http.StatusConflict, []byte(problem)).(*ConflictErr); !ok {
+		t.Error("FromHTTPResponse() of a conflict is not *ConflictErr")
+	}
+	if e := FromGRPCStatus(status.New(codes.AlreadyExists, "taken")); e.Type() != ErrTypeConflict || e.Error() != "taken" {
+		t.Errorf("FromGRPCStatus() = %s/%q", e.Type(), e.Error())
+	}
+}
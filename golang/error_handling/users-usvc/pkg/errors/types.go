@@ -0,0 +1,89 @@
+package errors
+
+// Error type structs. Errors of these types created by `New`, `NewCoded` and `NewValidation` are returned as
+// pointers to these structs, so callers can do the error handling with either `Type()` or a type switch:
+//
+//	switch err.(type) {
+//	case *errors.BadRequestErr:
+//		...
+//	}
+
+// BadRequestErr represents bad request errors
+type BadRequestErr struct {
+	*baseErr
+}
+
+// ConflictErr represents resource conflict errors
+type ConflictErr struct {
+	*baseErr
+}
+
+// NotFoundErr represents resource not found errors
+type NotFoundErr struct {
+	*baseErr
+}
+
+// UnauthorizedErr represents errors caused by missing or invalid credentials
+type UnauthorizedErr struct {
+	*baseErr
+}
+
+// ForbiddenErr represents errors caused by callers which are not allowed to perform the operation
+type ForbiddenErr struct {
+	*baseErr
+}
+
+// InternelServerErr represents internal server errors
+type InternelServerErr struct {
+	*baseErr
+}
+
+// NewBadRequestErr creates an instance of BadRequestErr
+func NewBadRequestErr(format string, a ...interface{}) error {
+	return New(ErrTypeBadRequest, format, a...)
+}
+
+// NewConflictErr creates an instance of ConflictErr
+func NewConflictErr(format string, a ...interface{}) error {
+	return New(ErrTypeConflict, format, a...)
+}
+
+// NewNotFoundErr creates an instance of NotFoundErr
+func NewNotFoundErr(format string, a ...interface{}) error {
+	return New(ErrTypeNotFound, format, a...)
+}
+
+// NewUnauthorizedErr creates an instance of UnauthorizedErr
+func NewUnauthorizedErr(format string, a ...interface{}) error {
+	return New(ErrTypeUnauthorized, format, a...)
+}
+
+// NewForbiddenErr creates an instance of ForbiddenErr
+func NewForbiddenErr(format string, a ...interface{}) error {
+	return New(ErrTypeForbidden, format, a...)
+}
+
+// NewInternelServerErr creates an instance of InternelServerErr
+func NewInternelServerErr(format string, a ...interface{}) error {
+	return New(ErrTypeInternalServerErr, format, a...)
+}
+
+// typed wraps the base implementation in the struct of its error type. Errors of other types are returned as is.
+func typed(e *baseErr) Error {
+	switch e.errType {
+	case ErrTypeBadRequest:
+		return &BadRequestErr{baseErr: e}
+	case ErrTypeConflict:
+		return &ConflictErr{baseErr: e}
+	case ErrTypeNotFound:
+		return &NotFoundErr{baseErr: e}
+	case ErrTypeUnauthorized:
+		return &UnauthorizedErr{baseErr: e}
+	case ErrTypeForbidden:
+		return &ForbiddenErr{baseErr: e}
+	case ErrTypeInternalServerErr:
+		return &InternelServerErr{baseErr: e}
+	default:
+		return e
+	}
+}
@@ -0,0 +1,248 @@
+// Package openapi builds OpenAPI 3 documents of the HTTP APIs. Schemas are generated from the request and response
+// types of handlers, and `Verify` checks that a document and the routes of a router describe the same endpoints.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+	usvcResponse "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/response"
+)
+
+// Version - the version of the OpenAPI specification which documents follow
+const Version = "3.0.3"
+
+// Document - an OpenAPI document
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components *Components          `json:"components,omitempty"`
+}
+
+// Info - the metadata of the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem - the operations on a path, keyed by lower case HTTP methods
+type PathItem map[string]*Operation
+
+// Operation - an endpoint
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter - a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody - the body of requests
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// MediaType - the schema of a body in a media type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Response - a response of an operation, or a reference to a response in the components
+type Response struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// Header - a response header
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Components - the objects which are referenced by operations
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	Responses       map[string]*Response       `json:"responses,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme - how requests are authenticated
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Names of the objects in the components added by `NewDocument`
+const (
+	// SchemaProblem - the schema of problem details
+	SchemaProblem = "Problem"
+	// SecurityBearer - the security scheme of bearer access tokens
+	SecurityBearer = "bearer"
+)
+
+// NewDocument creates a document with the components shared by the APIs: the problem details schema, one response
+// per HTTP status code of the error types, and the bearer security scheme
+func NewDocument(title, version string) *Document {
+	problem := SchemaOf(usvcErrors.ProblemDetails{})
+	errTypes := make([]string, 0)
+	responses := map[string]*Response{}
+	for _, errType := range usvcErrors.ErrTypes() {
+		errTypes = append(errTypes, string(errType))
+
+		// Error types sharing a status code share a response, e.g. internal server errors and unknown errors
+		name := strconv.Itoa(errType.HTTPStatusCode())
+		if resp, ok := responses[name]; ok {
+			resp.Description += ", " + errType.Title()
+			continue
+		}
+		responses[name] = &Response{
+			Description: errType.Title(),
+			Content: map[string]*MediaType{"application/json": {Schema: Object(map[string]*Schema{
+				"error": Ref(SchemaProblem),
+			}, "error")}},
+		}
+	}
+	problem.Properties["error_type"].Enum = errTypes
+
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version},
+		Paths:   map[string]*PathItem{},
+		Components: &Components{
+			Schemas:         map[string]*Schema{SchemaProblem: problem},
+			Responses:       responses,
+			SecuritySchemes: map[string]*SecurityScheme{SecurityBearer: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"}},
+		},
+	}
+}
+
+// Add adds the operation on the path. `errTypes` are the error types the operation may return, which are documented
+// as references to the responses in the components. Operations with a request body may also return payload too
+// large and unsupported media type errors, which are added for them.
+func (d *Document) Add(method, path string, op *Operation, errTypes ...usvcErrors.ErrType) {
+	if op.Responses == nil {
+		op.Responses = map[string]*Response{}
+	}
+	if op.RequestBody != nil {
+		// Bodies are rejected if they are too large or not in a media type of the operation, see `request.Decode`
+		errTypes = append(errTypes[:len(errTypes):len(errTypes)], usvcErrors.ErrTypePayloadTooLarge, usvcErrors.ErrTypeUnsupportedMediaType)
+	}
+	for _, errType := range errTypes {
+		name := strconv.Itoa(errType.HTTPStatusCode())
+		op.Responses[name] = &Response{Ref: "#/components/responses/" + name}
+	}
+
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	(*item)[strings.ToLower(method)] = op
+}
+
+// Verify checks that the document describes exactly the routes of the router. Routes which do not restrict methods,
+// e.g. the path prefixes of subrouters, are skipped.
+func Verify(r *mux.Router, d *Document) error {
+	routes := map[string]bool{}
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			routes[method+" "+path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking the routes, err: %s", err.Error())
+	}
+
+	documented := map[string]bool{}
+	for path, item := range d.Paths {
+		for method := range *item {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	var drifts []string
+	for route := range routes {
+		if !documented[route] {
+			drifts = append(drifts, fmt.Sprintf("%s is not documented", route))
+		}
+	}
+	for route := range documented {
+		if !routes[route] {
+			drifts = append(drifts, fmt.Sprintf("%s is documented but not routed", route))
+		}
+	}
+	if len(drifts) > 0 {
+		sort.Strings(drifts)
+		return fmt.Errorf("the OpenAPI document has drifted from the routes: %s", strings.Join(drifts, "; "))
+	}
+	return nil
+}
+
+// Handler returns the handler which serves the document
+func Handler(d *Document) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		usvcResponse.JSON(w, http.StatusOK, d)
+	}
+}
+
+// JSONContent returns the content of a JSON body with the schema
+func JSONContent(s *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: s}}
+}
+
+// JSONBody returns the required JSON request body of the Go value
+func JSONBody(v interface{}) *RequestBody {
+	return &RequestBody{Required: true, Content: JSONContent(SchemaOf(v))}
+}
+
+// DataResponse returns the response whose envelope carries the Go value as its data, see package `response`
+func DataResponse(description string, v interface{}) *Response {
+	return &Response{
+		Description: description,
+		Content:     JSONContent(Object(map[string]*Schema{"data": SchemaOf(v)}, "data")),
+	}
+}
+
+// PageResponse returns the response whose envelope carries a page of the Go values and the pagination metadata
+func PageResponse(description string, v interface{}) *Response {
+	return &Response{
+		Description: description,
+		Content: JSONContent(Object(map[string]*Schema{
+			"data": {Type: "array", Items: SchemaOf(v)},
+			"meta": SchemaOf(usvcResponse.Meta{}),
+		}, "data")),
+	}
+}
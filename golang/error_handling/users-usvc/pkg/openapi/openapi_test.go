@@ -0,0 +1,41 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestVerify(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	newRouter := func() *mux.Router {
+		r := mux.NewRouter()
+		r.Handle("/users/v1/", h).Methods(http.MethodGet, http.MethodPost)
+		// Path prefixes without methods are skipped
+		r.PathPrefix("/static/").Handler(h)
+		return r
+	}
+	newDocument := func() *Document {
+		d := NewDocument("test", "1.0.0")
+		d.Add(http.MethodGet, "/users/v1/", &Operation{OperationID: "listUsers"})
+		d.Add(http.MethodPost, "/users/v1/", &Operation{OperationID: "createUser"})
+		return d
+	}
+
+	if err := Verify(newRouter(), newDocument()); err != nil {
+		t.Errorf("Verify() err: %v, want nil", err)
+	}
+
+	r := newRouter()
+	r.Handle("/users/v1/{id}", h).Methods(http.MethodDelete)
+	d := newDocument()
+	d.Add(http.MethodGet, "/users/v1/{id}", &Operation{OperationID: "getUser"})
+	err := Verify(r, d)
+	for _, want := range []string{"DELETE /users/v1/{id} is not documented", "GET /users/v1/{id} is documented but not routed"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Verify() err: %v, want %q", err, want)
+		}
+	}
+}
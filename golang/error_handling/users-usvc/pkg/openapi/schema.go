@@ -0,0 +1,161 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema - the schema of a JSON value, or a reference to a schema in the components
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	MinItems    *int               `json:"minItems,omitempty"`
+	MaxItems    *int               `json:"maxItems,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty"`
+
+	AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+}
+
+// Ref returns a reference to the schema with the given name in the components
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Object returns the schema of an object with the given properties
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required}
+}
+
+// String returns the schema of a string
+func String(description string) *Schema {
+	return &Schema{Type: "string", Description: description}
+}
+
+// SchemaOf generates the schema of the Go value from its type. Struct fields are named after their `json` tags, and
+// the rules in their `validate` tags (see package `request`) become the constraints of the schema.
+func SchemaOf(v interface{}) *Schema {
+	return schemaOf(reflect.TypeOf(v))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf generates the schema of the Go type
+func schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	if t.Kind() == reflect.Ptr {
+		return schemaOf(t.Elem())
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	// Interfaces can hold any value
+	return &Schema{}
+}
+
+// structSchema generates the schema of the struct type
+func structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && name == "" {
+			// The fields of embedded structs are promoted
+			embedded := schemaOf(sf.Type)
+			for n, p := range embedded.Properties {
+				s.Properties[n] = p
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		p := schemaOf(sf.Type)
+		if sf.Type.Kind() == reflect.Ptr && !strings.Contains(opts, "omitempty") {
+			p.Nullable = true
+		}
+		if applyRules(p, sf.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = p
+	}
+	return s
+}
+
+// applyRules applies the rules in a `validate` tag to the schema and returns whether the field is required
+func applyRules(s *Schema, tag string) bool {
+	required := false
+	if tag == "" {
+		return required
+	}
+	for _, r := range strings.Split(tag, ",") {
+		key, arg, _ := strings.Cut(strings.TrimSpace(r), "=")
+		switch key {
+		case "required":
+			required = true
+			if s.Type == "string" && s.MinLength == nil {
+				s.MinLength = intPtr(1)
+			}
+		case "min", "max":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				continue
+			}
+			switch {
+			case s.Type == "string" && key == "min":
+				s.MinLength = intPtr(n)
+			case s.Type == "string":
+				s.MaxLength = intPtr(n)
+			case s.Type == "array" && key == "min":
+				s.MinItems = intPtr(n)
+			case s.Type == "array":
+				s.MaxItems = intPtr(n)
+			}
+		case "oneof":
+			s.Enum = strings.Fields(arg)
+		}
+	}
+	return required
+}
+
+// intPtr returns a pointer to the int
+func intPtr(n int) *int {
+	return &n
+}
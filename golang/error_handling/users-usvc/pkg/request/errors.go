@@ -0,0 +1,27 @@
+package request
+
+import (
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// Error codes which are more specific than error types
+const (
+	// CodeUnsupportedMediaType - the `Content-Type` of the request is not JSON
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	// CodeBodyTooLarge - the request body exceeds the maximum size
+	CodeBodyTooLarge = "request_body_too_large"
+	// CodeMalformedBody - the request body is empty or not valid JSON
+	CodeMalformedBody = "malformed_body"
+)
+
+// newCodedError returns an error with given error type, code and optional metadata
+func newCodedError(errType usvcErrors.ErrType, code string, metadata map[string]string, format string, a ...interface{}) usvcErrors.Error {
+	usvcErrors.Helper()
+	return usvcErrors.NewCoded(errType, code, metadata, format, a...)
+}
+
+// newValidationError returns a bad request error which carries the given field violations
+func newValidationError(violations []usvcErrors.FieldViolation) usvcErrors.Error {
+	usvcErrors.Helper()
+	return usvcErrors.NewValidation(violations)
+}
@@ -0,0 +1,129 @@
+// Package request decodes the JSON bodies of HTTP requests. Bodies are decoded strictly, i.e. with a size limit and
+// without unknown fields, and validated against the schema declared in the `validate` tags of the target struct:
+//
+//	req := &struct {
+//		Email string `json:"email" validate:"required,max=254"`
+//	}{}
+//	if err := request.Decode(w, r, req); err != nil {
+//		response.Error(w, r, err)
+//		return
+//	}
+//
+// Invalid fields are reported as field violations, in the same format as the validation errors of managers.
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// DefaultMaxBodyBytes - the maximum size of request bodies by default
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+// options - the settings of decoding a request
+type options struct {
+	maxBodyBytes int64
+}
+
+// Option configures optional settings of decoding a request
+type Option func(o *options)
+
+// WithMaxBodyBytes sets the maximum size of the request body. It is 1 MiB if it is not set.
+func WithMaxBodyBytes(n int64) Option {
+	return func(o *options) {
+		o.maxBodyBytes = n
+	}
+}
+
+// Decode decodes the JSON body of the request into `v`, which should be a pointer to a struct, and validates it
+// against the schema in its `validate` tags. The returned errors are bad request errors, except unsupported media
+// type errors for bodies which are not JSON and payload too large errors for bodies over the maximum size.
+func Decode(w http.ResponseWriter, r *http.Request, v interface{}, opts ...Option) error {
+	o := &options{maxBodyBytes: DefaultMaxBodyBytes}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return newCodedError(usvcErrors.ErrTypeUnsupportedMediaType, CodeUnsupportedMediaType, nil,
+			"The Content-Type of the request must be application/json.")
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, o.maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err, o)
+	}
+	// The body must hold exactly one JSON value
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		if err == nil {
+			return newCodedError(usvcErrors.ErrTypeBadRequest, CodeMalformedBody, nil, "The request body must contain a single JSON object.")
+		}
+		return decodeError(err, o)
+	}
+
+	if violations := Validate(v); len(violations) > 0 {
+		return newValidationError(violations)
+	}
+	return nil
+}
+
+// decodeError converts an error returned by the JSON decoder to a bad request or payload too large error
+func decodeError(err error, o *options) error {
+	var (
+		maxBytesErr  *http.MaxBytesError
+		syntaxErr    *json.SyntaxError
+		unmarshalErr *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return newCodedError(usvcErrors.ErrTypePayloadTooLarge, CodeBodyTooLarge, map[string]string{"max_bytes": strconv.FormatInt(o.maxBodyBytes, 10)},
+			"The request body must not exceed %d bytes.", o.maxBodyBytes)
+	case errors.Is(err, io.EOF):
+		return newCodedError(usvcErrors.ErrTypeBadRequest, CodeMalformedBody, nil, "The request body is empty.")
+	case errors.As(err, &syntaxErr):
+		return newCodedError(usvcErrors.ErrTypeBadRequest, CodeMalformedBody, nil, "The request body is not valid JSON at offset %d: %s.", syntaxErr.Offset, syntaxErr.Error())
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return newCodedError(usvcErrors.ErrTypeBadRequest, CodeMalformedBody, nil, "The request body is truncated.")
+	case errors.As(err, &unmarshalErr):
+		if unmarshalErr.Field == "" {
+			return newCodedError(usvcErrors.ErrTypeBadRequest, CodeMalformedBody, nil, "The request body must be a JSON %s.", jsonType(unmarshalErr.Type.Kind().String()))
+		}
+		return newValidationError([]usvcErrors.FieldViolation{{
+			Field:       unmarshalErr.Field,
+			Description: "The field must be a JSON " + jsonType(unmarshalErr.Type.Kind().String()) + ".",
+		}})
+	}
+
+	// The decoder has no error type for unknown fields
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return newValidationError([]usvcErrors.FieldViolation{{
+			Field:       strings.Trim(field, `"`),
+			Description: "The field is not supported.",
+		}})
+	}
+	return newCodedError(usvcErrors.ErrTypeBadRequest, CodeMalformedBody, nil, "Error decoding the request body, err: %s", err.Error())
+}
+
+// jsonType returns the JSON type which Go values of the given kind are decoded from
+func jsonType(kind string) string {
+	switch {
+	case kind == "string":
+		return "string"
+	case kind == "bool":
+		return "boolean"
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "slice", kind == "array":
+		return "array"
+	}
+	return "object"
+}
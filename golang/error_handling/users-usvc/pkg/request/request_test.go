@@ -0,0 +1,121 @@
+package request
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// testRequest - a request body with every kind of rule
+type testRequest struct {
+	Name string   `json:"name" validate:"required,max=3"`
+	Kind *string  `json:"kind" validate:"oneof=a b"`
+	Age  int      `json:"age"`
+	Tags []string `json:"tags" validate:"max=1"`
+}
+
+// decode decodes the body with the given content type into a testRequest, with bodies limited to 50 bytes
+func decode(contentType, body string) (*testRequest, error) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	req := &testRequest{}
+	return req, Decode(httptest.NewRecorder(), r, req, WithMaxBodyBytes(50))
+}
+
+func TestDecode(t *testing.T) {
+	req, err := decode("application/json; charset=utf-8", `{"name":"ann","kind":"a","age":3,"tags":["x"]}`)
+	if err != nil {
+		t.Fatalf("Decode() err: %v", err)
+	}
+	if req.Name != "ann" || req.Kind == nil || *req.Kind != "a" || req.Age != 3 || len(req.Tags) != 1 {
+		t.Errorf("Decode() = %+v, want every field decoded", req)
+	}
+	if _, err := decode("application/merge-patch+json", `{"name":"ann"}`); err != nil {
+		t.Errorf("Decode() of a +json media type err: %v", err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name, contentType, body string
+		wantType                usvcErrors.ErrType
+		wantCode                string
+		wantFields              []string
+	}{
+		{"not JSON", "text/plain", `{"name":"ann"}`, usvcErrors.ErrTypeUnsupportedMediaType, CodeUnsupportedMediaType, nil},
+		{"no content type", "", `{"name":"ann"}`, usvcErrors.ErrTypeUnsupportedMediaType, CodeUnsupportedMediaType, nil},
+		{"too large", "application/json", `{"name":"` + strings.Repeat("a", 100) + `"}`, usvcErrors.ErrTypePayloadTooLarge, CodeBodyTooLarge, nil},
+		{"empty", "application/json", ``, usvcErrors.ErrTypeBadRequest, CodeMalformedBody, nil},
+		{"truncated", "application/json", `{"name":`, usvcErrors.ErrTypeBadRequest, CodeMalformedBody, nil},
+		{"invalid JSON", "application/json", `{name}`, usvcErrors.ErrTypeBadRequest, CodeMalformedBody, nil},
+		{"not an object", "application/json", `[1]`, usvcErrors.ErrTypeBadRequest, CodeMalformedBody, nil},
+		{"two values", "application/json", `{"name":"ann"} {}`, usvcErrors.ErrTypeBadRequest, CodeMalformedBody, nil},
+		{"unknown field", "application/json", `{"name":"ann","phone":"1"}`, usvcErrors.ErrTypeBadRequest, usvcErrors.CodeInvalidFields, []string{"phone"}},
+		{"wrong type", "application/json", `{"name":"ann","age":"3"}`, usvcErrors.ErrTypeBadRequest, usvcErrors.CodeInvalidFields, []string{"age"}},
+		{"missing required field", "application/json", `{"age":3}`, usvcErrors.ErrTypeBadRequest, usvcErrors.CodeInvalidFields, []string{"name"}},
+		{"every violation", "application/json", `{"name":"anna","kind":"c","tags":["x","y"]}`, usvcErrors.ErrTypeBadRequest, usvcErrors.CodeInvalidFields,
+			[]string{"name", "kind", "tags"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decode(tt.contentType, tt.body)
+			e, ok := usvcErrors.Convert(err)
+			if !ok || e.Type() != tt.wantType || e.Code() != tt.wantCode {
+				t.Fatalf("Decode() err: %v, want %s %s", err, tt.wantType, tt.wantCode)
+			}
+			if len(e.Details()) != len(tt.wantFields) {
+				t.Fatalf("Decode() violations = %+v, want fields %v", e.Details(), tt.wantFields)
+			}
+			for i, v := range e.Details() {
+				if v.Field != tt.wantFields[i] {
+					t.Errorf("Decode() violation %d is of %s, want %s", i, v.Field, tt.wantFields[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	type optional struct {
+		Name *string `json:"name" validate:"required"`
+		Note string  `validate:"min=2"`
+	}
+	empty := ""
+	tests := []struct {
+		name       string
+		v          interface{}
+		wantFields []string
+	}{
+		{"absent pointer", &optional{Note: "ok"}, []string{"name"}},
+		{"present empty pointer", &optional{Name: &empty, Note: "ok"}, nil},
+		{"field without a JSON name", &optional{Name: &empty, Note: "x"}, []string{"Note"}},
+		{"not a struct", "name", nil},
+		{"nil pointer", (*optional)(nil), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := Validate(tt.v)
+			if len(violations) != len(tt.wantFields) {
+				t.Fatalf("Validate() = %+v, want fields %v", violations, tt.wantFields)
+			}
+			for i, v := range violations {
+				if v.Field != tt.wantFields[i] {
+					t.Errorf("Validate() violation %d is of %s, want %s", i, v.Field, tt.wantFields[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMalformedTag(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Validate() with a malformed tag did not panic")
+		}
+	}()
+	Validate(&struct {
+		Name string `validate:"max=ten"`
+	}{})
+}
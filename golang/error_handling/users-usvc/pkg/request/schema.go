@@ -0,0 +1,158 @@
+package request
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// The rules supported in `validate` tags. Rules are separated by commas, e.g. `validate:"required,max=100"`.
+//
+//	required      - the field must be present and not empty. Pointer fields only need to be present.
+//	min=N, max=N  - the number of characters in a string, or the number of items in a slice
+//	oneof=a b c   - the value must be one of the space separated values
+//
+// Rules other than `required` are skipped for absent fields, so optional fields are declared as pointers.
+const tagName = "validate"
+
+// rule checks a field value which is not a nil pointer. It returns the description of the violation, if any.
+type rule func(v reflect.Value) (string, bool)
+
+// fieldSchema - the schema of a struct field
+type fieldSchema struct {
+	index    int
+	name     string // the JSON name of the field, which is reported in violations
+	required bool
+	rules    []rule
+}
+
+// schemas caches the schemas of struct types, i.e. `reflect.Type` -> `[]*fieldSchema`
+var schemas sync.Map
+
+// Validate validates the struct, or pointer to a struct, against the schema in its `validate` tags and returns
+// every violation it finds. It panics if a tag is malformed, as that is a programming error.
+func Validate(v interface{}) []usvcErrors.FieldViolation {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var violations []usvcErrors.FieldViolation
+	for _, f := range schemaOf(rv.Type()) {
+		fv := rv.Field(f.index)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				if f.required {
+					violations = append(violations, usvcErrors.FieldViolation{Field: f.name, Description: "The field is required."})
+				}
+				continue
+			}
+			fv = fv.Elem()
+		} else if f.required && fv.IsZero() {
+			violations = append(violations, usvcErrors.FieldViolation{Field: f.name, Description: "The field is required."})
+			continue
+		}
+
+		for _, check := range f.rules {
+			if desc, ok := check(fv); !ok {
+				violations = append(violations, usvcErrors.FieldViolation{Field: f.name, Description: desc})
+			}
+		}
+	}
+	return violations
+}
+
+// schemaOf returns the schema of the struct type
+func schemaOf(t reflect.Type) []*fieldSchema {
+	if s, ok := schemas.Load(t); ok {
+		return s.([]*fieldSchema)
+	}
+
+	var fields []*fieldSchema
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup(tagName)
+		if !ok || !sf.IsExported() {
+			continue
+		}
+		f := &fieldSchema{index: i, name: jsonName(sf)}
+		for _, r := range strings.Split(tag, ",") {
+			key, arg, _ := strings.Cut(strings.TrimSpace(r), "=")
+			switch key {
+			case "required":
+				f.required = true
+			case "min", "max":
+				n, err := strconv.Atoi(arg)
+				if err != nil {
+					panic(fmt.Sprintf("request: invalid rule %q of %s.%s", r, t.Name(), sf.Name))
+				}
+				f.rules = append(f.rules, lengthRule(key, n))
+			case "oneof":
+				f.rules = append(f.rules, oneOfRule(strings.Fields(arg)))
+			default:
+				panic(fmt.Sprintf("request: unknown rule %q of %s.%s", r, t.Name(), sf.Name))
+			}
+		}
+		fields = append(fields, f)
+	}
+
+	s, _ := schemas.LoadOrStore(t, fields)
+	return s.([]*fieldSchema)
+}
+
+// jsonName returns the name of the struct field in JSON
+func jsonName(sf reflect.StructField) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return sf.Name
+}
+
+// lengthRule returns the rule which checks the minimum or maximum length of a string or a slice
+func lengthRule(key string, n int) rule {
+	return func(v reflect.Value) (string, bool) {
+		var length int
+		unit := "characters"
+		switch v.Kind() {
+		case reflect.String:
+			length = utf8.RuneCountInString(v.String())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			length, unit = v.Len(), "items"
+		default:
+			return "", true
+		}
+		if key == "min" && length < n {
+			return fmt.Sprintf("The field must have at least %d %s.", n, unit), false
+		}
+		if key == "max" && length > n {
+			return fmt.Sprintf("The field must not exceed %d %s.", n, unit), false
+		}
+		return "", true
+	}
+}
+
+// oneOfRule returns the rule which checks that a string is one of the allowed values
+func oneOfRule(allowed []string) rule {
+	return func(v reflect.Value) (string, bool) {
+		if v.Kind() != reflect.String {
+			return "", true
+		}
+		for _, a := range allowed {
+			if v.String() == a {
+				return "", true
+			}
+		}
+		return fmt.Sprintf("The field must be one of %s.", strings.Join(allowed, ", ")), false
+	}
+}
@@ -0,0 +1,92 @@
+// Package response writes the responses of the HTTP APIs. Bodies are wrapped in one JSON envelope so that clients
+// parse every response the same way:
+//
+//	{"data": {...}}                                    - a resource
+//	{"data": [...], "meta": {"next_cursor": "..."}}    - a page of resources
+//	{"error": {"type": "...", "code": "...", ...}}     - an error, in the problem details format of RFC 7807
+//
+// Headers are always set before the status code is written, as `http.ResponseWriter` ignores them afterwards.
+package response
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// ContentType - the media type of responses
+const ContentType = "application/json"
+
+// Envelope - the body of responses. Exactly one of `Data` and `Error` is set.
+type Envelope struct {
+	Data  interface{}                `json:"data,omitempty"`
+	Meta  *Meta                      `json:"meta,omitempty"`
+	Error *usvcErrors.ProblemDetails `json:"error,omitempty"`
+}
+
+// Meta - the metadata of a page of resources
+type Meta struct {
+	// NextCursor is passed as the `cursor` query parameter to get the next page. It is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// OK writes the data with 200
+func OK(w http.ResponseWriter, data interface{}) {
+	JSON(w, http.StatusOK, &Envelope{Data: data})
+}
+
+// Created writes the data of a created resource with 201. `location` is the URL of the resource.
+func Created(w http.ResponseWriter, location string, data interface{}) {
+	w.Header().Set("Location", location)
+	JSON(w, http.StatusCreated, &Envelope{Data: data})
+}
+
+// Page writes a page of resources with 200. `data` should be a slice, which is written as `[]` rather than `null`
+// when it is empty.
+func Page(w http.ResponseWriter, data interface{}, meta *Meta) {
+	JSON(w, http.StatusOK, &Envelope{Data: data, Meta: meta})
+}
+
+// NoContent writes 204 without a body
+func NoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Error writes the error with the HTTP status code of its type. It is the single place where HTTP handlers turn
+// errors into responses, so handlers only need to return after calling it:
+//
+//	ID, err := userManager.Create(...)
+//	if err != nil {
+//		response.Error(w, r, err)
+//		return
+//	}
+//
+// Server side errors are logged with their causes and origins, which never reach the client.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	ErrorWithData(w, r, err, nil)
+}
+
+// ErrorWithData writes the error like `Error`, along with the result of the work done before the error, e.g. the
+// rows created by an import which failed half way.
+func ErrorWithData(w http.ResponseWriter, r *http.Request, err error, data interface{}) {
+	p := usvcErrors.NewProblemDetails(err)
+	p.Instance = r.URL.Path
+	if p.Status >= http.StatusInternalServerError {
+		log.Printf("[response] error handling %s %s, err: %+v", r.Method, r.URL.Path, err)
+	}
+	JSON(w, p.Status, &Envelope{Data: data, Error: p})
+}
+
+// JSON writes the value as is, without the envelope. It is meant for bodies whose format is defined by a standard,
+// e.g. OAuth 2.0 token responses and JWK sets.
+func JSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		// The status code has been sent, so the client can only see a truncated body
+		log.Printf("[response] error writing the response, err: %s", err.Error())
+	}
+}
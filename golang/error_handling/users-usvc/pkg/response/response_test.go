@@ -0,0 +1,120 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// captureLog redirects the standard logger to a buffer until the test ends
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return buf
+}
+
+func TestSuccessResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		write      func(w http.ResponseWriter)
+		wantStatus int
+		wantBody   string
+	}{
+		{"OK", func(w http.ResponseWriter) { OK(w, map[string]string{"id": "u1"}) }, http.StatusOK, `{"data":{"id":"u1"}}`},
+		{"Created", func(w http.ResponseWriter) { Created(w, "/users/v1/u1", map[string]string{"id": "u1"}) }, http.StatusCreated, `{"data":{"id":"u1"}}`},
+		{"Page", func(w http.ResponseWriter) { Page(w, []string{"u1"}, &Meta{NextCursor: "c1"}) }, http.StatusOK, `{"data":["u1"],"meta":{"next_cursor":"c1"}}`},
+		{"empty Page", func(w http.ResponseWriter) { Page(w, []string{}, &Meta{}) }, http.StatusOK, `{"data":[],"meta":{}}`},
+		{"JSON", func(w http.ResponseWriter) { JSON(w, http.StatusOK, map[string]string{"kty": "OKP"}) }, http.StatusOK, `{"kty":"OKP"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.write(rec)
+			if rec.Code != tt.wantStatus || strings.TrimSpace(rec.Body.String()) != tt.wantBody {
+				t.Errorf("%s() = %d %s, want %d %s", tt.name, rec.Code, rec.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+				t.Errorf("Content-Type = %s, want %s", ct, ContentType)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	Created(rec, "/users/v1/u1", nil)
+	if loc := rec.Header().Get("Location"); loc != "/users/v1/u1" {
+		t.Errorf("Created() Location = %s, want /users/v1/u1", loc)
+	}
+
+	rec = httptest.NewRecorder()
+	NoContent(rec)
+	if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Errorf("NoContent() = %d %s, want 204 without a body", rec.Code, rec.Body.String())
+	}
+}
+
+func TestError(t *testing.T) {
+	logs := captureLog(t)
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantLogged bool
+	}{
+		{"not found", usvcErrors.New(usvcErrors.ErrTypeNotFound, "The user u1 does not exist."), http.StatusNotFound, string(usvcErrors.ErrTypeNotFound), false},
+		{"validation", usvcErrors.NewValidation([]usvcErrors.FieldViolation{{Field: "email", Description: "The email is invalid."}}),
+			http.StatusBadRequest, usvcErrors.CodeInvalidFields, false},
+		{"internal", usvcErrors.WrapInternal(errors.New("connection refused"), "Error getting user u1"), http.StatusInternalServerError, string(usvcErrors.ErrTypeInternalServerErr), true},
+		{"not an Error", errors.New("connection refused"), http.StatusInternalServerError, string(usvcErrors.ErrTypeUnknown), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			rec := httptest.NewRecorder()
+			Error(rec, httptest.NewRequest(http.MethodGet, "/users/v1/u1", nil), tt.err)
+
+			env := &Envelope{}
+			if err := json.Unmarshal(rec.Body.Bytes(), env); err != nil || env.Error == nil {
+				t.Fatalf("Error() = %s, want an error envelope, err: %v", rec.Body.String(), err)
+			}
+			if rec.Code != tt.wantStatus || env.Error.Status != tt.wantStatus || env.Error.Code != tt.wantCode || env.Data != nil {
+				t.Errorf("Error() = %d %s, want %d %s", rec.Code, rec.Body.String(), tt.wantStatus, tt.wantCode)
+			}
+			if env.Error.Instance != "/users/v1/u1" {
+				t.Errorf("Error() instance = %s, want /users/v1/u1", env.Error.Instance)
+			}
+			// Causes are logged for the operators but never reach the client
+			if strings.Contains(rec.Body.String(), "connection refused") {
+				t.Errorf("Error() exposes the cause: %s", rec.Body.String())
+			}
+			if logged := strings.Contains(logs.String(), "GET /users/v1/u1"); logged != tt.wantLogged {
+				t.Errorf("Error() logged %q, want logged %t", logs.String(), tt.wantLogged)
+			}
+		})
+	}
+}
+
+func TestErrorWithData(t *testing.T) {
+	captureLog(t)
+	rec := httptest.NewRecorder()
+	err := usvcErrors.WrapInternal(errors.New("connection refused"), "Error importing users")
+	ErrorWithData(rec, httptest.NewRequest(http.MethodPost, "/users/v1/import", nil), err, map[string]int{"created": 2})
+
+	env := &Envelope{Data: &map[string]int{}}
+	if err := json.Unmarshal(rec.Body.Bytes(), env); err != nil {
+		t.Fatal(err)
+	}
+	if data := *env.Data.(*map[string]int); rec.Code != http.StatusInternalServerError || env.Error == nil || data["created"] != 2 {
+		t.Errorf("ErrorWithData() = %d %s, want 500 with the error and the data", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,72 @@
+// Package sqlschema migrates the tables of databases created by older versions of the service, with the SQL subset
+// shared by SQLite and MySQL.
+//
+// MySQL commits DDL statements implicitly, so a migration which fails half way is not rolled back there. Back up
+// MySQL databases before upgrading the service.
+package sqlschema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// HasColumn tells whether the table has the column. The table must exist.
+func HasColumn(ctx context.Context, db *sql.DB, table, column string) (bool, error) {
+	if err := probe(ctx, db, `SELECT 1 FROM `+table+` WHERE 1 = 0`); err != nil {
+		return false, fmt.Errorf("error reading table %s, err: %s", table, err.Error())
+	}
+	// The table is readable, so the query can only fail on the column
+	return probe(ctx, db, `SELECT `+column+` FROM `+table+` WHERE 1 = 0`) == nil, nil
+}
+
+// AddColumn adds the column with the given definition, e.g. `VARCHAR(64) NOT NULL DEFAULT 'default'`, to the table
+// if the table does not have it
+func AddColumn(ctx context.Context, db *sql.DB, table, column, definition string) error {
+	ok, err := HasColumn(ctx, db, table, column)
+	if err != nil || ok {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `ALTER TABLE `+table+` ADD COLUMN `+column+` `+definition); err != nil {
+		return fmt.Errorf("error adding column %s to table %s, err: %s", column, table, err.Error())
+	}
+	return nil
+}
+
+// RebuildTable recreates the table with the given definition, e.g. to change its primary key or unique constraints,
+// which SQLite cannot alter. The rows are copied with the given columns, which both definitions must have; the other
+// columns of the new definition get their default values.
+func RebuildTable(ctx context.Context, db *sql.DB, table, definition, columns string) error {
+	tmp := table + "_rebuild"
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error rebuilding table %s, err: %s", table, err.Error())
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		// Left over by a rebuild which failed on MySQL
+		`DROP TABLE IF EXISTS ` + tmp,
+		`CREATE TABLE ` + tmp + ` ` + definition,
+		`INSERT INTO ` + tmp + ` (` + columns + `) SELECT ` + columns + ` FROM ` + table,
+		`DROP TABLE ` + table,
+		`ALTER TABLE ` + tmp + ` RENAME TO ` + table,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("error rebuilding table %s, err: %s", table, err.Error())
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error rebuilding table %s, err: %s", table, err.Error())
+	}
+	return nil
+}
+
+// probe runs the query and discards its rows
+func probe(ctx context.Context, db *sql.DB, query string) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
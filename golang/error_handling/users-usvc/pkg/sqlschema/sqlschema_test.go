@@ -0,0 +1,91 @@
+package sqlschema
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestDB opens an in-memory SQLite database with a `users` table holding one row
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	// Every connection to `:memory:` opens a database of its own
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{
+		`CREATE TABLE users (id VARCHAR(64) NOT NULL PRIMARY KEY, email VARCHAR(255) NOT NULL UNIQUE)`,
+		`INSERT INTO users (id, email) VALUES ('u1', 'ann@example.com')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestHasColumn(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	if ok, err := HasColumn(ctx, db, "users", "email"); !ok || err != nil {
+		t.Errorf("HasColumn() of an existing column = %v, %v, want true", ok, err)
+	}
+	if ok, err := HasColumn(ctx, db, "users", "tenant_id"); ok || err != nil {
+		t.Errorf("HasColumn() of a missing column = %v, %v, want false", ok, err)
+	}
+	if _, err := HasColumn(ctx, db, "groups", "id"); err == nil {
+		t.Error("HasColumn() of a missing table err: nil, want an error")
+	}
+}
+
+func TestAddColumn(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	// Adding the column again changes nothing
+	for i := 0; i < 2; i++ {
+		if err := AddColumn(ctx, db, "users", "tenant_id", `VARCHAR(64) NOT NULL DEFAULT 'default'`); err != nil {
+			t.Fatalf("AddColumn() err: %v", err)
+		}
+	}
+	var tenantID string
+	if err := db.QueryRow(`SELECT tenant_id FROM users WHERE id = 'u1'`).Scan(&tenantID); err != nil || tenantID != "default" {
+		t.Errorf("the tenant of the existing row = %q, %v, want the default value", tenantID, err)
+	}
+}
+
+func TestRebuildTable(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	definition := `(id VARCHAR(64) NOT NULL PRIMARY KEY, tenant_id VARCHAR(64) NOT NULL DEFAULT 'default', email VARCHAR(255) NOT NULL, UNIQUE (tenant_id, email))`
+	if err := RebuildTable(ctx, db, "users", definition, "id, email"); err != nil {
+		t.Fatalf("RebuildTable() err: %v", err)
+	}
+
+	var tenantID, email string
+	if err := db.QueryRow(`SELECT tenant_id, email FROM users WHERE id = 'u1'`).Scan(&tenantID, &email); err != nil || tenantID != "default" || email != "ann@example.com" {
+		t.Errorf("the copied row = %q, %q, %v, want ann in the default tenant", tenantID, email, err)
+	}
+	// The new unique constraint replaces the old one
+	if _, err := db.Exec(`INSERT INTO users (id, tenant_id, email) VALUES ('u2', 'acme', 'ann@example.com')`); err != nil {
+		t.Errorf("inserting the email in another tenant err: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO users (id, email) VALUES ('u3', 'ann@example.com')`); err == nil {
+		t.Error("inserting the email in the same tenant err: nil, want a unique constraint error")
+	}
+
+	// A failed rebuild leaves the table as it was
+	if err := RebuildTable(ctx, db, "users", definition, "id, missing"); err == nil {
+		t.Fatal("RebuildTable() with a missing column err: nil, want an error")
+	}
+	if ok, err := HasColumn(ctx, db, "users", "tenant_id"); !ok || err != nil {
+		t.Errorf("HasColumn() after a failed rebuild = %v, %v, want the table unchanged", ok, err)
+	}
+}
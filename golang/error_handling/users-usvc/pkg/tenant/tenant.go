@@ -0,0 +1,55 @@
+// Package tenant carries the tenant of a request through its context. Every call of the user and authz managers and
+// their repositories is scoped to the tenant in the context, so the same email can be used by users of different
+// tenants and the users and roles of one tenant are invisible to the others:
+//
+//	ctx = tenant.WithID(ctx, "acme")
+//	user, err := users.Get(ctx, ID) // not found if the user belongs to another tenant
+//
+// Contexts without a tenant belong to the default tenant, so single-tenant deployments need no changes.
+package tenant
+
+import (
+	"context"
+	"regexp"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+// Default - the tenant of contexts which do not carry one
+const Default = "default"
+
+// Header - the HTTP header which names the tenant of unauthenticated requests. Authenticated requests belong to
+// the tenant of their access tokens.
+const Header = "X-Tenant-ID"
+
+// CodeInvalidTenant - the tenant ID is malformed
+const CodeInvalidTenant = "invalid_tenant"
+
+// idPattern - the format of tenant IDs
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ctxKey - the context key of the tenant ID
+type ctxKey struct{}
+
+// WithID returns a copy of the context carrying the tenant ID
+func WithID(ctx context.Context, ID string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, ID)
+}
+
+// FromContext returns the tenant ID in the context, or `Default` if it does not carry one
+func FromContext(ctx context.Context) string {
+	if ID, ok := ctx.Value(ctxKey{}).(string); ok && ID != "" {
+		return ID
+	}
+	return Default
+}
+
+// Validate checks tenant IDs read from requests: 1 to 64 lower case letters, digits, `-` and `_`, starting with
+// a letter or a digit
+func Validate(ID string) error {
+	if !idPattern.MatchString(ID) {
+		return usvcErrors.NewCoded(usvcErrors.ErrTypeBadRequest, CodeInvalidTenant, map[string]string{"tenant_id": ID},
+			"The tenant ID %q is invalid; it must be 1 to 64 lower case letters, digits, - and _.", ID)
+	}
+	return nil
+}
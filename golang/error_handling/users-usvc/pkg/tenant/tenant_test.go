@@ -0,0 +1,44 @@
+package tenant
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	usvcErrors "github.com/azhuox/blogs/golang/error_handling/users-usvc/pkg/errors"
+)
+
+func TestFromContext(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"no tenant", context.Background(), Default},
+		{"empty tenant", WithID(context.Background(), ""), Default},
+		{"tenant", WithID(context.Background(), "acme"), "acme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromContext(tt.ctx); got != tt.want {
+				t.Errorf("FromContext() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, ID := range []string{"acme", "a", "acme-2_eu", "0acme", strings.Repeat("a", 64)} {
+		if err := Validate(ID); err != nil {
+			t.Errorf("Validate(%q) err: %v, want nil", ID, err)
+		}
+	}
+
+	// Dots are rejected as tenant IDs are a part of the tokens sent in emails
+	for _, ID := range []string{"", "Acme", "-acme", "ac.me", "ac me", strings.Repeat("a", 65)} {
+		e, ok := usvcErrors.Convert(Validate(ID))
+		if !ok || e.Type() != usvcErrors.ErrTypeBadRequest || e.Code() != CodeInvalidTenant {
+			t.Errorf("Validate(%q) err: %v, want %s", ID, e, CodeInvalidTenant)
+		}
+	}
+}
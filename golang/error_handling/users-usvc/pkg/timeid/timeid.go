@@ -0,0 +1,26 @@
+// Package timeid generates IDs which start with their creation time, so that records sort by time by their IDs,
+// e.g. audit records, outbox events and signing keys:
+//
+//	20240102T150405.123456789Z-1a2b3c4d
+//
+// A random suffix keeps IDs generated at the same time apart.
+package timeid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// layout - the format of the time in IDs. It sorts lexically in time order.
+const layout = "20060102T150405.000000000Z"
+
+// New generates an ID which starts with the given time in UTC
+func New(t time.Time) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("error generating a random ID suffix, err: %s", err.Error())
+	}
+	return t.UTC().Format(layout) + "-" + hex.EncodeToString(suffix), nil
+}